@@ -0,0 +1,299 @@
+package complexity
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/graph-gophers/graphql-go/introspection"
+)
+
+// DefaultPageSize is the page size assumed for paginated fields that are
+// queried without first or last.
+const DefaultPageSize = 50
+
+// maxValue caps the computed complexity, so huge page sizes can't overflow it.
+const maxValue = math.MaxInt32
+
+func capped(n int64) int {
+	if n > maxValue {
+		return maxValue
+	}
+	return int(n)
+}
+
+// Result is the outcome of analyzing one operation.
+type Result struct {
+	OperationType string
+	// RootFields are the fields selected on the root type of the operation
+	RootFields []string
+	Complexity int
+	Depth      int
+}
+
+// LimitError is returned for operations exceeding a limit of the analyzer.
+type LimitError struct {
+	// Limit is depth or complexity
+	Limit string
+	Value int
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("query %s %d exceeds the limit of %d", e.Limit, e.Value, e.Max)
+}
+
+func (e *LimitError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":  "QUERY_LIMIT_EXCEEDED",
+		"limit": e.Limit,
+		"value": e.Value,
+		"max":   e.Max,
+	}
+}
+
+type field struct {
+	typeName  string
+	composite bool
+	paginated bool
+}
+
+// Analyzer computes the depth and complexity of operations on a schema.
+//
+// Every field returning an object costs 1, scalar fields are free. Fields
+// taking first or last are paginated: everything selected below them counts
+// once per requested element, or DefaultPageSize times if no size was given.
+// Fragments count at every place they are spread.
+type Analyzer struct {
+	// Costs overrides the cost of single fields, keyed by "Type.field"
+	Costs map[string]int
+	// MaxDepth and MaxComplexity are the limits checked by Check, zero
+	// disables a limit
+	MaxDepth      int
+	MaxComplexity int
+
+	fields    map[string]map[string]field
+	rootTypes map[string]string
+}
+
+func NewAnalyzer(schema *introspection.Schema, maxDepth int, maxComplexity int) *Analyzer {
+	analyzer := &Analyzer{
+		Costs:         make(map[string]int),
+		MaxDepth:      maxDepth,
+		MaxComplexity: maxComplexity,
+		fields:        make(map[string]map[string]field),
+		rootTypes:     make(map[string]string),
+	}
+
+	for _, schemaType := range schema.Types() {
+		fields := schemaType.Fields(&struct{ IncludeDeprecated bool }{true})
+		if schemaType.Name() == nil || fields == nil {
+			continue
+		}
+
+		typeFields := make(map[string]field, len(*fields))
+		for _, schemaField := range *fields {
+			namedType := schemaField.Type()
+			for namedType.OfType() != nil {
+				namedType = namedType.OfType()
+			}
+
+			info := field{
+				typeName:  *namedType.Name(),
+				composite: namedType.Kind() == "OBJECT" || namedType.Kind() == "INTERFACE" || namedType.Kind() == "UNION",
+			}
+			for _, arg := range schemaField.Args() {
+				if arg.Name() == "first" || arg.Name() == "last" {
+					info.paginated = true
+				}
+			}
+
+			typeFields[schemaField.Name()] = info
+		}
+		analyzer.fields[*schemaType.Name()] = typeFields
+	}
+
+	for operationType, rootType := range map[string]*introspection.Type{
+		"query":        schema.QueryType(),
+		"mutation":     schema.MutationType(),
+		"subscription": schema.SubscriptionType(),
+	} {
+		if rootType != nil {
+			analyzer.rootTypes[operationType] = *rootType.Name()
+		}
+	}
+
+	return analyzer
+}
+
+type analysis struct {
+	analyzer  *Analyzer
+	document  *Document
+	variables map[string]interface{}
+	// fragments holds the fragments spread on the current path, to stop at cycles
+	fragments map[string]bool
+}
+
+// Analyze parses queryString and computes the depth and complexity of the
+// operation that would run. Invalid documents return a *SyntaxError, unknown
+// fields and types are ignored, they are rejected by the validation anyway.
+func (a *Analyzer) Analyze(queryString string, operationName string, variables map[string]interface{}) (Result, error) {
+	document, err := Parse(queryString)
+	if err != nil {
+		return Result{}, err
+	}
+
+	operation, err := document.Operation(operationName)
+	if err != nil {
+		return Result{}, err
+	}
+
+	values := make(map[string]interface{}, len(operation.VariableDefaults)+len(variables))
+	for name, value := range operation.VariableDefaults {
+		values[name] = value
+	}
+	for name, value := range variables {
+		values[name] = value
+	}
+
+	run := &analysis{
+		analyzer:  a,
+		document:  document,
+		variables: values,
+		fragments: make(map[string]bool),
+	}
+
+	complexity, depth, err := run.selections(a.rootTypes[operation.Type], operation.Selections)
+	return Result{
+		OperationType: operation.Type,
+		RootFields:    document.RootFields(operation),
+		Complexity:    complexity,
+		Depth:         depth,
+	}, err
+}
+
+// Check analyzes the operation and rejects it with a *LimitError if it
+// exceeds MaxDepth or MaxComplexity.
+func (a *Analyzer) Check(queryString string, operationName string, variables map[string]interface{}) (Result, error) {
+	result, err := a.Analyze(queryString, operationName, variables)
+	if err != nil {
+		return result, err
+	}
+
+	if a.MaxDepth > 0 && result.Depth > a.MaxDepth {
+		return result, &LimitError{Limit: "depth", Value: result.Depth, Max: a.MaxDepth}
+	}
+	if a.MaxComplexity > 0 && result.Complexity > a.MaxComplexity {
+		return result, &LimitError{Limit: "complexity", Value: result.Complexity, Max: a.MaxComplexity}
+	}
+
+	return result, nil
+}
+
+func (r *analysis) selections(typeName string, selections []*Selection) (complexity int, depth int, err error) {
+	for _, selection := range selections {
+		var (
+			selectionComplexity int
+			selectionDepth      int
+		)
+
+		switch selection.Kind {
+		case SelectionField:
+			selectionComplexity, selectionDepth, err = r.field(typeName, selection)
+
+		case SelectionInlineFragment:
+			fragmentType := typeName
+			if selection.TypeCondition != "" {
+				fragmentType = selection.TypeCondition
+			}
+			selectionComplexity, selectionDepth, err = r.selections(fragmentType, selection.Selections)
+
+		case SelectionFragmentSpread:
+			fragment, ok := r.document.Fragments[selection.Name]
+			if !ok || r.fragments[selection.Name] {
+				// unknown fragments and cycles fail the validation
+				continue
+			}
+
+			r.fragments[selection.Name] = true
+			selectionComplexity, selectionDepth, err = r.selections(fragment.TypeCondition, fragment.Selections)
+			delete(r.fragments, selection.Name)
+		}
+
+		if err != nil {
+			return 0, 0, err
+		}
+
+		complexity = capped(int64(complexity) + int64(selectionComplexity))
+		if selectionDepth > depth {
+			depth = selectionDepth
+		}
+	}
+
+	return complexity, depth, nil
+}
+
+func (r *analysis) field(typeName string, selection *Selection) (int, int, error) {
+	info, ok := r.analyzer.fields[typeName][selection.Name]
+	if !ok {
+		// __typename, or a field the validation rejects
+		return 0, 1, nil
+	}
+
+	cost := 0
+	if info.composite {
+		cost = 1
+	}
+	if override, ok := r.analyzer.Costs[typeName+"."+selection.Name]; ok {
+		cost = override
+	}
+
+	childComplexity, childDepth, err := r.selections(info.typeName, selection.Selections)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if info.paginated {
+		childComplexity = capped(int64(childComplexity) * int64(r.pageSize(selection)))
+	}
+
+	return capped(int64(cost) + int64(childComplexity)), childDepth + 1, nil
+}
+
+// pageSize returns the number of elements a paginated field asks for.
+func (r *analysis) pageSize(selection *Selection) int {
+	size := 0
+	for _, name := range []string{"first", "last"} {
+		if n, ok := r.intArgument(selection, name); ok && n > size {
+			size = n
+		}
+	}
+
+	if size <= 0 {
+		return DefaultPageSize
+	}
+	return capped(int64(size))
+}
+
+func (r *analysis) intArgument(selection *Selection, name string) (int, bool) {
+	value := selection.Arguments[name]
+	if variable, ok := value.(Variable); ok {
+		value = r.variables[string(variable)]
+	}
+
+	switch n := value.(type) {
+	case int64:
+		return capped(n), true
+	case int:
+		return n, true
+	case int32:
+		return int(n), true
+	case float64:
+		// json numbers of the variables
+		if n > maxValue {
+			return maxValue, true
+		}
+		return int(n), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,223 @@
+package complexity
+
+import (
+	"math"
+	"testing"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+const testSchema = `
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+type Query {
+	recipe(id: ID!): Recipe
+	recipes(first: Int, last: Int): RecipeConnection
+}
+
+type Mutation {
+	rcRecipeImport: ID
+}
+
+type RecipeConnection {
+	edges: [RecipeEdge]
+}
+
+type RecipeEdge {
+	node: Recipe
+}
+
+type Recipe {
+	name: String
+	related(first: Int): [Recipe]
+}
+`
+
+func newTestAnalyzer(maxDepth int, maxComplexity int) *Analyzer {
+	analyzer := NewAnalyzer(graphql.MustParseSchema(testSchema, nil).Inspect(), maxDepth, maxComplexity)
+	analyzer.Costs["Mutation.rcRecipeImport"] = 100
+	return analyzer
+}
+
+func TestAnalyzerCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		operationName  string
+		variables      map[string]interface{}
+		wantComplexity int
+		wantDepth      int
+		wantRootFields []string
+	}{
+		{
+			name:           "scalars are free",
+			query:          `{ recipe(id: "1") { name } }`,
+			wantComplexity: 1,
+			wantDepth:      2,
+			wantRootFields: []string{"recipe"},
+		},
+		{
+			name:           "default page size",
+			query:          `{ recipes { edges { node { name } } } }`,
+			wantComplexity: 1 + DefaultPageSize*2,
+			wantDepth:      4,
+			wantRootFields: []string{"recipes"},
+		},
+		{
+			name:           "first",
+			query:          `{ recipes(first: 10) { edges { node { name } } } }`,
+			wantComplexity: 1 + 10*2,
+			wantDepth:      4,
+			wantRootFields: []string{"recipes"},
+		},
+		{
+			name:           "larger of first and last",
+			query:          `{ recipes(first: 2, last: 5) { edges { node { name } } } }`,
+			wantComplexity: 1 + 5*2,
+			wantDepth:      4,
+			wantRootFields: []string{"recipes"},
+		},
+		{
+			name:           "variable",
+			query:          `query Recipes($n: Int) { recipes(first: $n) { edges { node { name } } } }`,
+			variables:      map[string]interface{}{"n": float64(3)},
+			wantComplexity: 1 + 3*2,
+			wantDepth:      4,
+			wantRootFields: []string{"recipes"},
+		},
+		{
+			name:           "variable default",
+			query:          `query Recipes($n: Int = 4) { recipes(first: $n) { edges { node { name } } } }`,
+			wantComplexity: 1 + 4*2,
+			wantDepth:      4,
+			wantRootFields: []string{"recipes"},
+		},
+		{
+			name:           "missing variable",
+			query:          `query Recipes($n: Int) { recipes(first: $n) { edges { node { name } } } }`,
+			wantComplexity: 1 + DefaultPageSize*2,
+			wantDepth:      4,
+			wantRootFields: []string{"recipes"},
+		},
+		{
+			name:           "fragments count at every spread",
+			query:          `{ a: recipe(id: "1") { ...Related } b: recipe(id: "2") { ...Related } } fragment Related on Recipe { related(first: 3) { name } }`,
+			wantComplexity: 2 * (1 + 1),
+			wantDepth:      3,
+			wantRootFields: []string{"recipe", "recipe"},
+		},
+		{
+			name:           "inline fragment",
+			query:          `{ recipe(id: "1") { ... on Recipe { related(first: 2) { related(first: 2) { name } } } } }`,
+			wantComplexity: 1 + 1 + 2*1,
+			wantDepth:      4,
+			wantRootFields: []string{"recipe"},
+		},
+		{
+			name:           "root fields of fragments",
+			query:          `{ ...Root } fragment Root on Query { recipe(id: "1") { name } }`,
+			wantComplexity: 1,
+			wantDepth:      2,
+			wantRootFields: []string{"recipe"},
+		},
+		{
+			name:           "fragment cycles stop",
+			query:          `{ recipe(id: "1") { ...A } } fragment A on Recipe { related(first: 1) { ...B } } fragment B on Recipe { related(first: 1) { ...A } }`,
+			wantComplexity: 1 + 1 + 1,
+			wantDepth:      3,
+			wantRootFields: []string{"recipe"},
+		},
+		{
+			name:           "picked operation",
+			query:          `query One { recipe(id: "1") { name } } mutation Import { rcRecipeImport }`,
+			operationName:  "Import",
+			wantComplexity: 100,
+			wantDepth:      1,
+			wantRootFields: []string{"rcRecipeImport"},
+		},
+		{
+			name:           "unknown fields are free",
+			query:          `{ __typename recipe(id: "1") { secret { name } } }`,
+			wantComplexity: 1,
+			wantDepth:      2,
+			wantRootFields: []string{"__typename", "recipe"},
+		},
+		{
+			name:           "overflow is capped",
+			query:          `{ recipes(first: 2147483647) { edges { node { related(first: 2147483647) { related(first: 2147483647) { name } } } } } }`,
+			wantComplexity: math.MaxInt32,
+			wantDepth:      6,
+			wantRootFields: []string{"recipes"},
+		},
+		{
+			name:           "huge variables are capped",
+			query:          `query Recipes($n: Int) { recipes(first: $n) { edges { node { name } } } }`,
+			variables:      map[string]interface{}{"n": float64(1e300)},
+			wantComplexity: math.MaxInt32,
+			wantDepth:      4,
+			wantRootFields: []string{"recipes"},
+		},
+	}
+
+	analyzer := newTestAnalyzer(0, 0)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := analyzer.Check(test.query, test.operationName, test.variables)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Complexity != test.wantComplexity {
+				t.Errorf("complexity = %d, want %d", result.Complexity, test.wantComplexity)
+			}
+			if result.Depth != test.wantDepth {
+				t.Errorf("depth = %d, want %d", result.Depth, test.wantDepth)
+			}
+			if len(result.RootFields) != len(test.wantRootFields) {
+				t.Fatalf("root fields = %v, want %v", result.RootFields, test.wantRootFields)
+			}
+			for i := range test.wantRootFields {
+				if result.RootFields[i] != test.wantRootFields[i] {
+					t.Errorf("root fields = %v, want %v", result.RootFields, test.wantRootFields)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzerCheckRejects(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		operationName string
+		wantLimit     string
+	}{
+		{"too deep", `{ recipe(id: "1") { related(first: 1) { related(first: 1) { related(first: 1) { name } } } } }`, "", "depth"},
+		{"too complex", `{ recipes(first: 100) { edges { node { name } } } }`, "", "complexity"},
+		{"overflow", `{ recipes(first: 2147483647) { edges { node { name } } } }`, "", "complexity"},
+		{"syntax error", `{ recipe(id: "1") { name }`, "", ""},
+		{"unknown operation", `query One { recipe(id: "1") { name } }`, "Two", ""},
+		{"several operations without a name", `query One { recipe(id: "1") { name } } query Two { recipe(id: "1") { name } }`, "", ""},
+	}
+
+	analyzer := newTestAnalyzer(4, 100)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := analyzer.Check(test.query, test.operationName, nil)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			limitError, ok := err.(*LimitError)
+			switch {
+			case test.wantLimit == "" && ok:
+				t.Errorf("err = %v, want an error that isn't a limit", err)
+			case test.wantLimit != "" && (!ok || limitError.Limit != test.wantLimit):
+				t.Errorf("err = %v, want the %v limit", err, test.wantLimit)
+			}
+		})
+	}
+}
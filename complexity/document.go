@@ -0,0 +1,552 @@
+package complexity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The graphql library keeps its query parser internal, so the analyzer parses
+// documents itself. The parser only keeps what the analysis needs: the shape
+// of the selections and the argument values. Anything the library would
+// reject is left for it to report.
+
+// Document is a parsed GraphQL request document.
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+type Operation struct {
+	// Type is query, mutation or subscription
+	Type string
+	Name string
+	// VariableDefaults holds the default values of the declared variables
+	VariableDefaults map[string]interface{}
+	Selections       []*Selection
+}
+
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Selections    []*Selection
+}
+
+const (
+	SelectionField          = "field"
+	SelectionFragmentSpread = "fragmentSpread"
+	SelectionInlineFragment = "inlineFragment"
+)
+
+// Selection is a field, a fragment spread or an inline fragment.
+type Selection struct {
+	Kind string
+	// Name is the name of the field or of the spread fragment
+	Name      string
+	Arguments map[string]interface{}
+	// TypeCondition is set on inline fragments that have one
+	TypeCondition string
+	Selections    []*Selection
+}
+
+// Variable is an argument value referring to a variable.
+type Variable string
+
+// SyntaxError is returned for documents that aren't valid GraphQL.
+type SyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Operation returns the operation to run, like the executor would pick it.
+func (d *Document) Operation(name string) (*Operation, error) {
+	if name == "" {
+		if len(d.Operations) != 1 {
+			return nil, fmt.Errorf("expected exactly one operation without an operation name, got %d", len(d.Operations))
+		}
+		return d.Operations[0], nil
+	}
+
+	for _, operation := range d.Operations {
+		if operation.Name == name {
+			return operation, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no operation with name %q", name)
+}
+
+// RootFields returns the names of the fields operation selects on its root
+// type, including those selected by fragments.
+func (d *Document) RootFields(operation *Operation) []string {
+	return d.rootFields(operation.Selections, nil, make(map[string]bool))
+}
+
+func (d *Document) rootFields(selections []*Selection, names []string, spread map[string]bool) []string {
+	for _, selection := range selections {
+		switch selection.Kind {
+		case SelectionField:
+			names = append(names, selection.Name)
+		case SelectionInlineFragment:
+			names = d.rootFields(selection.Selections, names, spread)
+		case SelectionFragmentSpread:
+			// spread holds the fragments on the current path, to stop at cycles
+			if fragment, ok := d.Fragments[selection.Name]; ok && !spread[selection.Name] {
+				spread[selection.Name] = true
+				names = d.rootFields(fragment.Selections, names, spread)
+				delete(spread, selection.Name)
+			}
+		}
+	}
+	return names
+}
+
+const (
+	tokenEOF = iota
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+	tokenPunctuator
+)
+
+type token struct {
+	kind   int
+	value  string
+	line   int
+	column int
+}
+
+type parser struct {
+	source string
+	offset int
+	line   int
+	column int
+	token  token
+}
+
+// Parse parses a GraphQL request document.
+func Parse(source string) (document *Document, err error) {
+	p := &parser{source: source, line: 1, column: 1}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			syntaxError, ok := recovered.(*SyntaxError)
+			if !ok {
+				panic(recovered)
+			}
+			document, err = nil, syntaxError
+		}
+	}()
+
+	p.next()
+	document = p.parseDocument()
+	return document, nil
+}
+
+func (p *parser) fail(format string, args ...interface{}) {
+	panic(&SyntaxError{Line: p.token.line, Column: p.token.column, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) advance(n int) {
+	for i := 0; i < n && p.offset < len(p.source); i++ {
+		if p.source[p.offset] == '\n' {
+			p.line++
+			p.column = 1
+		} else {
+			p.column++
+		}
+		p.offset++
+	}
+}
+
+func (p *parser) skipIgnored() {
+	for p.offset < len(p.source) {
+		switch c := p.source[p.offset]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.advance(1)
+		case c == '#':
+			for p.offset < len(p.source) && p.source[p.offset] != '\n' {
+				p.advance(1)
+			}
+		case strings.HasPrefix(p.source[p.offset:], "\uFEFF"):
+			p.advance(len("\uFEFF"))
+		default:
+			return
+		}
+	}
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// next reads the next token into p.token.
+func (p *parser) next() {
+	p.skipIgnored()
+	p.token = token{line: p.line, column: p.column}
+
+	if p.offset >= len(p.source) {
+		p.token.kind = tokenEOF
+		return
+	}
+
+	start := p.offset
+	c := p.source[p.offset]
+
+	switch {
+	case isNameStart(c):
+		for p.offset < len(p.source) && (isNameStart(p.source[p.offset]) || isDigit(p.source[p.offset])) {
+			p.advance(1)
+		}
+		p.token.kind = tokenName
+		p.token.value = p.source[start:p.offset]
+
+	case c == '-' || isDigit(c):
+		p.readNumber()
+
+	case strings.HasPrefix(p.source[p.offset:], `"""`):
+		p.readBlockString()
+
+	case c == '"':
+		p.readString()
+
+	case strings.HasPrefix(p.source[p.offset:], "..."):
+		p.advance(3)
+		p.token.kind = tokenPunctuator
+		p.token.value = "..."
+
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		p.advance(1)
+		p.token.kind = tokenPunctuator
+		p.token.value = string(c)
+
+	default:
+		p.fail("unexpected character %q", c)
+	}
+}
+
+func (p *parser) readNumber() {
+	start := p.offset
+	p.token.kind = tokenInt
+
+	if p.source[p.offset] == '-' {
+		p.advance(1)
+	}
+	digits := func() {
+		if p.offset >= len(p.source) || !isDigit(p.source[p.offset]) {
+			p.fail("invalid number")
+		}
+		for p.offset < len(p.source) && isDigit(p.source[p.offset]) {
+			p.advance(1)
+		}
+	}
+
+	digits()
+	if p.offset < len(p.source) && p.source[p.offset] == '.' {
+		p.token.kind = tokenFloat
+		p.advance(1)
+		digits()
+	}
+	if p.offset < len(p.source) && (p.source[p.offset] == 'e' || p.source[p.offset] == 'E') {
+		p.token.kind = tokenFloat
+		p.advance(1)
+		if p.offset < len(p.source) && (p.source[p.offset] == '+' || p.source[p.offset] == '-') {
+			p.advance(1)
+		}
+		digits()
+	}
+
+	p.token.value = p.source[start:p.offset]
+}
+
+func (p *parser) readString() {
+	p.advance(1)
+	start := p.offset
+
+	for {
+		if p.offset >= len(p.source) || p.source[p.offset] == '\n' {
+			p.fail("unterminated string")
+		}
+		switch p.source[p.offset] {
+		case '"':
+			value, err := strconv.Unquote(`"` + p.source[start:p.offset] + `"`)
+			if err != nil {
+				// GraphQL escapes are a subset of Go's, the value is only kept
+				// for completeness
+				value = p.source[start:p.offset]
+			}
+			p.advance(1)
+			p.token.kind = tokenString
+			p.token.value = value
+			return
+		case '\\':
+			p.advance(2)
+		default:
+			p.advance(1)
+		}
+	}
+}
+
+func (p *parser) readBlockString() {
+	p.advance(3)
+	start := p.offset
+
+	for {
+		if p.offset >= len(p.source) {
+			p.fail("unterminated block string")
+		}
+		if strings.HasPrefix(p.source[p.offset:], `\"""`) {
+			p.advance(4)
+			continue
+		}
+		if strings.HasPrefix(p.source[p.offset:], `"""`) {
+			p.token.kind = tokenString
+			p.token.value = p.source[start:p.offset]
+			p.advance(3)
+			return
+		}
+		p.advance(1)
+	}
+}
+
+func (p *parser) peek(kind int, value string) bool {
+	return p.token.kind == kind && p.token.value == value
+}
+
+func (p *parser) skip(kind int, value string) bool {
+	if p.peek(kind, value) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind int, value string) {
+	if !p.skip(kind, value) {
+		p.fail("expected %q, got %q", value, p.token.value)
+	}
+}
+
+func (p *parser) name() string {
+	if p.token.kind != tokenName {
+		p.fail("expected a name, got %q", p.token.value)
+	}
+	name := p.token.value
+	p.next()
+	return name
+}
+
+func (p *parser) parseDocument() *Document {
+	document := &Document{Fragments: make(map[string]*Fragment)}
+
+	for p.token.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunctuator, "{"):
+			document.Operations = append(document.Operations, &Operation{
+				Type:       "query",
+				Selections: p.parseSelectionSet(),
+			})
+		case p.peek(tokenName, "query") || p.peek(tokenName, "mutation") || p.peek(tokenName, "subscription"):
+			document.Operations = append(document.Operations, p.parseOperation())
+		case p.peek(tokenName, "fragment"):
+			fragment := p.parseFragment()
+			document.Fragments[fragment.Name] = fragment
+		default:
+			p.fail("unexpected %q", p.token.value)
+		}
+	}
+
+	if len(document.Operations) == 0 {
+		p.fail("no operations in query document")
+	}
+
+	return document
+}
+
+func (p *parser) parseOperation() *Operation {
+	operation := &Operation{Type: p.name(), VariableDefaults: make(map[string]interface{})}
+
+	if p.token.kind == tokenName {
+		operation.Name = p.name()
+	}
+
+	if p.skip(tokenPunctuator, "(") {
+		for !p.skip(tokenPunctuator, ")") {
+			p.expect(tokenPunctuator, "$")
+			name := p.name()
+			p.expect(tokenPunctuator, ":")
+			p.parseType()
+			if p.skip(tokenPunctuator, "=") {
+				operation.VariableDefaults[name] = p.parseValue(true)
+			}
+			p.parseDirectives()
+		}
+	}
+
+	p.parseDirectives()
+	operation.Selections = p.parseSelectionSet()
+
+	return operation
+}
+
+func (p *parser) parseFragment() *Fragment {
+	p.expect(tokenName, "fragment")
+	fragment := &Fragment{Name: p.name()}
+	p.expect(tokenName, "on")
+	fragment.TypeCondition = p.name()
+	p.parseDirectives()
+	fragment.Selections = p.parseSelectionSet()
+	return fragment
+}
+
+func (p *parser) parseType() {
+	if p.skip(tokenPunctuator, "[") {
+		p.parseType()
+		p.expect(tokenPunctuator, "]")
+	} else {
+		p.name()
+	}
+	p.skip(tokenPunctuator, "!")
+}
+
+func (p *parser) parseDirectives() {
+	for p.skip(tokenPunctuator, "@") {
+		p.name()
+		if p.peek(tokenPunctuator, "(") {
+			p.parseArguments()
+		}
+	}
+}
+
+func (p *parser) parseSelectionSet() []*Selection {
+	p.expect(tokenPunctuator, "{")
+
+	var selections []*Selection
+	for !p.skip(tokenPunctuator, "}") {
+		selections = append(selections, p.parseSelection())
+	}
+
+	if len(selections) == 0 {
+		p.fail("empty selection set")
+	}
+
+	return selections
+}
+
+func (p *parser) parseSelection() *Selection {
+	if p.skip(tokenPunctuator, "...") {
+		if p.token.kind == tokenName && p.token.value != "on" {
+			selection := &Selection{Kind: SelectionFragmentSpread, Name: p.name()}
+			p.parseDirectives()
+			return selection
+		}
+
+		selection := &Selection{Kind: SelectionInlineFragment}
+		if p.skip(tokenName, "on") {
+			selection.TypeCondition = p.name()
+		}
+		p.parseDirectives()
+		selection.Selections = p.parseSelectionSet()
+		return selection
+	}
+
+	selection := &Selection{Kind: SelectionField, Name: p.name()}
+	if p.skip(tokenPunctuator, ":") {
+		// the alias doesn't matter, the field does
+		selection.Name = p.name()
+	}
+
+	if p.peek(tokenPunctuator, "(") {
+		selection.Arguments = p.parseArguments()
+	}
+	p.parseDirectives()
+
+	if p.peek(tokenPunctuator, "{") {
+		selection.Selections = p.parseSelectionSet()
+	}
+
+	return selection
+}
+
+func (p *parser) parseArguments() map[string]interface{} {
+	p.expect(tokenPunctuator, "(")
+
+	arguments := make(map[string]interface{})
+	for !p.skip(tokenPunctuator, ")") {
+		name := p.name()
+		p.expect(tokenPunctuator, ":")
+		arguments[name] = p.parseValue(false)
+	}
+
+	return arguments
+}
+
+func (p *parser) parseValue(constant bool) interface{} {
+	current := p.token
+
+	switch current.kind {
+	case tokenInt:
+		p.next()
+		value, err := strconv.ParseInt(current.value, 10, 64)
+		if err != nil {
+			p.fail("invalid int %q", current.value)
+		}
+		return value
+	case tokenFloat:
+		p.next()
+		value, _ := strconv.ParseFloat(current.value, 64)
+		return value
+	case tokenString:
+		p.next()
+		return current.value
+	case tokenName:
+		p.next()
+		switch current.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		// an enum value
+		return current.value
+	}
+
+	switch {
+	case p.skip(tokenPunctuator, "$"):
+		if constant {
+			p.fail("unexpected variable")
+		}
+		return Variable(p.name())
+
+	case p.skip(tokenPunctuator, "["):
+		var list []interface{}
+		for !p.skip(tokenPunctuator, "]") {
+			list = append(list, p.parseValue(constant))
+		}
+		return list
+
+	case p.skip(tokenPunctuator, "{"):
+		object := make(map[string]interface{})
+		for !p.skip(tokenPunctuator, "}") {
+			name := p.name()
+			p.expect(tokenPunctuator, ":")
+			object[name] = p.parseValue(constant)
+		}
+		return object
+	}
+
+	p.fail("unexpected %q", current.value)
+	return nil
+}
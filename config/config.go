@@ -0,0 +1,202 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	StorageMongo  = "mongo"
+	StorageMemory = "memory"
+
+	EventbusNsq   = "nsq"
+	EventbusLocal = "local"
+
+	PersistedQueriesOff       = "off"
+	PersistedQueriesAuto      = "auto"
+	PersistedQueriesAllowlist = "allowlist"
+
+	TracingExporterNone = "none"
+	TracingExporterOTLP = "otlp"
+)
+
+// Config is everything the service can be configured with.
+//
+// Every field is loaded, in increasing precedence, from its default, the
+// optional config file, the environment variable named in the env tag and
+// the command-line flag named in the flag tag. Fields tagged secret are
+// redacted when the config is printed, secret:"url" only masks the password
+// of a url.
+type Config struct {
+	Port            string        `yaml:"port" env:"PORT" flag:"port" default:"8080"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" default:"20s"`
+	// Storage is StorageMongo, or StorageMemory to run without a database
+	Storage string `yaml:"storage" env:"STORAGE" flag:"storage" default:"mongo"`
+	// Eventbus is EventbusNsq, or EventbusLocal to run without nsq
+	Eventbus string `yaml:"eventbus" env:"EVENTBUS" flag:"eventbus" default:"nsq"`
+
+	Log     LogConfig     `yaml:"log"`
+	Tracing TracingConfig `yaml:"tracing"`
+
+	GraphQL   GraphQLConfig   `yaml:"graphql"`
+	RateLimit RateLimitConfig `yaml:"rateLimit"`
+	Socket    SocketConfig    `yaml:"socket"`
+
+	Service ServiceConfig `yaml:"service"`
+	Mongo   MongoConfig   `yaml:"mongo"`
+	Nsq     NsqConfig     `yaml:"nsq"`
+	Gateway GatewayConfig `yaml:"gateway"`
+	RC      RCConfig      `yaml:"rc"`
+	Import  ImportConfig  `yaml:"import"`
+}
+
+type LogConfig struct {
+	// Format is json or text
+	Format string `yaml:"format" env:"LOG_FORMAT" flag:"log-format" default:"json"`
+	// Level is debug, info, warn or error
+	Level string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" default:"info"`
+}
+
+type TracingConfig struct {
+	// Exporter is TracingExporterNone, or TracingExporterOTLP to export spans via OTLP over http
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter" default:"none"`
+	// Endpoint is the host:port of the OTLP collector
+	Endpoint string `yaml:"endpoint" env:"TRACING_ENDPOINT" flag:"tracing-endpoint" default:"localhost:4318"`
+	Insecure bool   `yaml:"insecure" env:"TRACING_INSECURE" flag:"tracing-insecure"`
+	// SampleRatio is the share of new traces that are recorded, traces
+	// started elsewhere follow the decision of their origin
+	SampleRatio float64 `yaml:"sampleRatio" env:"TRACING_SAMPLE_RATIO" flag:"tracing-sample-ratio" default:"1"`
+}
+
+// GraphQLConfig limits what a single operation and a single user may ask for.
+type GraphQLConfig struct {
+	MaxDepth      int `yaml:"maxDepth" env:"GRAPHQL_MAX_DEPTH" flag:"graphql-max-depth" default:"10"`
+	MaxComplexity int `yaml:"maxComplexity" env:"GRAPHQL_MAX_COMPLEXITY" flag:"graphql-max-complexity" default:"5000"`
+	// ComplexityBudget is the complexity every user may spend per minute, 0 disables the budget
+	ComplexityBudget int `yaml:"complexityBudget" env:"GRAPHQL_COMPLEXITY_BUDGET" flag:"graphql-complexity-budget" default:"50000"`
+
+	PersistedQueries PersistedQueriesConfig `yaml:"persistedQueries"`
+}
+
+// PersistedQueriesConfig configures automatic persisted queries, clients
+// sending the sha256 hash of a query instead of the query.
+type PersistedQueriesConfig struct {
+	// Mode is PersistedQueriesOff, PersistedQueriesAuto to let clients persist
+	// their queries, or PersistedQueriesAllowlist to only run the queries of
+	// the manifest
+	Mode string `yaml:"mode" env:"PERSISTED_QUERIES" flag:"persisted-queries" default:"auto"`
+	// Manifest is a json file mapping sha256 hashes to queries, loaded on startup
+	Manifest string `yaml:"manifest" env:"PERSISTED_QUERIES_MANIFEST" flag:"persisted-queries-manifest"`
+	// CacheSize is the number of queries kept in memory
+	CacheSize int `yaml:"cacheSize" env:"PERSISTED_QUERIES_CACHE_SIZE" flag:"persisted-queries-cache-size" default:"1000"`
+	// MaxQueryLength is the length of the longest query clients may persist
+	MaxQueryLength int `yaml:"maxQueryLength" env:"PERSISTED_QUERIES_MAX_QUERY_LENGTH" flag:"persisted-queries-max-query-length" default:"16384"`
+	// TTL is how long queries persisted by clients are kept
+	TTL time.Duration `yaml:"ttl" env:"PERSISTED_QUERIES_TTL" flag:"persisted-queries-ttl" default:"720h"`
+}
+
+// RateLimitConfig limits how often every user, client or, for anonymous
+// requests, address may call the service. A rate of 0 disables the limit.
+type RateLimitConfig struct {
+	RequestsPerMinute  float64 `yaml:"requestsPerMinute" env:"RATE_LIMIT_REQUESTS_PER_MINUTE" flag:"rate-limit-requests-per-minute" default:"600"`
+	RequestsBurst      int     `yaml:"requestsBurst" env:"RATE_LIMIT_REQUESTS_BURST" flag:"rate-limit-requests-burst" default:"100"`
+	MutationsPerMinute float64 `yaml:"mutationsPerMinute" env:"RATE_LIMIT_MUTATIONS_PER_MINUTE" flag:"rate-limit-mutations-per-minute" default:"120"`
+	MutationsBurst     int     `yaml:"mutationsBurst" env:"RATE_LIMIT_MUTATIONS_BURST" flag:"rate-limit-mutations-burst" default:"20"`
+	// Imports counts import mutations and uploads to /import
+	ImportsPerMinute float64 `yaml:"importsPerMinute" env:"RATE_LIMIT_IMPORTS_PER_MINUTE" flag:"rate-limit-imports-per-minute" default:"2"`
+	ImportsBurst     int     `yaml:"importsBurst" env:"RATE_LIMIT_IMPORTS_BURST" flag:"rate-limit-imports-burst" default:"3"`
+	// Expensive counts queries with a complexity of at least ExpensiveComplexity
+	ExpensivePerMinute  float64 `yaml:"expensivePerMinute" env:"RATE_LIMIT_EXPENSIVE_PER_MINUTE" flag:"rate-limit-expensive-per-minute" default:"30"`
+	ExpensiveBurst      int     `yaml:"expensiveBurst" env:"RATE_LIMIT_EXPENSIVE_BURST" flag:"rate-limit-expensive-burst" default:"10"`
+	ExpensiveComplexity int     `yaml:"expensiveComplexity" env:"RATE_LIMIT_EXPENSIVE_COMPLEXITY" flag:"rate-limit-expensive-complexity" default:"500"`
+	// Connections counts new /socket connections on connection_init
+	ConnectionsPerMinute float64 `yaml:"connectionsPerMinute" env:"RATE_LIMIT_CONNECTIONS_PER_MINUTE" flag:"rate-limit-connections-per-minute" default:"30"`
+	ConnectionsBurst     int     `yaml:"connectionsBurst" env:"RATE_LIMIT_CONNECTIONS_BURST" flag:"rate-limit-connections-burst" default:"10"`
+}
+
+// SocketConfig configures the websocket endpoint /socket.
+type SocketConfig struct {
+	// AllowedOrigins is a comma separated list of origins browsers may open
+	// the socket from, * allows any. The origin of the service itself and
+	// clients that send no origin are always allowed.
+	AllowedOrigins string `yaml:"allowedOrigins" env:"SOCKET_ALLOWED_ORIGINS" flag:"socket-allowed-origins"`
+	// MaxConnectionsPerUser limits the open connections of every user, client
+	// or anonymous address, 0 disables the limit
+	MaxConnectionsPerUser int `yaml:"maxConnectionsPerUser" env:"SOCKET_MAX_CONNECTIONS_PER_USER" flag:"socket-max-connections-per-user" default:"10"`
+	// InitTimeout is how long clients have to send connection_init
+	InitTimeout time.Duration `yaml:"initTimeout" env:"SOCKET_INIT_TIMEOUT" flag:"socket-init-timeout" default:"10s"`
+	// KeepAlive is the interval of keepalive messages, connections that
+	// didn't answer for IdleTimeout are closed
+	KeepAlive   time.Duration `yaml:"keepAlive" env:"SOCKET_KEEP_ALIVE" flag:"socket-keep-alive" default:"15s"`
+	IdleTimeout time.Duration `yaml:"idleTimeout" env:"SOCKET_IDLE_TIMEOUT" flag:"socket-idle-timeout" default:"60s"`
+}
+
+// Origins splits AllowedOrigins.
+func (c SocketConfig) Origins() []string {
+	var origins []string
+	for _, origin := range strings.Split(c.AllowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// ServiceConfig is what the service announces to the api gateway.
+type ServiceConfig struct {
+	PublishedHostname string `yaml:"publishedHostname" env:"PUBLISHED_HOSTNAME" flag:"published-hostname" default:"servicebackend"`
+	PublishedPort     string `yaml:"publishedPort" env:"PUBLISHED_PORT" flag:"published-port" default:"8080"`
+}
+
+type MongoConfig struct {
+	// Host is a comma separated list of host:port, or a mongodb:// url
+	Host       string        `yaml:"host" env:"DB_HOST" flag:"db-host" default:"localhost" secret:"url"`
+	Database   string        `yaml:"database" env:"DB_NAME" flag:"db-name" default:"recipe"`
+	Username   string        `yaml:"username" env:"DB_USERNAME" flag:"db-username"`
+	Password   string        `yaml:"password" env:"DB_PASSWORD" flag:"db-password" secret:"true"`
+	AuthSource string        `yaml:"authSource" env:"DB_AUTH_SOURCE" flag:"db-auth-source"`
+	ReplicaSet string        `yaml:"replicaSet" env:"DB_REPLICA_SET" flag:"db-replica-set"`
+	Timeout    time.Duration `yaml:"timeout" env:"DB_TIMEOUT" flag:"db-timeout" default:"10s"`
+	PoolLimit  int           `yaml:"poolLimit" env:"DB_POOL_LIMIT" flag:"db-pool-limit" default:"4096"`
+
+	TLS                   bool   `yaml:"tls" env:"DB_TLS" flag:"db-tls"`
+	TLSCAFile             string `yaml:"tlsCaFile" env:"DB_TLS_CA_FILE" flag:"db-tls-ca-file"`
+	TLSInsecureSkipVerify bool   `yaml:"tlsInsecureSkipVerify" env:"DB_TLS_INSECURE_SKIP_VERIFY" flag:"db-tls-insecure-skip-verify"`
+}
+
+type NsqConfig struct {
+	TCPURL        string `yaml:"tcpUrl" env:"NSQD_TCP_URL" flag:"nsqd-tcp-url" default:"localhost:4150"`
+	LookupHTTPURL string `yaml:"lookupHttpUrl" env:"NSQLOOKUP_HTTP_URL" flag:"nsqlookup-http-url" default:"localhost:4161"`
+}
+
+type GatewayConfig struct {
+	Host         string `yaml:"host" env:"API_GATEWAY_HOST" flag:"api-gateway-host" default:"localhost"`
+	Port         string `yaml:"port" env:"API_GATEWAY_PORT" flag:"api-gateway-port" default:"8090"`
+	Path         string `yaml:"path" env:"API_GATEWAY_PATH" flag:"api-gateway-path" default:"/graphql"`
+	ClientID     string `yaml:"clientId" env:"CLIENT_ID" flag:"client-id"`
+	ClientSecret string `yaml:"clientSecret" env:"CLIENT_SECRET" flag:"client-secret" secret:"true"`
+
+	RatePerSecond float64 `yaml:"ratePerSecond" env:"GATEWAY_RATE_PER_SECOND" flag:"gateway-rate-per-second" default:"50"`
+	RateBurst     int     `yaml:"rateBurst" env:"GATEWAY_RATE_BURST" flag:"gateway-rate-burst" default:"10"`
+	MaxConcurrent int     `yaml:"maxConcurrent" env:"GATEWAY_MAX_CONCURRENT" flag:"gateway-max-concurrent" default:"8"`
+
+	// PermissionResyncInterval is how often the permission data is fetched again
+	PermissionResyncInterval time.Duration `yaml:"permissionResyncInterval" env:"PERMISSION_RESYNC_INTERVAL" flag:"permission-resync-interval" default:"10m"`
+}
+
+// RCConfig configures the client of the recipe source used by rcRecipeImport.
+type RCConfig struct {
+	URL           string        `yaml:"url" env:"RC_URL" flag:"rc-url" default:"https://rc.dukfaar.com" secret:"url"`
+	Timeout       time.Duration `yaml:"timeout" env:"RC_TIMEOUT" flag:"rc-timeout" default:"60s"`
+	Retries       int           `yaml:"retries" env:"RC_RETRIES" flag:"rc-retries" default:"3"`
+	RatePerSecond float64       `yaml:"ratePerSecond" env:"RC_RATE_PER_SECOND" flag:"rc-rate-per-second" default:"20"`
+	RateBurst     int           `yaml:"rateBurst" env:"RC_RATE_BURST" flag:"rc-rate-burst" default:"5"`
+	MaxConcurrent int           `yaml:"maxConcurrent" env:"RC_MAX_CONCURRENT" flag:"rc-max-concurrent" default:"4"`
+	// MaxResponseSize is the size in bytes of the largest response read from RC
+	MaxResponseSize int `yaml:"maxResponseSize" env:"RC_MAX_RESPONSE_SIZE" flag:"rc-max-response-size" default:"268435456"`
+}
+
+type ImportConfig struct {
+	Dir     string `yaml:"dir" env:"IMPORT_DIR" flag:"import-dir" default:"/data/import"`
+	Workers int    `yaml:"workers" env:"IMPORT_WORKERS" flag:"import-workers" default:"8"`
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"github.com/globalsign/mgo"
+)
+
+// DialInfo turns the mongo config into the options for mgo.DialWithInfo.
+func (c MongoConfig) DialInfo() (*mgo.DialInfo, error) {
+	info := &mgo.DialInfo{}
+
+	if strings.HasPrefix(c.Host, "mongodb://") {
+		parsed, err := mgo.ParseURL(c.Host)
+		if err != nil {
+			return nil, err
+		}
+		info = parsed
+	} else {
+		info.Addrs = strings.Split(c.Host, ",")
+	}
+
+	info.Database = c.Database
+	info.Timeout = c.Timeout
+	info.PoolLimit = c.PoolLimit
+
+	if c.Username != "" {
+		info.Username = c.Username
+		info.Password = c.Password
+	}
+	if c.AuthSource != "" {
+		info.Source = c.AuthSource
+	}
+	if c.ReplicaSet != "" {
+		info.ReplicaSetName = c.ReplicaSet
+	}
+
+	if c.TLS {
+		tlsConfig, err := c.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		dialer := &net.Dialer{Timeout: c.Timeout}
+		info.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
+			return tls.DialWithDialer(dialer, "tcp", addr.String(), tlsConfig)
+		}
+	}
+
+	return info, nil
+}
+
+func (c MongoConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.TLSInsecureSkipVerify,
+	}
+
+	if c.TLSCAFile != "" {
+		pem, err := ioutil.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in " + c.TLSCAFile)
+		}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every problem of a config, so all of them can be
+// fixed in one go.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(isPort(c.Port), "port (PORT) must be a port number, got %q", c.Port)
+	check(c.ShutdownTimeout > 0, "shutdownTimeout (SHUTDOWN_TIMEOUT) must be positive")
+
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format (LOG_FORMAT) must be json or text, got %q", c.Log.Format)
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level (LOG_LEVEL) must be debug, info, warn or error, got %q", c.Log.Level)
+
+	check(c.Tracing.Exporter == TracingExporterNone || c.Tracing.Exporter == TracingExporterOTLP, "tracing.exporter (TRACING_EXPORTER) must be %q or %q, got %q", TracingExporterNone, TracingExporterOTLP, c.Tracing.Exporter)
+	if c.Tracing.Exporter == TracingExporterOTLP {
+		check(c.Tracing.Endpoint != "", "tracing.endpoint (TRACING_ENDPOINT) is required")
+		check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sampleRatio (TRACING_SAMPLE_RATIO) must be between 0 and 1")
+	}
+
+	check(c.GraphQL.MaxDepth > 0, "graphql.maxDepth (GRAPHQL_MAX_DEPTH) must be positive")
+	check(c.GraphQL.MaxComplexity > 0, "graphql.maxComplexity (GRAPHQL_MAX_COMPLEXITY) must be positive")
+	check(c.GraphQL.ComplexityBudget == 0 || c.GraphQL.ComplexityBudget >= c.GraphQL.MaxComplexity, "graphql.complexityBudget (GRAPHQL_COMPLEXITY_BUDGET) must be 0 or at least graphql.maxComplexity (GRAPHQL_MAX_COMPLEXITY)")
+
+	rateLimit := c.RateLimit
+	for _, rule := range []struct {
+		name  string
+		env   string
+		rate  float64
+		burst int
+	}{
+		{"requests", "REQUESTS", rateLimit.RequestsPerMinute, rateLimit.RequestsBurst},
+		{"mutations", "MUTATIONS", rateLimit.MutationsPerMinute, rateLimit.MutationsBurst},
+		{"imports", "IMPORTS", rateLimit.ImportsPerMinute, rateLimit.ImportsBurst},
+		{"expensive", "EXPENSIVE", rateLimit.ExpensivePerMinute, rateLimit.ExpensiveBurst},
+		{"connections", "CONNECTIONS", rateLimit.ConnectionsPerMinute, rateLimit.ConnectionsBurst},
+	} {
+		check(rule.rate >= 0, "rateLimit.%sPerMinute (RATE_LIMIT_%s_PER_MINUTE) must not be negative", rule.name, rule.env)
+		check(rule.rate == 0 || rule.burst > 0, "rateLimit.%sBurst (RATE_LIMIT_%s_BURST) must be positive", rule.name, rule.env)
+	}
+	check(rateLimit.ExpensiveComplexity > 0, "rateLimit.expensiveComplexity (RATE_LIMIT_EXPENSIVE_COMPLEXITY) must be positive")
+
+	socket := c.Socket
+	check(socket.MaxConnectionsPerUser >= 0, "socket.maxConnectionsPerUser (SOCKET_MAX_CONNECTIONS_PER_USER) must not be negative")
+	check(socket.InitTimeout > 0, "socket.initTimeout (SOCKET_INIT_TIMEOUT) must be positive")
+	check(socket.KeepAlive > 0, "socket.keepAlive (SOCKET_KEEP_ALIVE) must be positive")
+	check(socket.IdleTimeout > socket.KeepAlive, "socket.idleTimeout (SOCKET_IDLE_TIMEOUT) must be longer than socket.keepAlive (SOCKET_KEEP_ALIVE)")
+	for _, origin := range socket.Origins() {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		check(err == nil && u.Scheme != "" && u.Host != "", "socket.allowedOrigins (SOCKET_ALLOWED_ORIGINS): %q is not an origin like https://example.com", origin)
+	}
+
+	persisted := c.GraphQL.PersistedQueries
+	check(persisted.Mode == PersistedQueriesOff || persisted.Mode == PersistedQueriesAuto || persisted.Mode == PersistedQueriesAllowlist,
+		"graphql.persistedQueries.mode (PERSISTED_QUERIES) must be %q, %q or %q, got %q", PersistedQueriesOff, PersistedQueriesAuto, PersistedQueriesAllowlist, persisted.Mode)
+	check(persisted.CacheSize > 0, "graphql.persistedQueries.cacheSize (PERSISTED_QUERIES_CACHE_SIZE) must be positive")
+	check(persisted.MaxQueryLength > 0, "graphql.persistedQueries.maxQueryLength (PERSISTED_QUERIES_MAX_QUERY_LENGTH) must be positive")
+	check(persisted.TTL >= time.Hour, "graphql.persistedQueries.ttl (PERSISTED_QUERIES_TTL) must be at least an hour")
+	check(persisted.Mode != PersistedQueriesAllowlist || persisted.Manifest != "", "graphql.persistedQueries.manifest (PERSISTED_QUERIES_MANIFEST) is required in the %q mode", PersistedQueriesAllowlist)
+	if persisted.Manifest != "" {
+		_, err := os.Stat(persisted.Manifest)
+		check(err == nil, "graphql.persistedQueries.manifest (PERSISTED_QUERIES_MANIFEST): %v", err)
+	}
+
+	check(c.Service.PublishedHostname != "", "service.publishedHostname (PUBLISHED_HOSTNAME) is required")
+	check(isPort(c.Service.PublishedPort), "service.publishedPort (PUBLISHED_PORT) must be a port number, got %q", c.Service.PublishedPort)
+
+	check(c.Storage == StorageMongo || c.Storage == StorageMemory, "storage (STORAGE) must be %q or %q, got %q", StorageMongo, StorageMemory, c.Storage)
+	if c.Storage == StorageMongo {
+		check(c.Mongo.Host != "", "mongo.host (DB_HOST) is required")
+		check(c.Mongo.Database != "", "mongo.database (DB_NAME) is required")
+		check(c.Mongo.Timeout > 0, "mongo.timeout (DB_TIMEOUT) must be positive")
+		check(c.Mongo.PoolLimit >= 0, "mongo.poolLimit (DB_POOL_LIMIT) must not be negative")
+		check(c.Mongo.Password == "" || c.Mongo.Username != "", "mongo.password (DB_PASSWORD) is set without mongo.username (DB_USERNAME)")
+		if c.Mongo.TLSCAFile != "" {
+			check(c.Mongo.TLS, "mongo.tlsCaFile (DB_TLS_CA_FILE) is set but mongo.tls (DB_TLS) is off")
+			_, err := os.Stat(c.Mongo.TLSCAFile)
+			check(err == nil, "mongo.tlsCaFile (DB_TLS_CA_FILE): %v", err)
+		}
+	}
+
+	check(c.Eventbus == EventbusNsq || c.Eventbus == EventbusLocal, "eventbus (EVENTBUS) must be %q or %q, got %q", EventbusNsq, EventbusLocal, c.Eventbus)
+	if c.Eventbus == EventbusNsq {
+		check(c.Nsq.TCPURL != "", "nsq.tcpUrl (NSQD_TCP_URL) is required")
+		check(c.Nsq.LookupHTTPURL != "", "nsq.lookupHttpUrl (NSQLOOKUP_HTTP_URL) is required")
+	}
+
+	check(c.Gateway.Host != "", "gateway.host (API_GATEWAY_HOST) is required")
+	check(isPort(c.Gateway.Port), "gateway.port (API_GATEWAY_PORT) must be a port number, got %q", c.Gateway.Port)
+	check(strings.HasPrefix(c.Gateway.Path, "/"), "gateway.path (API_GATEWAY_PATH) must start with /, got %q", c.Gateway.Path)
+	check(c.Gateway.ClientID != "", "gateway.clientId (CLIENT_ID) is required")
+	check(c.Gateway.ClientSecret != "", "gateway.clientSecret (CLIENT_SECRET) is required")
+	check(c.Gateway.RatePerSecond > 0, "gateway.ratePerSecond (GATEWAY_RATE_PER_SECOND) must be positive")
+	check(c.Gateway.RateBurst > 0, "gateway.rateBurst (GATEWAY_RATE_BURST) must be positive")
+	check(c.Gateway.MaxConcurrent > 0, "gateway.maxConcurrent (GATEWAY_MAX_CONCURRENT) must be positive")
+	check(c.Gateway.PermissionResyncInterval > 0, "gateway.permissionResyncInterval (PERMISSION_RESYNC_INTERVAL) must be positive")
+
+	rcURL, err := url.Parse(c.RC.URL)
+	check(err == nil && rcURL.IsAbs() && rcURL.Host != "", "rc.url (RC_URL) must be an absolute url, got %q", c.RC.URL)
+	check(c.RC.Timeout > 0, "rc.timeout (RC_TIMEOUT) must be positive")
+	check(c.RC.Retries >= 0, "rc.retries (RC_RETRIES) must not be negative")
+	check(c.RC.RatePerSecond > 0, "rc.ratePerSecond (RC_RATE_PER_SECOND) must be positive")
+	check(c.RC.RateBurst > 0, "rc.rateBurst (RC_RATE_BURST) must be positive")
+	check(c.RC.MaxConcurrent > 0, "rc.maxConcurrent (RC_MAX_CONCURRENT) must be positive")
+	check(c.RC.MaxResponseSize > 0, "rc.maxResponseSize (RC_MAX_RESPONSE_SIZE) must be positive")
+
+	check(c.Import.Dir != "", "import.dir (IMPORT_DIR) is required")
+	check(c.Import.Workers > 0, "import.workers (IMPORT_WORKERS) must be positive")
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+func isPort(value string) bool {
+	port, err := strconv.Atoi(value)
+	return err == nil && port > 0 && port < 65536
+}
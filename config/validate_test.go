@@ -0,0 +1,84 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// validConfig is the default config plus the settings without a default.
+func validConfig() *Config {
+	config := Defaults()
+	config.Gateway.ClientID = "recipe"
+	config.Gateway.ClientSecret = "secret"
+	return config
+}
+
+func TestValidateRCMaxResponseSize(t *testing.T) {
+	config := validConfig()
+	if err := config.Validate(); err != nil {
+		t.Fatalf("default config is invalid: %v", err)
+	}
+
+	config.RC.MaxResponseSize = 0
+	err := config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "RC_MAX_RESPONSE_SIZE") {
+		t.Errorf("Validate() = %v, want a problem with RC_MAX_RESPONSE_SIZE", err)
+	}
+}
+
+func TestValidateProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(config *Config)
+		want   string
+	}{
+		{"port", func(c *Config) { c.Port = "70000" }, "PORT"},
+		{"log level", func(c *Config) { c.Log.Level = "verbose" }, "LOG_LEVEL"},
+		{"otlp without endpoint", func(c *Config) { c.Tracing.Exporter = TracingExporterOTLP; c.Tracing.Endpoint = "" }, "TRACING_ENDPOINT"},
+		{"budget below max complexity", func(c *Config) { c.GraphQL.ComplexityBudget = c.GraphQL.MaxComplexity - 1 }, "GRAPHQL_COMPLEXITY_BUDGET"},
+		{"rate without burst", func(c *Config) { c.RateLimit.ImportsBurst = 0 }, "RATE_LIMIT_IMPORTS_BURST"},
+		{"idle timeout below keep alive", func(c *Config) { c.Socket.IdleTimeout = c.Socket.KeepAlive }, "SOCKET_IDLE_TIMEOUT"},
+		{"origin without scheme", func(c *Config) { c.Socket.AllowedOrigins = "example.com" }, "SOCKET_ALLOWED_ORIGINS"},
+		{"allowlist without manifest", func(c *Config) { c.GraphQL.PersistedQueries.Mode = PersistedQueriesAllowlist }, "PERSISTED_QUERIES_MANIFEST"},
+		{"password without username", func(c *Config) { c.Mongo.Password = "secret" }, "DB_USERNAME"},
+		{"relative gateway path", func(c *Config) { c.Gateway.Path = "graphql" }, "API_GATEWAY_PATH"},
+		{"missing client secret", func(c *Config) { c.Gateway.ClientSecret = "" }, "CLIENT_SECRET"},
+		{"relative rc url", func(c *Config) { c.RC.URL = "rc.example.com" }, "RC_URL"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := validConfig()
+			test.change(config)
+
+			err := config.Validate()
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Validate() = %v, want a problem with %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateSkipsUnusedBackends(t *testing.T) {
+	config := validConfig()
+	config.Storage = StorageMemory
+	config.Eventbus = EventbusLocal
+	config.Mongo.Host = ""
+	config.Nsq.TCPURL = ""
+
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want mongo and nsq to be ignored", err)
+	}
+}
+
+func TestValidateListsAllProblems(t *testing.T) {
+	config := validConfig()
+	config.Port = "http"
+	config.Gateway.ClientID = ""
+	config.Import.Workers = 0
+
+	validationErr, ok := config.Validate().(*ValidationError)
+	if !ok || len(validationErr.Problems) != 3 {
+		t.Fatalf("Validate() = %v, want 3 problems", validationErr)
+	}
+}
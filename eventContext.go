@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/tracing"
+)
+
+// EventHandler handles a message of the eventbus. ctx carries a logger, the
+// correlation id of the message and the span handling it.
+type EventHandler func(ctx context.Context, msg []byte) error
+
+type eventCorrelation struct {
+	CorrelationID string            `json:"correlationId"`
+	TraceContext  map[string]string `json:"traceContext"`
+}
+
+// withEventContext adapts handler to the eventbus. Messages that carry a
+// correlationId continue the correlation of the request that caused them,
+// all others get a new one. Likewise the handler span continues the trace
+// the message was emitted in, if it carries one.
+func withEventContext(ctx context.Context, topic string, handler EventHandler) func(msg []byte) error {
+	logger := logging.FromContext(ctx).With("topic", topic)
+
+	return func(msg []byte) error {
+		var correlation eventCorrelation
+		// plenty of events are plain ids, they just don't have a correlation id
+		json.Unmarshal(msg, &correlation)
+
+		correlationID := correlation.CorrelationID
+		if correlationID == "" {
+			correlationID = bson.NewObjectId().Hex()
+		}
+
+		handlerCtx := logging.WithRequestID(logging.WithLogger(ctx, logger), correlationID)
+		return tracing.Handle(handlerCtx, topic, correlation.TraceContext, func(ctx context.Context) error {
+			return handler(ctx, msg)
+		})
+	}
+}
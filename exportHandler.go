@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/importer"
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/recipe"
+)
+
+// exportFlushInterval is the number of recipes written between two flushes,
+// so clients see a steady stream instead of one burst at the end.
+const exportFlushInterval = 500
+
+// exportCSVFields are the recipe fields of the importer, written in the
+// columns importer.DefaultFieldMapping reads them from, so an exported csv
+// can be imported again unchanged.
+var exportCSVFields = []string{
+	"externalId",
+	"inputs",
+	"outputs",
+	"craftingLevel",
+	"craftingJobId",
+	"masterbook",
+	"requiredControl",
+	"requiredCraftsmanship",
+	"stars",
+}
+
+// exportCSVExtraColumns follow the importer fields and are ignored by the importer.
+var exportCSVExtraColumns = []string{
+	"recipeId",
+	"namespaceId",
+	"importSource",
+}
+
+func exportCSVHeader() []string {
+	header := make([]string, 0, len(exportCSVFields)+len(exportCSVExtraColumns))
+	for _, field := range exportCSVFields {
+		header = append(header, importer.DefaultFieldMapping.Source(field))
+	}
+	return append(header, exportCSVExtraColumns...)
+}
+
+type recipeWriter interface {
+	Begin() error
+	Write(*recipe.Model) error
+	End() error
+}
+
+type jsonRecipeWriter struct {
+	w     http.ResponseWriter
+	first bool
+}
+
+func (j *jsonRecipeWriter) Begin() error {
+	j.first = true
+	_, err := j.w.Write([]byte("["))
+	return err
+}
+
+func (j *jsonRecipeWriter) Write(model *recipe.Model) error {
+	if !j.first {
+		if _, err := j.w.Write([]byte(",\n")); err != nil {
+			return err
+		}
+	}
+	j.first = false
+
+	return json.NewEncoder(j.w).Encode(model)
+}
+
+func (j *jsonRecipeWriter) End() error {
+	_, err := j.w.Write([]byte("]\n"))
+	return err
+}
+
+type ndjsonRecipeWriter struct {
+	encoder *json.Encoder
+}
+
+func (n *ndjsonRecipeWriter) Begin() error {
+	return nil
+}
+
+func (n *ndjsonRecipeWriter) Write(model *recipe.Model) error {
+	return n.encoder.Encode(model)
+}
+
+func (n *ndjsonRecipeWriter) End() error {
+	return nil
+}
+
+type csvRecipeWriter struct {
+	writer *csv.Writer
+}
+
+func (c *csvRecipeWriter) Begin() error {
+	return c.writer.Write(exportCSVHeader())
+}
+
+func formatObjectID(id *bson.ObjectId) string {
+	if id == nil {
+		return ""
+	}
+	return id.Hex()
+}
+
+func formatInt(value *int32) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(int(*value))
+}
+
+func formatString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// formatElements writes inputs and outputs the way the CSV importer reads them.
+func formatElements(elements []recipe.InOutElement) string {
+	parts := make([]string, len(elements))
+	for i, element := range elements {
+		parts[i] = fmt.Sprintf("%v:%v", element.ItemID.Hex(), element.Amount)
+	}
+	return strings.Join(parts, ";")
+}
+
+func (c *csvRecipeWriter) Write(model *recipe.Model) error {
+	inputs := make([]recipe.InOutElement, len(model.Inputs))
+	for i := range model.Inputs {
+		inputs[i] = model.Inputs[i].InOutElement
+	}
+	outputs := make([]recipe.InOutElement, len(model.Outputs))
+	for i := range model.Outputs {
+		outputs[i] = model.Outputs[i].InOutElement
+	}
+
+	// recipes that weren't imported are identified by their own id
+	externalID := model.ID.Hex()
+	if model.ExternalID != nil {
+		externalID = *model.ExternalID
+	}
+
+	return c.writer.Write([]string{
+		externalID,
+		formatElements(inputs),
+		formatElements(outputs),
+		formatInt(model.CraftingLevel),
+		formatObjectID(model.CraftingJobID),
+		formatInt(model.Masterbook),
+		formatInt(model.RequiredControl),
+		formatInt(model.RequiredCraftsmanship),
+		formatInt(model.Stars),
+		model.ID.Hex(),
+		formatObjectID(model.NamespaceID),
+		formatString(model.ImportSource),
+	})
+}
+
+func (c *csvRecipeWriter) End() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func newRecipeWriter(format string, w http.ResponseWriter) (recipeWriter, string, error) {
+	switch format {
+	case "", "json":
+		return &jsonRecipeWriter{w: w}, "application/json", nil
+	case "ndjson":
+		return &ndjsonRecipeWriter{encoder: json.NewEncoder(w)}, "application/x-ndjson", nil
+	case "csv":
+		return &csvRecipeWriter{writer: csv.NewWriter(w)}, "text/csv", nil
+	default:
+		return nil, "", fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+func optionalObjectIDParam(r *http.Request, name string) (*string, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	if !bson.IsObjectIdHex(value) {
+		return nil, fmt.Errorf("invalid %v", name)
+	}
+	return &value, nil
+}
+
+// ExportHandler streams all recipes matching the filters of the recipes
+// query as json, ndjson or csv, selected by the format parameter.
+func ExportHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if err := checkPermission(ctx, "query.recipeExport"); err != nil {
+			http.Error(w, "No Permission", http.StatusForbidden)
+			return
+		}
+
+		recipeService := ctx.Value("recipeService").(recipe.Service)
+
+		var filters = make(map[string]*string)
+		for _, name := range []string{"inputItemId", "outputItemId", "namespaceId"} {
+			value, err := optionalObjectIDParam(r, name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			filters[name] = value
+		}
+
+		writer, contentType, err := newRecipeWriter(r.URL.Query().Get("format"), w)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		query := recipeService.MakeBaseQuery()
+		AddInputOutputToQuery(query, filters["inputItemId"], filters["outputItemId"])
+		AddNamespaceToQuery(query, filters["namespaceId"])
+
+		w.Header().Set("Content-Type", contentType)
+		flusher, _ := w.(http.Flusher)
+
+		if err := writer.Begin(); err != nil {
+			return
+		}
+
+		count := 0
+		err = recipeService.Iterate(query, func(model *recipe.Model) error {
+			if err := writer.Write(model); err != nil {
+				return err
+			}
+
+			count++
+			if flusher != nil && count%exportFlushInterval == 0 {
+				if csvWriter, ok := writer.(*csvRecipeWriter); ok {
+					csvWriter.writer.Flush()
+				}
+				flusher.Flush()
+			}
+
+			return ctx.Err()
+		})
+
+		if err != nil {
+			// the status is already sent, all we can do is cut the stream short
+			logging.FromContext(ctx).Error("exporting recipes failed", "records", count, "error", err)
+			return
+		}
+
+		writer.End()
+	})
+}
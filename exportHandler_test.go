@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/importer"
+	"github.com/dukfaar/recipeBackend/recipe"
+)
+
+func export(ctx context.Context, query string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/export?"+query, nil).WithContext(ctx)
+	ExportHandler().ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestExportHandler(t *testing.T) {
+	ctx, recipeService := newResolverContext()
+
+	ore, bar, plate := bson.NewObjectId(), bson.NewObjectId(), bson.NewObjectId()
+	namespace := bson.NewObjectId()
+
+	smelt := importedRecipe("smelt", ore, bar, 2)
+	smelt.NamespaceID = &namespace
+	level := int32(5)
+	smelt.CraftingLevel = &level
+	forge := &recipe.Model{
+		Inputs:  []recipe.InputElement{{recipe.InOutElement{ItemID: bar, Amount: 3}}},
+		Outputs: []recipe.OutputElement{{recipe.InOutElement{ItemID: plate, Amount: 1}}},
+	}
+	archivedAt := time.Now()
+	archived := importedRecipe("archived", ore, plate, 1)
+	archived.ArchivedAt = &archivedAt
+	for _, model := range []*recipe.Model{smelt, forge, archived} {
+		if _, err := recipeService.Create(ctx, model); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("json", func(t *testing.T) {
+		response := export(ctx, "")
+		if contentType := response.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Content-Type = %v", contentType)
+		}
+
+		var models []recipe.Model
+		if err := json.Unmarshal(response.Body.Bytes(), &models); err != nil {
+			t.Fatalf("%v in %s", err, response.Body.Bytes())
+		}
+		if len(models) != 2 || models[0].ID != smelt.ID || models[1].ID != forge.ID {
+			t.Errorf("exported %v, want the two unarchived recipes", models)
+		}
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		response := export(ctx, "format=ndjson&inputItemId="+bar.Hex())
+
+		var ids []bson.ObjectId
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			var model recipe.Model
+			if err := json.Unmarshal(scanner.Bytes(), &model); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, model.ID)
+		}
+		if len(ids) != 1 || ids[0] != forge.ID {
+			t.Errorf("exported %v, want only %v", ids, forge.ID)
+		}
+	})
+
+	t.Run("csv can be imported again", func(t *testing.T) {
+		response := export(ctx, "format=csv")
+
+		csvImporter := importer.NewCSVImporter(importer.OpenBytes(response.Body.Bytes()), importer.Options{
+			Source:   "export",
+			Mapping:  importer.DefaultFieldMapping,
+			Resolver: importer.IDItemResolver{},
+		})
+
+		imported := make(map[string]*recipe.Model)
+		err := csvImporter.Import(ctx, func(model *recipe.Model, err error) error {
+			if err != nil {
+				return err
+			}
+			imported[*model.ExternalID] = model
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%v in\n%s", err, response.Body.Bytes())
+		}
+
+		again := imported["smelt"]
+		if again == nil || len(again.Inputs) != 1 || again.Inputs[0].ItemID != ore || again.Inputs[0].Amount != 2 || *again.CraftingLevel != 5 {
+			t.Errorf("imported smelt = %+v, want 2 %v at level 5", again, ore)
+		}
+		// recipes that weren't imported are exported under their own id
+		if again := imported[forge.ID.Hex()]; again == nil || again.Outputs[0].ItemID != plate {
+			t.Errorf("imported forge = %+v, want %v", again, plate)
+		}
+	})
+
+	t.Run("namespace filter", func(t *testing.T) {
+		response := export(ctx, "format=ndjson&namespaceId="+namespace.Hex())
+		if lines := bytes.Count(response.Body.Bytes(), []byte("\n")); lines != 1 {
+			t.Errorf("exported %d recipes, want the one in %v", lines, namespace)
+		}
+	})
+
+	for _, query := range []string{"format=xml", "namespaceId=nope"} {
+		if response := export(ctx, query); response.Code != http.StatusBadRequest {
+			t.Errorf("export?%v = %d, want 400", query, response.Code)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	dukgraphql "github.com/dukfaar/goUtils/graphql"
+	"github.com/dukfaar/recipeBackend/throttle"
+	"github.com/dukfaar/recipeBackend/tracing"
+)
+
+var ErrNotFound = errors.New("not found in gateway")
+
+// DefaultBatchSize is the number of names resolved per gateway request.
+const DefaultBatchSize = 50
+
+// Client wraps the api gateway fetcher with typed lookups. All values are
+// passed as GraphQL variables, never spliced into the query text.
+type Client struct {
+	Fetcher   dukgraphql.Fetcher
+	BatchSize int
+	Limiter   *throttle.Limiter
+}
+
+func NewClient(fetcher dukgraphql.Fetcher) *Client {
+	return &Client{
+		Fetcher:   fetcher,
+		BatchSize: DefaultBatchSize,
+	}
+}
+
+func (c *Client) Query(ctx context.Context, query string, variables map[string]interface{}) (dukgraphql.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return dukgraphql.Response{}, err
+	}
+
+	var response dukgraphql.Response
+	err := c.Limiter.Do(ctx, func() error {
+		result, err := tracing.Fetch(ctx, c.Fetcher, dukgraphql.Request{
+			Query:     query,
+			Variables: variables,
+		})
+		if err != nil {
+			return err
+		}
+
+		response = dukgraphql.Response{result}
+		return nil
+	})
+
+	return response, err
+}
+
+func (c *Client) NamespaceIDByName(ctx context.Context, name string) (string, error) {
+	response, err := c.Query(ctx, "query($name: String!) { namespaceByName(name: $name) { _id name } }", map[string]interface{}{
+		"name": name,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	id := response.GetObject("namespaceByName").GetString("_id")
+	if id == "" {
+		return "", ErrNotFound
+	}
+
+	return id, nil
+}
+
+func (c *Client) ItemIDByName(ctx context.Context, name string, namespaceID string) (string, error) {
+	ids, err := c.ItemIDsByNames(ctx, []string{name}, namespaceID)
+	if err != nil {
+		return "", err
+	}
+
+	id, ok := ids[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return id, nil
+}
+
+// ItemIDsByNames looks up many items with one aliased findItem field per
+// name. Names without an item are missing from the result.
+func (c *Client) ItemIDsByNames(ctx context.Context, names []string, namespaceID string) (map[string]string, error) {
+	result := make(map[string]string, len(names))
+
+	batchSize := c.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	for start := 0; start < len(names); start += batchSize {
+		end := start + batchSize
+		if end > len(names) {
+			end = len(names)
+		}
+
+		if err := c.itemIDsByNamesBatch(ctx, names[start:end], namespaceID, result); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+func (c *Client) itemIDsByNamesBatch(ctx context.Context, names []string, namespaceID string, result map[string]string) error {
+	var (
+		declarations = []string{"$namespaceId: ID!"}
+		fields       = make([]string, len(names))
+		variables    = map[string]interface{}{"namespaceId": namespaceID}
+	)
+
+	for i, name := range names {
+		variable := fmt.Sprintf("n%d", i)
+		declarations = append(declarations, "$"+variable+": String!")
+		fields[i] = fmt.Sprintf("i%d: findItem(name: $%s, namespaceId: $namespaceId) { _id }", i, variable)
+		variables[variable] = name
+	}
+
+	query := "query(" + strings.Join(declarations, ", ") + ") { " + strings.Join(fields, " ") + " }"
+
+	response, err := c.Query(ctx, query, variables)
+	if err != nil {
+		return err
+	}
+
+	for i, name := range names {
+		if id := response.GetObject(fmt.Sprintf("i%d", i)).GetString("_id"); id != "" {
+			result[name] = id
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	dukgraphql "github.com/dukfaar/goUtils/graphql"
+)
+
+// httpFetcher posts requests to the test server like the gateway fetcher.
+type httpFetcher struct {
+	url string
+}
+
+func (f httpFetcher) Fetch(request dukgraphql.Request) (interface{}, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := http.Post(f.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var result interface{}
+	err = json.NewDecoder(response.Body).Decode(&result)
+	return result, err
+}
+
+// fakeGateway answers findItem fields for the items it knows, except for the
+// aliases in drop, and records every request.
+type fakeGateway struct {
+	items map[string]string
+	drop  map[string]bool
+
+	mutex    sync.Mutex
+	requests []dukgraphql.Request
+}
+
+func (g *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request dukgraphql.Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	g.mutex.Lock()
+	g.requests = append(g.requests, request)
+	g.mutex.Unlock()
+
+	data := make(map[string]interface{})
+	for i := 0; ; i++ {
+		name, ok := request.Variables[fmt.Sprintf("n%d", i)].(string)
+		if !ok {
+			break
+		}
+
+		alias := fmt.Sprintf("i%d", i)
+		if g.drop[alias] {
+			continue
+		}
+		if id, ok := g.items[name]; ok {
+			data[alias] = map[string]interface{}{"_id": id}
+		} else {
+			data[alias] = nil
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func newTestClient(gateway *fakeGateway) (*Client, func()) {
+	server := httptest.NewServer(gateway)
+	return NewClient(httpFetcher{url: server.URL}), server.Close
+}
+
+func TestItemIDsByNamesPassesNamesAsVariables(t *testing.T) {
+	names := []string{
+		`Iron "Ingot"`,
+		`x") { _id } evil: deleteItem(id: "1`,
+		"Back\\slash $namespaceId",
+	}
+	gateway := &fakeGateway{items: map[string]string{names[0]: "1", names[1]: "2", names[2]: "3"}}
+	client, closeServer := newTestClient(gateway)
+	defer closeServer()
+
+	ids, err := client.ItemIDsByNames(context.Background(), names, "ns")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, name := range names {
+		if want := fmt.Sprint(i + 1); ids[name] != want {
+			t.Errorf("id of %q = %q, want %q", name, ids[name], want)
+		}
+	}
+
+	if len(gateway.requests) != 1 {
+		t.Fatalf("sent %d requests, want 1", len(gateway.requests))
+	}
+	request := gateway.requests[0]
+	for i, name := range names {
+		if strings.Contains(request.Query, name) {
+			t.Errorf("query contains the name %q: %v", name, request.Query)
+		}
+		if got := request.Variables[fmt.Sprintf("n%d", i)]; got != name {
+			t.Errorf("variable n%d = %q, want %q", i, got, name)
+		}
+	}
+	if strings.Contains(request.Query, "deleteItem") {
+		t.Errorf("name was spliced into the query: %v", request.Query)
+	}
+	if request.Variables["namespaceId"] != "ns" {
+		t.Errorf("namespaceId = %v, want ns", request.Variables["namespaceId"])
+	}
+}
+
+func TestItemIDsByNamesBatches(t *testing.T) {
+	tests := []struct {
+		names int
+		want  []int
+	}{
+		{1, []int{1}},
+		{DefaultBatchSize, []int{DefaultBatchSize}},
+		{DefaultBatchSize + 1, []int{DefaultBatchSize, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.names), func(t *testing.T) {
+			gateway := &fakeGateway{items: make(map[string]string)}
+			names := make([]string, test.names)
+			for i := range names {
+				names[i] = fmt.Sprintf("item %d", i)
+				gateway.items[names[i]] = fmt.Sprintf("id %d", i)
+			}
+
+			client, closeServer := newTestClient(gateway)
+			defer closeServer()
+
+			ids, err := client.ItemIDsByNames(context.Background(), names, "ns")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ids) != test.names {
+				t.Errorf("resolved %d names, want %d", len(ids), test.names)
+			}
+			for _, name := range names {
+				if ids[name] != gateway.items[name] {
+					t.Errorf("id of %q = %q, want %q", name, ids[name], gateway.items[name])
+				}
+			}
+
+			if len(gateway.requests) != len(test.want) {
+				t.Fatalf("sent %d requests, want %d", len(gateway.requests), len(test.want))
+			}
+			for i, request := range gateway.requests {
+				if fields := strings.Count(request.Query, "findItem("); fields != test.want[i] {
+					t.Errorf("request %d has %d findItem fields, want %d", i, fields, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestItemIDsByNamesMissingItems(t *testing.T) {
+	gateway := &fakeGateway{
+		items: map[string]string{"a": "1", "b": "2", "c": "3"},
+		// the answer to b lacks its alias entirely
+		drop: map[string]bool{"i1": true},
+	}
+	client, closeServer := newTestClient(gateway)
+	defer closeServer()
+
+	ids, err := client.ItemIDsByNames(context.Background(), []string{"a", "b", "c", "unknown"}, "ns")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"a": "1", "c": "3"}
+	if len(ids) != len(want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+	for name, id := range want {
+		if ids[name] != id {
+			t.Errorf("id of %q = %q, want %q", name, ids[name], id)
+		}
+	}
+
+	if _, err := client.ItemIDByName(context.Background(), "unknown", "ns"); err != ErrNotFound {
+		t.Errorf("ItemIDByName of an unknown item = %v, want ErrNotFound", err)
+	}
+}
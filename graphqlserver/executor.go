@@ -0,0 +1,117 @@
+package graphqlserver
+
+import (
+	"context"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/errors"
+)
+
+// Request is a GraphQL request, sent over http or the socket.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    map[string]interface{} `json:"extensions"`
+}
+
+// Check runs before a request is executed. It may complete the request, an
+// error rejects it.
+type Check func(ctx context.Context, request *Request) error
+
+// Hook runs after a request passed all checks and ran without errors.
+type Hook func(ctx context.Context, request *Request)
+
+// ExtendedError is an error that adds extensions to the GraphQL error it
+// is reported as.
+type ExtendedError interface {
+	error
+	Extensions() map[string]interface{}
+}
+
+// RetryableError is an error of a request that succeeds when it is sent
+// again after RetryAfter, like one exceeding a rate limit.
+type RetryableError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// Executor runs requests on Schema once they passed all Checks. It is shared
+// by the http and the socket handler, so both apply the same checks.
+type Executor struct {
+	Schema *graphql.Schema
+	Checks []Check
+	// Executed are the hooks of requests that ran without errors,
+	// subscriptions run them once their first response has no errors
+	Executed []Hook
+}
+
+// QueryError converts err to the GraphQL error it is reported as.
+func QueryError(err error) *errors.QueryError {
+	queryError := &errors.QueryError{Message: err.Error()}
+	if extended, ok := err.(ExtendedError); ok {
+		queryError.Extensions = extended.Extensions()
+	}
+	return queryError
+}
+
+func (e *Executor) check(ctx context.Context, request *Request) error {
+	for _, check := range e.Checks {
+		if err := check(ctx, request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Exec runs a query or mutation. A rejected request is returned as err, so
+// the transport can report it, together with the response to send.
+func (e *Executor) Exec(ctx context.Context, request *Request) (response *graphql.Response, err error) {
+	if err := e.check(ctx, request); err != nil {
+		return &graphql.Response{Errors: []*errors.QueryError{QueryError(err)}}, err
+	}
+
+	response = e.Schema.Exec(ctx, request.Query, request.OperationName, request.Variables)
+	if len(response.Errors) == 0 {
+		e.executed(ctx, request)
+	}
+	return response, nil
+}
+
+func (e *Executor) executed(ctx context.Context, request *Request) {
+	for _, hook := range e.Executed {
+		hook(ctx, request)
+	}
+}
+
+// Subscribe runs any operation, subscriptions send a response per event
+// until ctx is done.
+func (e *Executor) Subscribe(ctx context.Context, request *Request) (<-chan interface{}, error) {
+	if err := e.check(ctx, request); err != nil {
+		return nil, err
+	}
+
+	responses, err := e.Schema.Subscribe(ctx, request.Query, request.OperationName, request.Variables)
+	if err != nil || len(e.Executed) == 0 {
+		return responses, err
+	}
+
+	// invalid operations are reported as their only response
+	forwarded := make(chan interface{})
+	go func() {
+		defer close(forwarded)
+
+		first := true
+		for response := range responses {
+			if first {
+				first = false
+				if r, ok := response.(*graphql.Response); ok && len(r.Errors) == 0 {
+					e.executed(ctx, request)
+				}
+			}
+			forwarded <- response
+		}
+	}()
+	return forwarded, nil
+}
@@ -0,0 +1,49 @@
+package graphqlserver
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/errors"
+)
+
+// Handler serves GraphQL requests posted as json, like the relay handler of
+// the graphql library, but runs them through the Executor.
+type Handler struct {
+	Executor *Executor
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.Executor.Exec(r.Context(), &request)
+	writeResponse(w, response, err)
+}
+
+// WriteError sends err as the only GraphQL error of a response. Requests
+// that may be retried later get status 429 and a Retry-After header.
+func WriteError(w http.ResponseWriter, err error) {
+	writeResponse(w, &graphql.Response{Errors: []*errors.QueryError{QueryError(err)}}, err)
+}
+
+func writeResponse(w http.ResponseWriter, response *graphql.Response, err error) {
+	responseJSON, marshalErr := json.Marshal(response)
+	if marshalErr != nil {
+		http.Error(w, marshalErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if retryable, ok := err.(RetryableError); ok && retryable.RetryAfter() > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryable.RetryAfter().Seconds()))))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	w.Write(responseJSON)
+}
@@ -0,0 +1,107 @@
+package graphqlserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+const testSchema = `
+schema {
+	query: Query
+	subscription: Subscription
+}
+
+type Query {
+	hello: String!
+}
+
+type Subscription {
+	ticks: Int!
+}
+`
+
+type testResolver struct{}
+
+func (r *testResolver) Hello() string {
+	return "world"
+}
+
+func (r *testResolver) Ticks(ctx context.Context) <-chan int32 {
+	ticks := make(chan int32)
+	go func() {
+		defer close(ticks)
+		for tick := int32(1); ; tick++ {
+			select {
+			case <-ctx.Done():
+				return
+			case ticks <- tick:
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	return ticks
+}
+
+type retryLater struct{}
+
+func (retryLater) Error() string             { return "slow down" }
+func (retryLater) RetryAfter() time.Duration { return 2 * time.Second }
+
+// rejectQuery is a check rejecting every query containing "reject".
+func rejectQuery(ctx context.Context, request *Request) error {
+	if strings.Contains(request.Query, "reject") {
+		return retryLater{}
+	}
+	return nil
+}
+
+func newTestExecutor() *Executor {
+	return &Executor{
+		Schema: graphql.MustParseSchema(testSchema, &testResolver{}),
+		Checks: []Check{rejectQuery},
+	}
+}
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(&Handler{Executor: newTestExecutor()})
+	defer server.Close()
+
+	post := func(query string) (*http.Response, map[string]interface{}) {
+		body, _ := json.Marshal(Request{Query: query})
+		response, err := http.Post(server.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+
+		var result map[string]interface{}
+		if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return response, result
+	}
+
+	response, result := post(`{ hello }`)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("status = %d", response.StatusCode)
+	}
+	if data, _ := result["data"].(map[string]interface{}); data["hello"] != "world" {
+		t.Errorf("result = %v", result)
+	}
+
+	response, result = post(`{ reject: hello }`)
+	if response.StatusCode != http.StatusTooManyRequests || response.Header.Get("Retry-After") != "2" {
+		t.Errorf("status = %d, Retry-After = %q, want 429 and 2", response.StatusCode, response.Header.Get("Retry-After"))
+	}
+	if errs, _ := result["errors"].([]interface{}); len(errs) != 1 {
+		t.Errorf("result = %v, want the rejection", result)
+	}
+}
@@ -0,0 +1,426 @@
+package graphqlserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/errors"
+)
+
+// Subprotocol is the websocket protocol spoken by SocketHandler, the one of
+// the apollo subscriptions transport.
+const Subprotocol = "graphql-ws"
+
+const (
+	messageConnectionInit      = "connection_init"
+	messageConnectionAck       = "connection_ack"
+	messageConnectionError     = "connection_error"
+	messageConnectionTerminate = "connection_terminate"
+	messageStart               = "start"
+	messageStop                = "stop"
+	messageData                = "data"
+	messageError               = "error"
+	messageComplete            = "complete"
+	messageKeepAlive           = "ka"
+)
+
+type socketMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// SocketHandler serves queries, mutations and subscriptions over a
+// websocket, running them through the Executor.
+type SocketHandler struct {
+	Executor *Executor
+	Upgrader websocket.Upgrader
+
+	// Authenticate is called with the payload of connection_init and returns
+	// the context the operations of the connection run in. An error refuses
+	// the connection.
+	Authenticate func(ctx context.Context, r *http.Request, payload json.RawMessage) (context.Context, error)
+	// Admit is called with the authenticated context before the connection
+	// is acknowledged, like to count it against a rate limit. An error
+	// refuses the connection.
+	Admit func(ctx context.Context) error
+	// MaxConnections limits the open connections per ConnectionKey, 0 disables the limit
+	ConnectionKey  func(ctx context.Context) string
+	MaxConnections int
+	// InitTimeout is how long clients have to send connection_init
+	InitTimeout time.Duration
+	// KeepAlive is the interval of keepalive messages and pings, connections
+	// that send nothing, not even a pong, for IdleTimeout are closed
+	KeepAlive   time.Duration
+	IdleTimeout time.Duration
+
+	mutex       sync.Mutex
+	connections map[string]int
+	sockets     map[*websocket.Conn]struct{}
+}
+
+// AllowOrigins returns a CheckOrigin for the Upgrader that accepts requests
+// from origins, or from any origin if it contains "*". Like the default of
+// the Upgrader it accepts requests without an origin or from the host of the
+// request.
+func AllowOrigins(origins []string) func(r *http.Request) bool {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || allowed["*"] || allowed[strings.ToLower(origin)] {
+			return true
+		}
+
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
+
+func (h *SocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrader := h.Upgrader
+	if len(upgrader.Subprotocols) == 0 {
+		upgrader.Subprotocols = []string{Subprotocol}
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already replied with an error
+		return
+	}
+	h.track(conn)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	connection := &socketConnection{
+		handler:    h,
+		request:    r,
+		ctx:        ctx,
+		cancel:     cancel,
+		conn:       conn,
+		executor:   h.Executor,
+		operations: make(map[string]*operation),
+	}
+
+	connection.serve()
+
+	cancel()
+	connection.waitGroup.Wait()
+	if connection.counted {
+		h.release(connection.key)
+	}
+	h.untrack(conn)
+	conn.Close()
+}
+
+// track remembers an open websocket, http.Server.Shutdown doesn't know about
+// hijacked connections anymore.
+func (h *SocketHandler) track(conn *websocket.Conn) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.sockets == nil {
+		h.sockets = make(map[*websocket.Conn]struct{})
+	}
+	h.sockets[conn] = struct{}{}
+}
+
+func (h *SocketHandler) untrack(conn *websocket.Conn) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.sockets, conn)
+}
+
+// Count returns the number of open websockets.
+func (h *SocketHandler) Count() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return len(h.sockets)
+}
+
+// CloseAll closes all open websockets, their operations are cancelled.
+func (h *SocketHandler) CloseAll() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for conn := range h.sockets {
+		conn.Close()
+		delete(h.sockets, conn)
+	}
+}
+
+// acquire counts a connection of key, unless key has MaxConnections open.
+func (h *SocketHandler) acquire(key string) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.connections == nil {
+		h.connections = make(map[string]int)
+	}
+	if h.connections[key] >= h.MaxConnections {
+		return false
+	}
+	h.connections[key]++
+	return true
+}
+
+func (h *SocketHandler) release(key string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.connections[key]--; h.connections[key] <= 0 {
+		delete(h.connections, key)
+	}
+}
+
+type operation struct {
+	cancel context.CancelFunc
+}
+
+type socketConnection struct {
+	handler *SocketHandler
+	request *http.Request
+
+	// ctx is replaced by the authenticated context on connection_init
+	ctx         context.Context
+	cancel      context.CancelFunc
+	conn        *websocket.Conn
+	executor    *Executor
+	initialized bool
+	counted     bool
+	key         string
+
+	writeMutex sync.Mutex
+
+	mutex      sync.Mutex
+	operations map[string]*operation
+	waitGroup  sync.WaitGroup
+}
+
+func (c *socketConnection) write(message socketMessage) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	return c.conn.WriteJSON(message)
+}
+
+func (c *socketConnection) writePayload(id string, messageType string, payload interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return c.write(socketMessage{ID: id, Type: messageType, Payload: payloadJSON})
+}
+
+// serve reads messages until the connection is closed or terminated.
+func (c *socketConnection) serve() {
+	if c.handler.InitTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.handler.InitTimeout))
+	}
+
+	for {
+		var message socketMessage
+		if err := c.conn.ReadJSON(&message); err != nil {
+			return
+		}
+
+		if c.initialized {
+			c.extendDeadline()
+		}
+
+		switch message.Type {
+		case messageConnectionInit:
+			if c.initialized {
+				c.writePayload(message.ID, messageConnectionError, errors.Errorf("connection is already initialized"))
+				continue
+			}
+			if err := c.init(message.Payload); err != nil {
+				c.refuse(err)
+				return
+			}
+
+		case messageStart:
+			if !c.initialized {
+				c.writePayload(message.ID, messageError, errors.Errorf("connection_init is required before start"))
+				continue
+			}
+			c.start(message.ID, message.Payload)
+
+		case messageStop:
+			c.stop(message.ID)
+
+		case messageConnectionTerminate:
+			return
+
+		default:
+			c.writePayload(message.ID, messageConnectionError, errors.Errorf("unknown message type %q", message.Type))
+		}
+	}
+}
+
+// init authenticates and admits the connection and counts it against the
+// connection limit of its user before acknowledging it.
+func (c *socketConnection) init(payload json.RawMessage) error {
+	ctx := c.ctx
+	if c.handler.Authenticate != nil {
+		authenticated, err := c.handler.Authenticate(ctx, c.request, payload)
+		if err != nil {
+			return err
+		}
+		ctx = authenticated
+	}
+
+	if c.handler.Admit != nil {
+		if err := c.handler.Admit(ctx); err != nil {
+			return err
+		}
+	}
+
+	if c.handler.MaxConnections > 0 && c.handler.ConnectionKey != nil {
+		key := c.handler.ConnectionKey(ctx)
+		if !c.handler.acquire(key) {
+			return fmt.Errorf("too many open connections, at most %d are allowed", c.handler.MaxConnections)
+		}
+		c.key = key
+		c.counted = true
+	}
+
+	c.ctx = ctx
+	c.initialized = true
+
+	if err := c.write(socketMessage{Type: messageConnectionAck}); err != nil {
+		return err
+	}
+
+	c.conn.SetReadDeadline(time.Time{})
+	c.extendDeadline()
+	if c.handler.KeepAlive > 0 {
+		c.conn.SetPongHandler(func(string) error {
+			c.extendDeadline()
+			return nil
+		})
+
+		c.write(socketMessage{Type: messageKeepAlive})
+
+		c.waitGroup.Add(1)
+		go c.keepAlive()
+	}
+
+	return nil
+}
+
+// refuse reports why the connection isn't accepted and closes it.
+func (c *socketConnection) refuse(err error) {
+	c.writePayload("", messageConnectionError, QueryError(err))
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()))
+}
+
+func (c *socketConnection) extendDeadline() {
+	if c.handler.IdleTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.handler.IdleTimeout))
+	}
+}
+
+// keepAlive sends keepalive messages for apollo clients and pings, whose
+// pongs keep the connection from timing out, until the connection is closed.
+func (c *socketConnection) keepAlive() {
+	defer c.waitGroup.Done()
+
+	ticker := time.NewTicker(c.handler.KeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.write(socketMessage{Type: messageKeepAlive}); err != nil {
+				c.cancel()
+				return
+			}
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.handler.KeepAlive)); err != nil {
+				c.cancel()
+				return
+			}
+		}
+	}
+}
+
+func (c *socketConnection) start(id string, payload json.RawMessage) {
+	var request Request
+	if err := json.Unmarshal(payload, &request); err != nil {
+		c.writePayload(id, messageError, errors.Errorf("invalid request: %v", err))
+		return
+	}
+
+	c.mutex.Lock()
+	if _, ok := c.operations[id]; ok {
+		c.mutex.Unlock()
+		c.writePayload(id, messageError, errors.Errorf("operation %q is already running", id))
+		return
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	current := &operation{cancel: cancel}
+	c.operations[id] = current
+	c.mutex.Unlock()
+
+	c.waitGroup.Add(1)
+	go func() {
+		defer c.waitGroup.Done()
+		defer c.remove(id, current)
+
+		responses, err := c.executor.Subscribe(ctx, &request)
+		if err != nil {
+			// rejected requests look like on http
+			c.writePayload(id, messageData, &graphql.Response{Errors: []*errors.QueryError{QueryError(err)}})
+			c.write(socketMessage{ID: id, Type: messageComplete})
+			return
+		}
+
+		for response := range responses {
+			if err := c.writePayload(id, messageData, response); err != nil {
+				cancel()
+			}
+		}
+
+		c.write(socketMessage{ID: id, Type: messageComplete})
+	}()
+}
+
+func (c *socketConnection) stop(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if current, ok := c.operations[id]; ok {
+		current.cancel()
+		delete(c.operations, id)
+	}
+}
+
+// remove forgets a finished operation, unless its id was reused since.
+func (c *socketConnection) remove(id string, finished *operation) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	finished.cancel()
+	if c.operations[id] == finished {
+		delete(c.operations, id)
+	}
+}
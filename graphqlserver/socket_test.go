@@ -0,0 +1,242 @@
+package graphqlserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type testSocket struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func dialSocket(t *testing.T, server *httptest.Server) *testSocket {
+	dialer := websocket.Dialer{Subprotocols: []string{Subprotocol}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testSocket{t: t, conn: conn}
+}
+
+func (s *testSocket) send(id string, messageType string, payload interface{}) {
+	s.t.Helper()
+
+	message := socketMessage{ID: id, Type: messageType}
+	if payload != nil {
+		message.Payload, _ = json.Marshal(payload)
+	}
+	if err := s.conn.WriteJSON(message); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func (s *testSocket) receive() socketMessage {
+	s.t.Helper()
+
+	s.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var message socketMessage
+	if err := s.conn.ReadJSON(&message); err != nil {
+		s.t.Fatal(err)
+	}
+	return message
+}
+
+func (s *testSocket) expect(messageType string) socketMessage {
+	s.t.Helper()
+
+	message := s.receive()
+	if message.Type != messageType {
+		s.t.Fatalf("got %v %s, want %v", message.Type, message.Payload, messageType)
+	}
+	return message
+}
+
+func TestSocketHandler(t *testing.T) {
+	handler := &SocketHandler{Executor: newTestExecutor()}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	socket := dialSocket(t, server)
+	defer socket.conn.Close()
+
+	socket.send("1", messageStart, Request{Query: `{ hello }`})
+	socket.expect(messageError)
+
+	socket.send("", messageConnectionInit, map[string]interface{}{})
+	socket.expect(messageConnectionAck)
+
+	// queries send their response and complete
+	socket.send("1", messageStart, Request{Query: `{ hello }`})
+	data := socket.expect(messageData)
+	if !strings.Contains(string(data.Payload), `"hello":"world"`) || data.ID != "1" {
+		t.Errorf("data = %s for %q", data.Payload, data.ID)
+	}
+	socket.expect(messageComplete)
+
+	// rejected operations look like on http
+	socket.send("2", messageStart, Request{Query: `{ reject: hello }`})
+	data = socket.expect(messageData)
+	if !strings.Contains(string(data.Payload), "slow down") {
+		t.Errorf("data = %s, want the rejection", data.Payload)
+	}
+	socket.expect(messageComplete)
+
+	// subscriptions run until they are stopped
+	socket.send("3", messageStart, Request{Query: `subscription { ticks }`})
+	for i := 0; i < 3; i++ {
+		if data := socket.expect(messageData); data.ID != "3" {
+			t.Fatalf("data for %q", data.ID)
+		}
+	}
+	socket.send("3", messageStop, nil)
+	for {
+		message := socket.receive()
+		if message.Type == messageComplete && message.ID == "3" {
+			break
+		}
+		if message.Type != messageData {
+			t.Fatalf("got %v while stopping", message.Type)
+		}
+	}
+
+	if handler.Count() != 1 {
+		t.Errorf("%d open sockets, want 1", handler.Count())
+	}
+
+	socket.send("", messageConnectionTerminate, nil)
+	deadline := time.Now().Add(5 * time.Second)
+	for handler.Count() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if handler.Count() != 0 {
+		t.Errorf("terminated socket is still tracked")
+	}
+}
+
+func TestSocketHandlerRefusesConnections(t *testing.T) {
+	handler := &SocketHandler{
+		Executor: newTestExecutor(),
+		Authenticate: func(ctx context.Context, r *http.Request, payload json.RawMessage) (context.Context, error) {
+			if strings.Contains(string(payload), "invalid") {
+				return nil, errors.New("invalid token")
+			}
+			return ctx, nil
+		},
+		ConnectionKey:  func(ctx context.Context) string { return "user" },
+		MaxConnections: 1,
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	refused := dialSocket(t, server)
+	defer refused.conn.Close()
+	refused.send("", messageConnectionInit, map[string]string{"authToken": "invalid"})
+	refused.expect(messageConnectionError)
+
+	first := dialSocket(t, server)
+	defer first.conn.Close()
+	first.send("", messageConnectionInit, map[string]string{"authToken": "valid"})
+	first.expect(messageConnectionAck)
+
+	second := dialSocket(t, server)
+	defer second.conn.Close()
+	second.send("", messageConnectionInit, map[string]string{"authToken": "valid"})
+	if message := second.expect(messageConnectionError); !strings.Contains(string(message.Payload), "too many open connections") {
+		t.Errorf("payload = %s", message.Payload)
+	}
+
+	// closing the first connection frees its slot
+	first.conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		third := dialSocket(t, server)
+		third.send("", messageConnectionInit, map[string]string{"authToken": "valid"})
+		message := third.receive()
+		third.conn.Close()
+		if message.Type == messageConnectionAck {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the slot of the closed connection wasn't freed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSocketHandlerCloseAll(t *testing.T) {
+	handler := &SocketHandler{Executor: newTestExecutor()}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	socket := dialSocket(t, server)
+	defer socket.conn.Close()
+	socket.send("", messageConnectionInit, nil)
+	socket.expect(messageConnectionAck)
+	socket.send("1", messageStart, Request{Query: `subscription { ticks }`})
+	socket.expect(messageData)
+
+	handler.CloseAll()
+
+	socket.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var message socketMessage
+		if err := socket.conn.ReadJSON(&message); err != nil {
+			break
+		}
+	}
+	if handler.Count() != 0 {
+		t.Errorf("%d sockets still tracked", handler.Count())
+	}
+}
+
+type userKey struct{}
+
+func TestSocketHandlerAdmitsAuthenticatedConnections(t *testing.T) {
+	var mutex sync.Mutex
+	var admitted []string
+	handler := &SocketHandler{
+		Executor: newTestExecutor(),
+		Authenticate: func(ctx context.Context, r *http.Request, payload json.RawMessage) (context.Context, error) {
+			return context.WithValue(ctx, userKey{}, "user:1"), nil
+		},
+		Admit: func(ctx context.Context) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			user, _ := ctx.Value(userKey{}).(string)
+			admitted = append(admitted, user)
+			if len(admitted) > 1 {
+				return errors.New("too many connections")
+			}
+			return nil
+		},
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	first := dialSocket(t, server)
+	defer first.conn.Close()
+	first.send("", messageConnectionInit, nil)
+	first.expect(messageConnectionAck)
+
+	second := dialSocket(t, server)
+	defer second.conn.Close()
+	second.send("", messageConnectionInit, nil)
+	second.expect(messageConnectionError)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(admitted) != 2 || admitted[0] != "user:1" || admitted[1] != "user:1" {
+		t.Errorf("admitted %v, want both connections with the authenticated context", admitted)
+	}
+}
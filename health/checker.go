@@ -0,0 +1,126 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Check reports whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// CheckResult is the outcome of a single Check.
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is returned by the health endpoints.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Checker runs a set of named checks concurrently, each bounded by Timeout.
+type Checker struct {
+	Timeout time.Duration
+
+	checks []namedCheck
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		Timeout: timeout,
+	}
+}
+
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{
+		Status: StatusUp,
+		Checks: make(map[string]CheckResult, len(c.checks)),
+	}
+
+	var mutex sync.Mutex
+	var waitGroup sync.WaitGroup
+	for _, named := range c.checks {
+		waitGroup.Add(1)
+		go func(named namedCheck) {
+			defer waitGroup.Done()
+
+			result := c.run(ctx, named.check)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Checks[named.name] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(named)
+	}
+	waitGroup.Wait()
+
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	// checks like mgo's Ping can't be cancelled, so don't wait for them longer than the timeout
+	errs := make(chan error, 1)
+	go func() {
+		errs <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{
+		Status:   StatusUp,
+		Duration: time.Since(start).String(),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// Handler serves the report of checker, with 503 if any check is down.
+func Handler(checker *Checker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := checker.Run(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Status != StatusUp {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,124 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	notLoaded := NewFlag(errors.New("permissions not loaded"))
+
+	checker := NewChecker(50 * time.Millisecond)
+	checker.Add("permissions", notLoaded.Check)
+	checker.Add("mongo", func(ctx context.Context) error { return nil })
+	checker.Add("stuck", func(ctx context.Context) error {
+		// like mgo's Ping, this ignores ctx
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	serve := func() (int, Report) {
+		recorder := httptest.NewRecorder()
+		Handler(checker).ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+
+		var report Report
+		if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		if recorder.Header().Get("Cache-Control") != "no-store" {
+			t.Error("health reports may be cached")
+		}
+		return recorder.Code, report
+	}
+
+	start := time.Now()
+	code, report := serve()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("handler waited %v for a stuck check", elapsed)
+	}
+	if code != http.StatusServiceUnavailable || report.Status != StatusDown {
+		t.Errorf("got %d %v, want 503 down", code, report.Status)
+	}
+	if result := report.Checks["permissions"]; result.Status != StatusDown || result.Error != "permissions not loaded" {
+		t.Errorf("permissions = %+v", result)
+	}
+	if result := report.Checks["stuck"]; result.Status != StatusDown || result.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("stuck = %+v, want a timeout", result)
+	}
+	if result := report.Checks["mongo"]; result.Status != StatusUp {
+		t.Errorf("mongo = %+v", result)
+	}
+
+	healthy := NewChecker(50 * time.Millisecond)
+	healthy.Add("permissions", notLoaded.Check)
+	notLoaded.Set(nil)
+	checker = healthy
+
+	code, report = serve()
+	if code != http.StatusOK || report.Status != StatusUp {
+		t.Errorf("got %d %v after the flag was set, want 200 up", code, report.Status)
+	}
+}
+
+func TestEmptyCheckerIsUp(t *testing.T) {
+	if report := NewChecker(time.Second).Run(context.Background()); report.Status != StatusUp {
+		t.Errorf("liveness without checks is %v", report.Status)
+	}
+}
+
+func TestTCPCheck(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+
+	if err := TCPCheck(addr)(context.Background()); err != nil {
+		t.Errorf("TCPCheck of a listening port = %v", err)
+	}
+
+	listener.Close()
+	if err := TCPCheck(addr)(context.Background()); err == nil {
+		t.Error("TCPCheck of a closed port succeeded")
+	}
+}
+
+func TestNsqLookupCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr bool
+	}{
+		{"ok", http.StatusOK, "OK\n", false},
+		{"unhealthy", http.StatusInternalServerError, "NOK", true},
+		{"other server", http.StatusOK, "<html>", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/ping" {
+					http.NotFound(w, r)
+					return
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			// nsqlookupd addresses are configured without scheme
+			err := NsqLookupCheck(strings.TrimPrefix(server.URL, "http://"))(context.Background())
+			if (err != nil) != test.wantErr {
+				t.Errorf("NsqLookupCheck = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
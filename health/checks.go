@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/globalsign/mgo"
+)
+
+// Flag is a check for state that is set from elsewhere, like whether startup
+// got far enough to load the permission data.
+type Flag struct {
+	mutex sync.RWMutex
+	err   error
+}
+
+// NewFlag returns a flag that is down with err until it is set.
+func NewFlag(err error) *Flag {
+	return &Flag{
+		err: err,
+	}
+}
+
+func (f *Flag) Set(err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.err = err
+}
+
+func (f *Flag) Check(ctx context.Context) error {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.err
+}
+
+// MongoCheck pings the database on a copy of session, so a broken socket
+// is noticed instead of being reused.
+func MongoCheck(session *mgo.Session) Check {
+	return func(ctx context.Context) error {
+		s := session.Copy()
+		defer s.Close()
+
+		return s.Ping()
+	}
+}
+
+// TCPCheck dials addr, which is all we can check about nsqd without
+// speaking its protocol.
+func TCPCheck(addr string) Check {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// NsqLookupCheck calls the /ping endpoint of nsqlookupd at addr (host:port).
+func NsqLookupCheck(addr string) Check {
+	return func(ctx context.Context) error {
+		url := addr
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			url = "http://" + url
+		}
+
+		req, err := http.NewRequest("GET", strings.TrimSuffix(url, "/")+"/ping", nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64))
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("nsqlookupd responded with %v", resp.Status)
+		}
+		if strings.TrimSpace(string(body)) != "OK" {
+			return errors.New("unexpected nsqlookupd ping response")
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,45 @@
+package health
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/url"
+
+	dukGraphql "github.com/dukfaar/goUtils/graphql"
+)
+
+// ObservedFetcher records in Flag whether the last fetch reached the
+// gateway. Wrapped around the login fetcher it tells whether we are still
+// able to log in at the gateway, without sending requests just for the
+// health check.
+type ObservedFetcher struct {
+	Fetcher dukGraphql.Fetcher
+	Flag    *Flag
+}
+
+func (f *ObservedFetcher) Fetch(request dukGraphql.Request) (interface{}, error) {
+	result, err := f.Fetcher.Fetch(request)
+	if isTransportError(err) {
+		f.Flag.Set(err)
+	} else {
+		// GraphQL errors, like an unknown item, are answers of a working gateway
+		f.Flag.Set(nil)
+	}
+	return result, err
+}
+
+// isTransportError tells whether err means the gateway couldn't be reached
+// or its response got lost.
+func isTransportError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var urlError *url.Error
+	var netError net.Error
+	return errors.As(err, &urlError) ||
+		errors.As(err, &netError) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
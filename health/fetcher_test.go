@@ -0,0 +1,50 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"testing"
+
+	dukGraphql "github.com/dukfaar/goUtils/graphql"
+)
+
+type fetcherFunc func(request dukGraphql.Request) (interface{}, error)
+
+func (f fetcherFunc) Fetch(request dukGraphql.Request) (interface{}, error) {
+	return f(request)
+}
+
+func TestObservedFetcherFlagsTransportErrors(t *testing.T) {
+	refused := &url.Error{Op: "Post", URL: "http://gateway/graphql", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+
+	tests := []struct {
+		name     string
+		err      error
+		wantDown bool
+	}{
+		{"success", nil, false},
+		{"connection refused", refused, true},
+		{"truncated response", io.ErrUnexpectedEOF, true},
+		{"graphql error", errors.New("item not found"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flag := NewFlag(errors.New("not fetched yet"))
+			fetcher := &ObservedFetcher{
+				Fetcher: fetcherFunc(func(dukGraphql.Request) (interface{}, error) { return nil, test.err }),
+				Flag:    flag,
+			}
+
+			if _, err := fetcher.Fetch(dukGraphql.Request{}); err != test.err {
+				t.Errorf("err = %v, want %v", err, test.err)
+			}
+			if down := flag.Check(context.Background()) != nil; down != test.wantDown {
+				t.Errorf("flag down = %v, want %v", down, test.wantDown)
+			}
+		})
+	}
+}
@@ -1,29 +1,66 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 
-	dukgraphql "github.com/dukfaar/goUtils/graphql"
+	"github.com/dukfaar/recipeBackend/importer"
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/metrics"
 	"github.com/dukfaar/recipeBackend/recipe"
 )
 
-func CreateRCEventImporter(recipeService recipe.Service, fetcher dukgraphql.Fetcher) func(msg []byte) error {
-	return func(msg []byte) error {
-		var recipeData map[string]interface{}
-		err := json.Unmarshal(msg, &recipeData)
+// CreateImportEventHandler stores recipes converted by an importer and counts
+// them on their import job. Recipes that were imported from the same source
+// before are updated.
+func CreateImportEventHandler(recipeService recipe.Service, jobService importer.JobService) EventHandler {
+	return func(ctx context.Context, msg []byte) error {
+		logger := logging.FromContext(ctx)
+		recipeService := recipe.WithContext(ctx, recipeService)
+
+		var event importer.RecordEvent
+		err := json.Unmarshal(msg, &event)
 
 		if err != nil {
-			fmt.Printf("Error(%v) unmarshaling event data: %v\n", err, string(msg))
+			logger.Error("unmarshaling import event failed", "message", string(msg), "error", err)
 			return err
 		}
 
-		fmt.Printf("%+v\n", recipeData)
+		job, err := jobService.FindByID(event.JobID.Hex())
+		if err != nil {
+			logger.Error("finding import job failed", "jobId", event.JobID.Hex(), "error", err)
+			return nil
+		}
+		if job.Done() {
+			return nil
+		}
+
+		model := event.Recipe
+		if model == nil || model.ImportSource == nil || model.ExternalID == nil {
+			metrics.ObserveImportRecord(job.Source, "failed", nil)
+			_, err := jobService.AddError(job.ID, event.Record, importer.JobError{Message: "imported recipe without source"})
+			return err
+		}
+
+		outcome, existing, _, err := importer.Compare(recipeService, model)
+		switch {
+		case err != nil:
+		case outcome == importer.OutcomeCreated:
+			_, err = recipeService.Create(ctx, model)
+		case outcome == importer.OutcomeUpdated:
+			_, err = recipeService.Update(ctx, existing.ID.Hex(), model)
+		}
+
+		if err != nil {
+			logger.Error("storing imported recipe failed", "source", *model.ImportSource, "externalId", *model.ExternalID, "error", err)
+			metrics.ObserveImportRecord(job.Source, "failed", model.NamespaceID)
+			_, err = jobService.AddError(job.ID, event.Record, importer.JobError{ExternalID: *model.ExternalID, Message: err.Error()})
+			return err
+		}
 
-		//check if recipe exists or not
-		//if it does: update
-		//else create
+		metrics.ObserveImportRecord(job.Source, outcome, model.NamespaceID)
 
-		return nil
+		_, err = jobService.AddOutcome(job.ID, event.Record, outcome, nil)
+		return err
 	}
 }
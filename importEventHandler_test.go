@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/importer"
+	"github.com/dukfaar/recipeBackend/localbus"
+	"github.com/dukfaar/recipeBackend/recipe"
+)
+
+func importedRecipe(externalID string, input bson.ObjectId, output bson.ObjectId, amount int32) *recipe.Model {
+	source := "rc"
+	return &recipe.Model{
+		Inputs:       []recipe.InputElement{{recipe.InOutElement{ItemID: input, Amount: amount}}},
+		Outputs:      []recipe.OutputElement{{recipe.InOutElement{ItemID: output, Amount: 1}}},
+		ImportSource: &source,
+		ExternalID:   &externalID,
+	}
+}
+
+func handleImport(t *testing.T, handler EventHandler, job *importer.Job, record int, model *recipe.Model) {
+	t.Helper()
+
+	msg, err := json.Marshal(importer.RecordEvent{JobID: job.ID, Record: record, Recipe: model})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := handler(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReimportKeepsStoredFields(t *testing.T) {
+	ctx := context.Background()
+	recipeService := recipe.NewMemoryService(localbus.NewBus())
+	jobService := importer.NewMemoryJobService()
+	handler := CreateImportEventHandler(recipeService, jobService)
+
+	ore, bar, coal := bson.NewObjectId(), bson.NewObjectId(), bson.NewObjectId()
+	namespace := bson.NewObjectId()
+
+	stored := importedRecipe("1", ore, bar, 2)
+	stored.NamespaceID = &namespace
+	stored.BrokenReferences = []bson.ObjectId{ore, coal}
+	if _, err := recipeService.Create(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	job, _ := jobService.Create("rc", false, "")
+	handleImport(t, handler, job, 1, importedRecipe("1", ore, bar, 3))
+
+	result, err := recipeService.FindByID(stored.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inputs[0].Amount != 3 {
+		t.Errorf("input amount = %d, want the imported 3", result.Inputs[0].Amount)
+	}
+	if result.NamespaceID == nil || *result.NamespaceID != namespace {
+		t.Errorf("namespaceId = %v, want %v", result.NamespaceID, namespace)
+	}
+	if len(result.BrokenReferences) != 1 || result.BrokenReferences[0] != ore {
+		t.Errorf("brokenReferences = %v, want only the still used %v", result.BrokenReferences, ore)
+	}
+
+	job, _ = jobService.FindByID(job.ID.Hex())
+	if job.Updated != 1 || job.Created != 0 {
+		t.Errorf("created %d and updated %d recipes, want an update", job.Created, job.Updated)
+	}
+}
+
+func TestReimportSkipsArchivedRecipes(t *testing.T) {
+	ctx := context.Background()
+	recipeService := recipe.NewMemoryService(localbus.NewBus())
+	jobService := importer.NewMemoryJobService()
+	handler := CreateImportEventHandler(recipeService, jobService)
+
+	ore, bar := bson.NewObjectId(), bson.NewObjectId()
+
+	archivedAt := time.Now().UTC().Truncate(time.Millisecond)
+	stored := importedRecipe("1", ore, bar, 2)
+	stored.ArchivedAt = &archivedAt
+	if _, err := recipeService.Create(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	job, _ := jobService.Create("rc", false, "")
+	handleImport(t, handler, job, 1, importedRecipe("1", ore, bar, 3))
+
+	result, err := recipeService.FindByID(stored.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ArchivedAt == nil || result.Inputs[0].Amount != 2 {
+		t.Errorf("archived recipe was changed: %+v", result)
+	}
+
+	count, _ := recipeService.CountWithQuery(bson.M{})
+	if count != 1 {
+		t.Errorf("%d recipes stored, want the archived one only", count)
+	}
+
+	job, _ = jobService.FindByID(job.ID.Hex())
+	if job.Failed != 1 || len(job.Errors) != 1 || job.Errors[0].Message != importer.ErrArchived.Error() {
+		t.Errorf("job failed %d records with %v, want the archived one", job.Failed, job.Errors)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/dukfaar/recipeBackend/importer"
+)
+
+const maxImportUploadSize = 64 << 20
+
+func writeImportResponse(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"status": message})
+}
+
+func writeImportJobResponse(w http.ResponseWriter, job *importer.Job) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "OK", "jobId": job.ID.Hex()})
+}
+
+// ImportUploadHandler accepts a multipart upload with the fields file, format,
+// itemIdStrategy, namespaceId, dryRun and mapping (a JSON object of recipe
+// field to source field) and imports the file like the fileRecipeImport mutation.
+func ImportUploadHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeImportResponse(w, http.StatusMethodNotAllowed, "POST required")
+			return
+		}
+
+		ctx := r.Context()
+		if err := checkPermission(ctx, "mutation.fileRecipeImport"); err != nil {
+			writeImportResponse(w, http.StatusForbidden, "No Permission")
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportUploadSize)
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeImportResponse(w, http.StatusBadRequest, "Error reading file")
+			return
+		}
+		defer file.Close()
+
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			writeImportResponse(w, http.StatusBadRequest, "Error reading file")
+			return
+		}
+
+		var mapping []*FieldMappingInput
+		if rawMapping := r.FormValue("mapping"); rawMapping != "" {
+			var fields map[string]string
+			if err := json.Unmarshal([]byte(rawMapping), &fields); err != nil {
+				writeImportResponse(w, http.StatusBadRequest, "Invalid mapping")
+				return
+			}
+			for field, source := range fields {
+				mapping = append(mapping, &FieldMappingInput{Field: field, Source: source})
+			}
+		}
+
+		options, err := buildFileImportOptions(ctx, optionalFormValue(r, "namespaceId"), optionalFormValue(r, "itemIdStrategy"), &mapping)
+		if err != nil {
+			writeImportResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		fileImporter, err := importer.NewFileImporter(r.FormValue("format"), importer.OpenBytes(data), options)
+		if err != nil {
+			writeImportResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		job, err := startImport(ctx, fileImporter, r.FormValue("dryRun") == "true")
+		if err != nil {
+			writeImportResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		writeImportJobResponse(w, job)
+	})
+}
+
+func optionalFormValue(r *http.Request, name string) *string {
+	value := r.FormValue(name)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
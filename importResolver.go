@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/goUtils/eventbus"
+	"github.com/dukfaar/recipeBackend/gateway"
+	"github.com/dukfaar/recipeBackend/importer"
+	"github.com/dukfaar/recipeBackend/itemmapping"
+	"github.com/dukfaar/recipeBackend/jobs"
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/metrics"
+	"github.com/dukfaar/recipeBackend/rc"
+	"github.com/dukfaar/recipeBackend/recipe"
+	"github.com/dukfaar/recipeBackend/tracing"
+)
+
+type FieldMappingInput struct {
+	Field  string
+	Source string
+}
+
+// jobCancelPollInterval is how often a running import checks whether its job
+// was cancelled, possibly on another replica.
+const jobCancelPollInterval = time.Second
+
+var errImportInterrupted = errors.New("import interrupted")
+
+// watchImportJob cancels the import once its job was ended elsewhere.
+func watchImportJob(ctx context.Context, cancel context.CancelFunc, jobService importer.JobService, jobID bson.ObjectId) {
+	ticker := time.NewTicker(jobCancelPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current, err := jobService.FindByID(jobID.Hex())
+			if err == nil && current.Done() {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// startImport creates a job and runs the importer on the background job
+// runner. Every converted recipe is handed to the import.recipe handlers,
+// which store it and count it on the job. A dry run compares the converted
+// recipes with the stored ones right here and writes nothing but the job.
+func startImport(ctx context.Context, recipeImporter importer.Importer, dryRun bool) (*importer.Job, error) {
+	eventbus := ctx.Value("eventbus").(eventbus.EventBus)
+	jobService := ctx.Value("importJobService").(importer.JobService)
+	recipeService := ctx.Value("recipeService").(recipe.Service)
+	jobRunner := ctx.Value("jobRunner").(*jobs.Runner)
+
+	job, err := jobService.Create(recipeImporter.Name(), dryRun, recipe.ActorFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.ImportJobsStarted.WithLabelValues(job.Source, strconv.FormatBool(dryRun)).Inc()
+
+	// the job outlives the request, but stays correlated with it and
+	// continues its trace
+	requestID := logging.RequestID(ctx)
+	traceContext := tracing.Inject(ctx)
+
+	_, err = jobRunner.StartWithID(job.ID.Hex(), "import "+recipeImporter.Name(), func(jobCtx context.Context) (err error) {
+		importCtx, span := tracing.Start(tracing.Extract(logging.WithRequestID(jobCtx, requestID), traceContext), "import "+recipeImporter.Name())
+		defer func() { tracing.End(span, err) }()
+
+		importCtx, cancel := context.WithCancel(importCtx)
+		defer cancel()
+
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				jobService.Fail(job.ID, fmt.Errorf("import panicked: %v", recovered))
+				panic(recovered)
+			}
+		}()
+
+		recipeService := recipe.WithContext(importCtx, recipeService)
+
+		handle := func(model *recipe.Model, recordErr error) error {
+			if recordErr != nil {
+				jobError := importer.JobError{Message: recordErr.Error()}
+				if e, ok := recordErr.(*importer.RecordError); ok {
+					jobError = importer.JobError{ExternalID: e.ExternalID, Message: e.Err.Error()}
+				}
+
+				if !dryRun {
+					metrics.ObserveImportRecord(job.Source, "failed", nil)
+				}
+
+				_, err := jobService.Increment(job.ID, map[string]int{"total": 1})
+				if err != nil {
+					return err
+				}
+				_, err = jobService.AddError(job.ID, 0, jobError)
+				return err
+			}
+
+			counted, err := jobService.Increment(job.ID, map[string]int{"total": 1, "converted": 1})
+			if err != nil {
+				return err
+			}
+
+			if dryRun {
+				return compareImportedRecipe(recipeService, jobService, job.ID, model)
+			}
+
+			tracing.Emit(importCtx, eventbus, "import.recipe", &importer.RecordEvent{JobID: job.ID, Record: counted.Total, Recipe: model, CorrelationID: requestID})
+			return nil
+		}
+
+		go watchImportJob(importCtx, cancel, jobService, job.ID)
+
+		err = recipeImporter.Import(importCtx, handle)
+
+		if err == context.Canceled {
+			// a cancelled job keeps its status, anything else was cut off by a shutdown
+			jobService.Fail(job.ID, errImportInterrupted)
+			return err
+		}
+
+		if err != nil {
+			jobService.Fail(job.ID, err)
+			return err
+		}
+
+		_, err = jobService.FinishReading(job.ID)
+		return err
+	})
+
+	if err != nil {
+		jobService.Fail(job.ID, err)
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func compareImportedRecipe(recipeService recipe.Service, jobService importer.JobService, jobID bson.ObjectId, model *recipe.Model) error {
+	outcome, _, diffs, err := importer.Compare(recipeService, model)
+	if err != nil {
+		_, err = jobService.AddError(jobID, 0, importer.JobError{ExternalID: *model.ExternalID, Message: err.Error()})
+		return err
+	}
+
+	entry := &importer.ReportEntry{ExternalID: *model.ExternalID, Diffs: diffs}
+	if model.ID != "" {
+		entry.RecipeID = model.ID.Hex()
+	}
+
+	_, err = jobService.AddOutcome(jobID, 0, outcome, entry)
+	return err
+}
+
+func importUpstreams(ctx context.Context) importer.Upstreams {
+	return importer.Upstreams{
+		Gateway:      ctx.Value("gatewayClient").(*gateway.Client),
+		RC:           ctx.Value("rcClient").(*rc.Client),
+		ItemMappings: ctx.Value("itemMappingStore").(itemmapping.Store),
+		Workers:      ctx.Value("importWorkers").(int),
+	}
+}
+
+func resolveImportNamespace(ctx context.Context, namespaceId *string) (bson.ObjectId, error) {
+	if namespaceId != nil {
+		if !bson.IsObjectIdHex(*namespaceId) {
+			return "", fmt.Errorf("invalid namespace id %q", *namespaceId)
+		}
+		return bson.ObjectIdHex(*namespaceId), nil
+	}
+
+	ffxivNamespaceId, err := fetchFFXIVNamespace(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !bson.IsObjectIdHex(ffxivNamespaceId) {
+		return "", fmt.Errorf("namespace FFXIV not found")
+	}
+
+	return bson.ObjectIdHex(ffxivNamespaceId), nil
+}
+
+func buildFileImportOptions(ctx context.Context, namespaceId *string, itemIdStrategy *string, mapping *[]*FieldMappingInput) (importer.Options, error) {
+	namespaceObjectId, err := resolveImportNamespace(ctx, namespaceId)
+	if err != nil {
+		return importer.Options{}, err
+	}
+
+	strategy := importer.ItemIDStrategyID
+	if itemIdStrategy != nil {
+		strategy = *itemIdStrategy
+	}
+
+	upstreams := importUpstreams(ctx)
+	itemResolver, err := importer.NewItemResolver(strategy, upstreams, namespaceObjectId)
+	if err != nil {
+		return importer.Options{}, err
+	}
+
+	fieldMapping := importer.FieldMapping{}
+	if mapping != nil {
+		for _, entry := range *mapping {
+			fieldMapping[entry.Field] = entry.Source
+		}
+	}
+	if err := fieldMapping.Validate(); err != nil {
+		return importer.Options{}, err
+	}
+
+	return importer.Options{
+		Source:      "file",
+		NamespaceID: namespaceObjectId,
+		Mapping:     importer.DefaultFieldMapping.Merge(fieldMapping),
+		Resolver:    itemResolver,
+		Workers:     upstreams.Workers,
+	}, nil
+}
+
+func (r *Resolver) RcRecipeImport(ctx context.Context, args struct {
+	DryRun *bool
+}) (*importer.JobResolver, error) {
+	err := checkPermission(ctx, "mutation.rcRecipeImport")
+	if err != nil {
+		return nil, err
+	}
+
+	namespaceId, err := resolveImportNamespace(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := startImport(ctx, importer.NewRCImporter(importUpstreams(ctx), namespaceId), args.DryRun != nil && *args.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	return &importer.JobResolver{Job: job}, nil
+}
+
+func (r *Resolver) FileRecipeImport(ctx context.Context, args struct {
+	Format         string
+	Path           string
+	ItemIdStrategy *string
+	Mapping        *[]*FieldMappingInput
+	NamespaceId    *string
+	DryRun         *bool
+}) (*importer.JobResolver, error) {
+	err := checkPermission(ctx, "mutation.fileRecipeImport")
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := buildFileImportOptions(ctx, args.NamespaceId, args.ItemIdStrategy, args.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	open, err := importer.OpenPath(ctx.Value("importDir").(string), args.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileImporter, err := importer.NewFileImporter(args.Format, open, options)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := startImport(ctx, fileImporter, args.DryRun != nil && *args.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	return &importer.JobResolver{Job: job}, nil
+}
+
+func (r *Resolver) ImportJob(ctx context.Context, args struct {
+	Id string
+}) (*importer.JobResolver, error) {
+	err := checkPermission(ctx, "query.importJob")
+	if err != nil {
+		return nil, err
+	}
+
+	jobService := ctx.Value("importJobService").(importer.JobService)
+
+	job, err := jobService.FindByID(args.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &importer.JobResolver{Job: job}, nil
+}
+
+func (r *Resolver) CancelImportJob(ctx context.Context, args struct {
+	Id string
+}) (*importer.JobResolver, error) {
+	err := checkPermission(ctx, "mutation.cancelImportJob")
+	if err != nil {
+		return nil, err
+	}
+
+	jobService := ctx.Value("importJobService").(importer.JobService)
+	jobRunner := ctx.Value("jobRunner").(*jobs.Runner)
+
+	job, err := jobService.Cancel(args.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	// replicas running the import notice the cancelled job by themselves,
+	// this one can stop right away
+	jobRunner.Cancel(args.Id)
+
+	return &importer.JobResolver{Job: job}, nil
+}
+
+// importJobProgressInterval is how often a progress subscription polls its job.
+// Jobs are updated by the import.recipe handlers of all replicas, so polling
+// the shared collection is the one place that sees every change.
+const importJobProgressInterval = time.Second
+
+func (r *Resolver) ImportJobProgress(ctx context.Context, args struct {
+	Id string
+}) (<-chan *importer.JobResolver, error) {
+	err := checkPermission(ctx, "subscription.importJobProgress")
+	if err != nil {
+		return nil, err
+	}
+
+	jobService := ctx.Value("importJobService").(importer.JobService)
+
+	job, err := jobService.FindByID(args.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := make(chan *importer.JobResolver)
+
+	go func() {
+		defer close(progress)
+
+		ticker := time.NewTicker(importJobProgressInterval)
+		defer ticker.Stop()
+
+		var last *importer.Job
+		for {
+			if last == nil || !reflect.DeepEqual(last, job) {
+				select {
+				case progress <- &importer.JobResolver{Job: job}:
+				case <-ctx.Done():
+					return
+				}
+				last = job
+			}
+
+			if job.Done() {
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			current, err := jobService.FindByID(args.Id)
+			if err != nil {
+				logging.FromContext(ctx).Error("polling import job failed", "jobId", args.Id, "error", err)
+				continue
+			}
+			job = current
+		}
+	}()
+
+	return progress, nil
+}
+
+func (r *Resolver) BackgroundJobs(ctx context.Context) (*[]*jobs.Resolver, error) {
+	err := checkPermission(ctx, "query.backgroundJobs")
+	if err != nil {
+		return nil, err
+	}
+
+	jobRunner := ctx.Value("jobRunner").(*jobs.Runner)
+
+	runningJobs := jobRunner.List()
+	l := make([]*jobs.Resolver, len(runningJobs))
+	for i := range runningJobs {
+		l[i] = &jobs.Resolver{Job: runningJobs[i]}
+	}
+	return &l, nil
+}
@@ -0,0 +1,41 @@
+package importer
+
+import (
+	"errors"
+
+	mgo "github.com/globalsign/mgo"
+
+	"github.com/dukfaar/recipeBackend/recipe"
+)
+
+// ErrArchived is returned for imported recipes whose stored version was
+// archived, like the recipes of a deleted namespace. Importing them again
+// would bring them back.
+var ErrArchived = errors.New("recipe is archived")
+
+// Compare looks up the stored version of an imported recipe and decides
+// whether importing it creates, updates or leaves the recipe unchanged.
+// The returned existing recipe is nil when the recipe would be created.
+// Fields the import doesn't set are taken over from the stored version, so
+// model can replace it.
+func Compare(recipeService recipe.Service, model *recipe.Model) (string, *recipe.Model, []recipe.FieldDiff, error) {
+	existing, err := recipeService.FindByExternalID(*model.ImportSource, *model.ExternalID)
+	if err == mgo.ErrNotFound {
+		return OutcomeCreated, nil, nil, nil
+	}
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	if existing.ArchivedAt != nil {
+		return "", nil, nil, ErrArchived
+	}
+
+	model.KeepStoredFields(existing)
+	diffs := recipe.Diff(existing, model)
+	if len(diffs) == 0 {
+		return OutcomeUnchanged, existing, nil, nil
+	}
+
+	return OutcomeUpdated, existing, diffs, nil
+}
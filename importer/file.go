@@ -0,0 +1,176 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// OpenFunc opens the data of a file based import. It may be called more than once.
+type OpenFunc func() (io.ReadCloser, error)
+
+// OpenBytes serves an uploaded file that has already been read into memory.
+func OpenBytes(data []byte) OpenFunc {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
+// OpenPath opens a file on the server. Only files below baseDir can be opened.
+func OpenPath(baseDir string, path string) (OpenFunc, error) {
+	fullPath := filepath.Join(baseDir, filepath.Clean("/"+path))
+
+	if _, err := os.Stat(fullPath); err != nil {
+		return nil, err
+	}
+
+	return func() (io.ReadCloser, error) {
+		return os.Open(fullPath)
+	}, nil
+}
+
+type decodeFunc func(r io.Reader, handle func(map[string]interface{}) error) error
+
+// FileImporter reads recipes from a JSON array, NDJSON or CSV file.
+type FileImporter struct {
+	format  string
+	open    OpenFunc
+	decode  decodeFunc
+	Options Options
+}
+
+func NewJSONImporter(open OpenFunc, options Options) *FileImporter {
+	return &FileImporter{format: FormatJSON, open: open, decode: decodeJSON, Options: options}
+}
+
+func NewNDJSONImporter(open OpenFunc, options Options) *FileImporter {
+	return &FileImporter{format: FormatNDJSON, open: open, decode: decodeNDJSON, Options: options}
+}
+
+func NewCSVImporter(open OpenFunc, options Options) *FileImporter {
+	return &FileImporter{format: FormatCSV, open: open, decode: decodeCSV, Options: options}
+}
+
+func NewFileImporter(format string, open OpenFunc, options Options) (*FileImporter, error) {
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		return NewJSONImporter(open, options), nil
+	case FormatNDJSON:
+		return NewNDJSONImporter(open, options), nil
+	case FormatCSV:
+		return NewCSVImporter(open, options), nil
+	default:
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+}
+
+func (i *FileImporter) Name() string {
+	return i.format
+}
+
+func (i *FileImporter) Import(ctx context.Context, handle HandleFunc) error {
+	reader, err := i.open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return convertAll(ctx, i.Options, func(emit func(map[string]interface{}) error) error {
+		return i.decode(reader, emit)
+	}, handle)
+}
+
+func decodeJSON(r io.Reader, handle func(map[string]interface{}) error) error {
+	decoder := json.NewDecoder(r)
+
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected a JSON array of recipes")
+	}
+
+	for decoder.More() {
+		var data map[string]interface{}
+		if err := decoder.Decode(&data); err != nil {
+			return err
+		}
+
+		if err := handle(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func decodeNDJSON(r io.Reader, handle func(map[string]interface{}) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var data map[string]interface{}
+		if err := json.Unmarshal(text, &data); err != nil {
+			return fmt.Errorf("line %v: %v", line, err)
+		}
+
+		if err := handle(data); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func decodeCSV(r io.Reader, handle func(map[string]interface{}) error) error {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		data := make(map[string]interface{}, len(header))
+		for column, name := range header {
+			if column < len(row) && row[column] != "" {
+				data[name] = row[column]
+			}
+		}
+
+		if err := handle(data); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/recipe"
+)
+
+var (
+	ore   = bson.ObjectIdHex("5b0000000000000000000001")
+	bar   = bson.ObjectIdHex("5b0000000000000000000002")
+	coal  = bson.ObjectIdHex("5b0000000000000000000003")
+	plate = bson.ObjectIdHex("5b0000000000000000000004")
+)
+
+var testFiles = map[string]string{
+	FormatJSON: `[
+		{"_id": "r1", "inputs": [{"item": "5b0000000000000000000001", "amount": 2}, {"item": "5b0000000000000000000003"}], "outputs": [{"item": "5b0000000000000000000002", "amount": 1}], "craftingLevel": 5},
+		{"_id": "r2", "inputs": [{"item": "5b0000000000000000000002", "amount": 3}], "outputs": [{"item": "5b0000000000000000000004", "amount": 1}], "stars": 1}
+	]`,
+	FormatNDJSON: `{"_id": "r1", "inputs": [{"item": "5b0000000000000000000001", "amount": 2}, {"item": "5b0000000000000000000003"}], "outputs": [{"item": "5b0000000000000000000002", "amount": 1}], "craftingLevel": 5}
+
+{"_id": "r2", "inputs": [{"item": "5b0000000000000000000002", "amount": 3}], "outputs": [{"item": "5b0000000000000000000004", "amount": 1}], "stars": 1}
+`,
+	FormatCSV: `_id,inputs,outputs,craftingLevel,stars
+r1,5b0000000000000000000001:2;5b0000000000000000000003,5b0000000000000000000002:1,5,
+r2,5b0000000000000000000002:3,5b0000000000000000000004:1,,1
+`,
+}
+
+// collect imports everything and returns the models by external id and the
+// record errors.
+func collect(t *testing.T, importer Importer) (map[string]*recipe.Model, []error) {
+	t.Helper()
+
+	var (
+		mutex  sync.Mutex
+		models = make(map[string]*recipe.Model)
+		errs   []error
+	)
+	err := importer.Import(context.Background(), func(model *recipe.Model, err error) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		models[*model.ExternalID] = model
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return models, errs
+}
+
+func TestFileImporterFormats(t *testing.T) {
+	namespace := bson.NewObjectId()
+
+	for format, data := range testFiles {
+		t.Run(format, func(t *testing.T) {
+			importer, err := NewFileImporter(format, OpenBytes([]byte(data)), Options{
+				Source:      "file",
+				NamespaceID: namespace,
+				Mapping:     DefaultFieldMapping,
+				Resolver:    IDItemResolver{},
+				Workers:     4,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			models, errs := collect(t, importer)
+			if len(errs) > 0 {
+				t.Fatalf("record errors: %v", errs)
+			}
+			if len(models) != 2 {
+				t.Fatalf("imported %d recipes, want 2", len(models))
+			}
+
+			r1 := models["r1"]
+			if *r1.ImportSource != "file" || r1.NamespaceID == nil || *r1.NamespaceID != namespace {
+				t.Errorf("r1 source %v in namespace %v, want file in %v", *r1.ImportSource, r1.NamespaceID, namespace)
+			}
+			if len(r1.Inputs) != 2 || r1.Inputs[0].ItemID != ore || r1.Inputs[0].Amount != 2 || r1.Inputs[1].ItemID != coal || r1.Inputs[1].Amount != 1 {
+				t.Errorf("r1 inputs = %v, want 2 %v and 1 %v", r1.Inputs, ore, coal)
+			}
+			if len(r1.Outputs) != 1 || r1.Outputs[0].ItemID != bar {
+				t.Errorf("r1 outputs = %v, want %v", r1.Outputs, bar)
+			}
+			if r1.CraftingLevel == nil || *r1.CraftingLevel != 5 || r1.Stars != nil {
+				t.Errorf("r1 craftingLevel %v and stars %v, want 5 and none", r1.CraftingLevel, r1.Stars)
+			}
+
+			r2 := models["r2"]
+			if len(r2.Outputs) != 1 || r2.Outputs[0].ItemID != plate || r2.Stars == nil || *r2.Stars != 1 {
+				t.Errorf("r2 = %+v, want %v with 1 star", r2, plate)
+			}
+		})
+	}
+}
+
+func TestFileImporterMapping(t *testing.T) {
+	data := `[{"key": "r1", "in": [{"id": "5b0000000000000000000001", "n": 4}], "out": []}]`
+	importer := NewJSONImporter(OpenBytes([]byte(data)), Options{
+		Source:   "file",
+		Mapping:  DefaultFieldMapping.Merge(FieldMapping{"externalId": "key", "inputs": "in", "outputs": "out", "item": "id", "amount": "n"}),
+		Resolver: IDItemResolver{},
+	})
+
+	models, errs := collect(t, importer)
+	if len(errs) > 0 || models["r1"] == nil {
+		t.Fatalf("got %v and errors %v, want r1", models, errs)
+	}
+	if inputs := models["r1"].Inputs; len(inputs) != 1 || inputs[0].ItemID != ore || inputs[0].Amount != 4 {
+		t.Errorf("inputs = %v, want 4 %v", inputs, ore)
+	}
+
+	if err := (FieldMapping{"name": "title"}).Validate(); err == nil {
+		t.Error("Validate accepted an unknown recipe field")
+	}
+}
+
+func TestFileImporterRecordErrors(t *testing.T) {
+	data := `_id,inputs,outputs
+r1,5b0000000000000000000001:x,5b0000000000000000000002
+r2,iron,5b0000000000000000000002
+,5b0000000000000000000001,5b0000000000000000000002
+r4,5b0000000000000000000001,5b0000000000000000000002
+`
+	importer := NewCSVImporter(OpenBytes([]byte(data)), Options{
+		Source:   "file",
+		Mapping:  DefaultFieldMapping,
+		Resolver: IDItemResolver{},
+	})
+
+	models, errs := collect(t, importer)
+	if len(models) != 1 || models["r4"] == nil {
+		t.Errorf("imported %v, want only r4", models)
+	}
+
+	var externalIDs []string
+	for _, err := range errs {
+		recordErr, ok := err.(*RecordError)
+		if !ok {
+			t.Fatalf("error %v is no *RecordError", err)
+		}
+		externalIDs = append(externalIDs, recordErr.ExternalID)
+	}
+	sort.Strings(externalIDs)
+	if fmt.Sprint(externalIDs) != "[ r1 r2]" {
+		t.Errorf("record errors of %q, want r1, r2 and the record without id", externalIDs)
+	}
+}
+
+func TestFileImporterStopsOnHandleError(t *testing.T) {
+	var data []byte
+	data = append(data, '[')
+	for i := 0; i < 100; i++ {
+		if i > 0 {
+			data = append(data, ',')
+		}
+		data = append(data, fmt.Sprintf(`{"_id": "r%d"}`, i)...)
+	}
+	data = append(data, ']')
+
+	importer := NewJSONImporter(OpenBytes(data), Options{Mapping: DefaultFieldMapping, Resolver: IDItemResolver{}, Workers: 4})
+
+	stop := errors.New("stop")
+	var (
+		mutex   sync.Mutex
+		handled int
+	)
+	err := importer.Import(context.Background(), func(model *recipe.Model, err error) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		handled++
+		return stop
+	})
+	if err != stop {
+		t.Errorf("Import = %v, want the error of handle", err)
+	}
+	if handled >= 100 {
+		t.Errorf("handled all %d records after the error", handled)
+	}
+}
+
+func TestFileImporterInvalidFiles(t *testing.T) {
+	tests := map[string]string{
+		FormatJSON:   `{"_id": "r1"}`,
+		FormatNDJSON: "{\"_id\": \"r1\"}\nnot json\n",
+	}
+
+	for format, data := range tests {
+		importer, _ := NewFileImporter(format, OpenBytes([]byte(data)), Options{Mapping: DefaultFieldMapping, Resolver: IDItemResolver{}})
+		err := importer.Import(context.Background(), func(*recipe.Model, error) error { return nil })
+		if err == nil {
+			t.Errorf("%v import of %q succeeded", format, data)
+		}
+	}
+
+	if _, err := NewFileImporter("xml", OpenBytes(nil), Options{}); err == nil {
+		t.Error("NewFileImporter accepted an unknown format")
+	}
+}
+
+func TestOpenPathStaysInBaseDir(t *testing.T) {
+	base, err := ioutil.TempDir("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	outside := filepath.Join(filepath.Dir(base), "outside.json")
+	if err := ioutil.WriteFile(outside, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(outside)
+	if err := ioutil.WriteFile(filepath.Join(base, "inside.json"), []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenPath(base, "../outside.json"); err == nil {
+		t.Error("OpenPath opened a file outside of the base directory")
+	}
+	if _, err := OpenPath(base, "/inside.json"); err != nil {
+		t.Errorf("OpenPath(/inside.json) = %v", err)
+	}
+}
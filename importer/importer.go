@@ -0,0 +1,294 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/recipe"
+)
+
+// HandleFunc is called once per record, either with the converted recipe or
+// with a *RecordError if the record could not be converted.
+// An error returned by it aborts the import. With more than one worker it is
+// called concurrently.
+type HandleFunc func(model *recipe.Model, err error) error
+
+// Importer reads recipes from an external source and hands them to handle one
+// at a time, with all item references already resolved to item service ids.
+type Importer interface {
+	Name() string
+	Import(ctx context.Context, handle HandleFunc) error
+}
+
+// RecordEvent is the payload of the import.recipe event.
+type RecordEvent struct {
+	JobID bson.ObjectId `json:"jobId"`
+	// Record numbers the records of a job, so redelivered events are only
+	// counted once
+	Record        int               `json:"record"`
+	Recipe        *recipe.Model     `json:"recipe"`
+	CorrelationID string            `json:"correlationId,omitempty"`
+	TraceContext  map[string]string `json:"traceContext,omitempty"`
+}
+
+func (e *RecordEvent) SetTraceContext(traceContext map[string]string) {
+	e.TraceContext = traceContext
+}
+
+// Options are shared by all importers.
+type Options struct {
+	// Source is stored on every imported recipe together with its external id,
+	// so a later import of the same source updates instead of duplicating.
+	Source      string
+	NamespaceID bson.ObjectId
+	Mapping     FieldMapping
+	Resolver    ItemResolver
+	// Workers is the number of records converted in parallel.
+	Workers int
+}
+
+// RecordError is returned when a single record could not be converted.
+type RecordError struct {
+	ExternalID string
+	Err        error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %v: %v", e.ExternalID, e.Err)
+}
+
+// Element is an input or output of a record before its item is resolved.
+type Element struct {
+	Item   string
+	Amount int32
+}
+
+// Record is a recipe as read from a source, with item references as the
+// source knows them.
+type Record struct {
+	ExternalID            string
+	Inputs                []Element
+	Outputs               []Element
+	CraftingLevel         *int32
+	CraftingJobID         *string
+	Masterbook            *int32
+	RequiredControl       *int32
+	RequiredCraftsmanship *int32
+	Stars                 *int32
+}
+
+// ToModel resolves all item references of the record and builds the recipe to store.
+func (r *Record) ToModel(ctx context.Context, options Options) (*recipe.Model, error) {
+	source := options.Source
+	externalID := r.ExternalID
+
+	model := &recipe.Model{
+		CraftingLevel:         r.CraftingLevel,
+		Masterbook:            r.Masterbook,
+		RequiredControl:       r.RequiredControl,
+		RequiredCraftsmanship: r.RequiredCraftsmanship,
+		Stars:                 r.Stars,
+		ImportSource:          &source,
+		ExternalID:            &externalID,
+	}
+
+	if options.NamespaceID != "" {
+		namespaceID := options.NamespaceID
+		model.NamespaceID = &namespaceID
+	}
+
+	if r.CraftingJobID != nil && bson.IsObjectIdHex(*r.CraftingJobID) {
+		craftingJobID := bson.ObjectIdHex(*r.CraftingJobID)
+		model.CraftingJobID = &craftingJobID
+	}
+
+	itemIDs, err := r.resolveItems(ctx, options.Resolver)
+	if err != nil {
+		return nil, &RecordError{ExternalID: r.ExternalID, Err: err}
+	}
+
+	model.Inputs = make([]recipe.InputElement, len(r.Inputs))
+	for i, input := range r.Inputs {
+		model.Inputs[i] = recipe.InputElement{recipe.InOutElement{ItemID: itemIDs[input.Item], Amount: input.Amount}}
+	}
+
+	model.Outputs = make([]recipe.OutputElement, len(r.Outputs))
+	for i, output := range r.Outputs {
+		model.Outputs[i] = recipe.OutputElement{recipe.InOutElement{ItemID: itemIDs[output.Item], Amount: output.Amount}}
+	}
+
+	return model, nil
+}
+
+func (r *Record) itemRefs() []string {
+	seen := make(map[string]bool)
+	refs := make([]string, 0, len(r.Inputs)+len(r.Outputs))
+
+	for _, elements := range [][]Element{r.Inputs, r.Outputs} {
+		for _, element := range elements {
+			if !seen[element.Item] {
+				seen[element.Item] = true
+				refs = append(refs, element.Item)
+			}
+		}
+	}
+
+	return refs
+}
+
+func (r *Record) resolveItems(ctx context.Context, resolver ItemResolver) (map[string]bson.ObjectId, error) {
+	refs := r.itemRefs()
+
+	batchResolver, ok := resolver.(BatchItemResolver)
+	if !ok {
+		itemIDs := make(map[string]bson.ObjectId, len(refs))
+		for _, ref := range refs {
+			itemID, err := resolver.ResolveItem(ctx, ref)
+			if err != nil {
+				return nil, err
+			}
+			itemIDs[ref] = itemID
+		}
+		return itemIDs, nil
+	}
+
+	itemIDs, err := batchResolver.ResolveItems(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ref := range refs {
+		if _, ok := itemIDs[ref]; !ok {
+			return nil, fmt.Errorf("item %q not found", ref)
+		}
+	}
+
+	return itemIDs, nil
+}
+
+// prefetchBatchSize is the number of item references resolved together
+// before the records of a source are converted.
+const prefetchBatchSize = 500
+
+// prefetchItems resolves the items of all records in batches, so converting
+// the records afterwards doesn't need a round trip per record. Failures are
+// left for the conversion of the affected records to report.
+func prefetchItems(ctx context.Context, data []map[string]interface{}, options Options) error {
+	batchResolver, ok := options.Resolver.(BatchItemResolver)
+	if !ok {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	refs := make([]string, 0)
+	for _, entry := range data {
+		record, err := recordFromMap(entry, options.Mapping)
+		if err != nil {
+			continue
+		}
+		for _, ref := range record.itemRefs() {
+			if !seen[ref] {
+				seen[ref] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+
+	for start := 0; start < len(refs); start += prefetchBatchSize {
+		end := start + prefetchBatchSize
+		if end > len(refs) {
+			end = len(refs)
+		}
+
+		_, err := batchResolver.ResolveItems(ctx, refs[start:end])
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			logging.FromContext(ctx).Warn("prefetching items failed", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// importRecord converts a single decoded record and passes it to handle.
+func importRecord(ctx context.Context, data map[string]interface{}, options Options, handle HandleFunc) error {
+	record, err := recordFromMap(data, options.Mapping)
+	if err != nil {
+		if _, ok := err.(*RecordError); !ok {
+			err = &RecordError{Err: err}
+		}
+		return handle(nil, err)
+	}
+
+	model, err := record.ToModel(ctx, options)
+	if err != nil {
+		return handle(nil, err)
+	}
+
+	return handle(model, nil)
+}
+
+// convertAll converts the records produced by read using options.Workers
+// workers. read has to stop when emit returns an error.
+func convertAll(ctx context.Context, options Options, read func(emit func(map[string]interface{}) error) error, handle HandleFunc) error {
+	workers := options.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		records   = make(chan map[string]interface{})
+		waitGroup sync.WaitGroup
+		failOnce  sync.Once
+		failure   error
+	)
+
+	for i := 0; i < workers; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+
+			for data := range records {
+				if workerCtx.Err() != nil {
+					continue
+				}
+
+				if err := importRecord(workerCtx, data, options, handle); err != nil {
+					failOnce.Do(func() {
+						failure = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	readErr := read(func(data map[string]interface{}) error {
+		select {
+		case records <- data:
+			return nil
+		case <-workerCtx.Done():
+			return workerCtx.Err()
+		}
+	})
+
+	close(records)
+	waitGroup.Wait()
+
+	if failure != nil {
+		return failure
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return readErr
+}
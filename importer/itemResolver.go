@@ -0,0 +1,140 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/gateway"
+	"github.com/dukfaar/recipeBackend/itemmapping"
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/rc"
+)
+
+// ItemResolver turns the item reference used by a source into an item service id.
+type ItemResolver interface {
+	ResolveItem(ctx context.Context, ref string) (bson.ObjectId, error)
+}
+
+// BatchItemResolver is implemented by resolvers that can resolve many
+// references at once. References that can't be resolved are missing from
+// the result.
+type BatchItemResolver interface {
+	ItemResolver
+	ResolveItems(ctx context.Context, refs []string) (map[string]bson.ObjectId, error)
+}
+
+const (
+	// ItemIDStrategyID expects item service ids in the source.
+	ItemIDStrategyID = "id"
+	// ItemIDStrategyName looks items up by name in the target namespace.
+	ItemIDStrategyName = "name"
+	// ItemIDStrategyRC expects RC item ids and maps them by their RC name.
+	ItemIDStrategyRC = "rc"
+)
+
+// Upstreams bundles the services item resolvers and importers talk to.
+type Upstreams struct {
+	Gateway      *gateway.Client
+	RC           *rc.Client
+	ItemMappings itemmapping.Store
+	// Workers is the number of records, and RC items, processed in parallel.
+	// The upstream clients carry their own rate limits on top of that.
+	Workers int
+}
+
+func NewItemResolver(strategy string, upstreams Upstreams, namespaceID bson.ObjectId) (ItemResolver, error) {
+	switch strategy {
+	case "", ItemIDStrategyID:
+		return IDItemResolver{}, nil
+	case ItemIDStrategyName:
+		return NewNameItemResolver(upstreams.Gateway, namespaceID), nil
+	case ItemIDStrategyRC:
+		return NewRCItemResolver(upstreams, namespaceID), nil
+	default:
+		return nil, fmt.Errorf("unknown item id strategy %q", strategy)
+	}
+}
+
+func resolveSingle(ctx context.Context, resolver BatchItemResolver, ref string) (bson.ObjectId, error) {
+	ids, err := resolver.ResolveItems(ctx, []string{ref})
+	if err != nil {
+		return "", err
+	}
+
+	id, ok := ids[ref]
+	if !ok {
+		return "", fmt.Errorf("item %q not found", ref)
+	}
+	return id, nil
+}
+
+type IDItemResolver struct{}
+
+func (IDItemResolver) ResolveItem(ctx context.Context, ref string) (bson.ObjectId, error) {
+	if !bson.IsObjectIdHex(ref) {
+		return "", fmt.Errorf("invalid item id %q", ref)
+	}
+	return bson.ObjectIdHex(ref), nil
+}
+
+// NameItemResolver looks items up by name and remembers the result for the
+// lifetime of the resolver, which is a single import.
+type NameItemResolver struct {
+	Gateway     *gateway.Client
+	NamespaceID bson.ObjectId
+
+	mutex sync.RWMutex
+	cache map[string]bson.ObjectId
+}
+
+func NewNameItemResolver(gatewayClient *gateway.Client, namespaceID bson.ObjectId) *NameItemResolver {
+	return &NameItemResolver{
+		Gateway:     gatewayClient,
+		NamespaceID: namespaceID,
+		cache:       make(map[string]bson.ObjectId),
+	}
+}
+
+func (r *NameItemResolver) ResolveItem(ctx context.Context, name string) (bson.ObjectId, error) {
+	return resolveSingle(ctx, r, name)
+}
+
+func (r *NameItemResolver) ResolveItems(ctx context.Context, names []string) (map[string]bson.ObjectId, error) {
+	result := make(map[string]bson.ObjectId, len(names))
+	missing := make([]string, 0)
+
+	r.mutex.RLock()
+	for _, name := range names {
+		if id, ok := r.cache[name]; ok {
+			result[name] = id
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	r.mutex.RUnlock()
+
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	ids, err := r.Gateway.ItemIDsByNames(ctx, missing, r.NamespaceID.Hex())
+	if err != nil {
+		logging.FromContext(ctx).Error("fetching items failed", "error", err)
+		return result, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for name, id := range ids {
+		if bson.IsObjectIdHex(id) {
+			r.cache[name] = bson.ObjectIdHex(id)
+			result[name] = r.cache[name]
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,274 @@
+package importer
+
+import (
+	"time"
+
+	mgo "github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/recipe"
+)
+
+const (
+	JobStatusRunning   = "running"
+	JobStatusFinished  = "finished"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+const (
+	OutcomeCreated   = "created"
+	OutcomeUpdated   = "updated"
+	OutcomeUnchanged = "unchanged"
+)
+
+// maxJobErrors caps the per record errors kept on a job, so an import of a
+// completely broken file doesn't outgrow the document size limit.
+const maxJobErrors = 1000
+
+// recordRetention is how long the handled records of a job are remembered,
+// long after any redelivery of their events.
+const recordRetention = 7 * 24 * time.Hour
+
+// maxReportEntries caps the entries kept per outcome in a dry run report.
+// The counters on the job stay exact.
+const maxReportEntries = 5000
+
+// ReportEntry describes what importing a record did, or would do in a dry run.
+type ReportEntry struct {
+	ExternalID string             `json:"externalId" bson:"externalId"`
+	RecipeID   string             `json:"recipeId,omitempty" bson:"recipeId,omitempty"`
+	Diffs      []recipe.FieldDiff `json:"diffs,omitempty" bson:"diffs,omitempty"`
+}
+
+type Report struct {
+	Created []ReportEntry `json:"created,omitempty" bson:"created,omitempty"`
+	Updated []ReportEntry `json:"updated,omitempty" bson:"updated,omitempty"`
+}
+
+type JobError struct {
+	ExternalID string `json:"externalId,omitempty" bson:"externalId,omitempty"`
+	Message    string `json:"message" bson:"message"`
+}
+
+// Job tracks a single import run. Total and Converted are counted while the
+// source is read, Created, Updated, Unchanged and Failed while the records are
+// stored. A dry run only compares the records with the stored recipes and
+// collects the result in Report.
+type Job struct {
+	ID          bson.ObjectId `json:"_id" bson:"_id"`
+	Source      string        `json:"source" bson:"source"`
+	DryRun      bool          `json:"dryRun" bson:"dryRun"`
+	Status      string        `json:"status" bson:"status"`
+	ReadingDone bool          `json:"readingDone" bson:"readingDone"`
+	Total       int           `json:"total" bson:"total"`
+	Converted   int           `json:"converted" bson:"converted"`
+	Created     int           `json:"created" bson:"created"`
+	Updated     int           `json:"updated" bson:"updated"`
+	Unchanged   int           `json:"unchanged" bson:"unchanged"`
+	Failed      int           `json:"failed" bson:"failed"`
+	Errors      []JobError    `json:"errors,omitempty" bson:"errors,omitempty"`
+	Report      Report        `json:"report" bson:"report"`
+	Error       string        `json:"error,omitempty" bson:"error,omitempty"`
+	StartedBy   string        `json:"startedBy,omitempty" bson:"startedBy,omitempty"`
+	StartedAt   time.Time     `json:"startedAt" bson:"startedAt"`
+	FinishedAt  *time.Time    `json:"finishedAt,omitempty" bson:"finishedAt,omitempty"`
+}
+
+func (j *Job) Done() bool {
+	return j.Status != JobStatusRunning
+}
+
+func (j *Job) complete() bool {
+	return j.ReadingDone && j.Created+j.Updated+j.Unchanged+j.Failed >= j.Total
+}
+
+type JobService interface {
+	Create(source string, dryRun bool, startedBy string) (*Job, error)
+	FindByID(id string) (*Job, error)
+
+	// Increment adds to the named counters and finishes the job once every
+	// record has been stored or failed.
+	Increment(id bson.ObjectId, counters map[string]int) (*Job, error)
+	// AddError counts a failed record. AddError and AddOutcome count every
+	// record number only once, so redelivered events don't count twice.
+	// Record 0 is for records that are never redelivered.
+	AddError(id bson.ObjectId, record int, jobError JobError) (*Job, error)
+	// AddOutcome counts a stored or compared record. The entry is only kept
+	// in the report of dry runs.
+	AddOutcome(id bson.ObjectId, record int, outcome string, entry *ReportEntry) (*Job, error)
+	FinishReading(id bson.ObjectId) (*Job, error)
+	Fail(id bson.ObjectId, err error) (*Job, error)
+	Cancel(id string) (*Job, error)
+}
+
+type MgoJobService struct {
+	collection *mgo.Collection
+	// records remembers the counted records of every job
+	records *mgo.Collection
+}
+
+type jobRecordID struct {
+	JobID  bson.ObjectId `bson:"jobId"`
+	Record int           `bson:"record"`
+}
+
+type jobRecord struct {
+	ID        jobRecordID `bson:"_id"`
+	CreatedAt time.Time   `bson:"createdAt"`
+}
+
+func NewMgoJobService(db *mgo.Database) (*MgoJobService, error) {
+	records := db.C("importJobRecords")
+	err := records.EnsureIndex(mgo.Index{
+		Key:         []string{"createdAt"},
+		ExpireAfter: recordRetention,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MgoJobService{
+		collection: db.C("importJobs"),
+		records:    records,
+	}, nil
+}
+
+func (s *MgoJobService) Create(source string, dryRun bool, startedBy string) (*Job, error) {
+	job := &Job{
+		ID:        bson.NewObjectId(),
+		Source:    source,
+		DryRun:    dryRun,
+		Status:    JobStatusRunning,
+		StartedBy: startedBy,
+		StartedAt: time.Now().UTC(),
+	}
+
+	err := s.collection.Insert(job)
+
+	return job, err
+}
+
+func (s *MgoJobService) FindByID(id string) (*Job, error) {
+	var result Job
+
+	if !bson.IsObjectIdHex(id) {
+		return nil, mgo.ErrNotFound
+	}
+
+	err := s.collection.FindId(bson.ObjectIdHex(id)).One(&result)
+
+	return &result, err
+}
+
+func (s *MgoJobService) apply(id bson.ObjectId, update bson.M) (*Job, error) {
+	var result Job
+
+	_, err := s.collection.FindId(id).Apply(mgo.Change{
+		Update:    update,
+		ReturnNew: true,
+	}, &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.finishIfComplete(&result)
+}
+
+func (s *MgoJobService) finishIfComplete(job *Job) (*Job, error) {
+	if job.Done() || !job.complete() {
+		return job, nil
+	}
+
+	return s.setStatus(job.ID, JobStatusFinished, "")
+}
+
+func (s *MgoJobService) setStatus(id bson.ObjectId, status string, message string) (*Job, error) {
+	var result Job
+
+	finishedAt := time.Now().UTC()
+	set := bson.M{"status": status, "finishedAt": finishedAt}
+	if message != "" {
+		set["error"] = message
+	}
+
+	_, err := s.collection.Find(bson.M{"_id": id, "status": JobStatusRunning}).Apply(mgo.Change{
+		Update:    bson.M{"$set": set},
+		ReturnNew: true,
+	}, &result)
+
+	if err == mgo.ErrNotFound {
+		// somebody else already ended the job
+		err = s.collection.FindId(id).One(&result)
+	}
+
+	return &result, err
+}
+
+// applyOnce applies update unless record was counted before. The record is
+// claimed first and released again if the update fails, so the event can be
+// redelivered.
+func (s *MgoJobService) applyOnce(id bson.ObjectId, record int, update bson.M) (*Job, error) {
+	if record == 0 {
+		return s.apply(id, update)
+	}
+
+	recordID := jobRecordID{JobID: id, Record: record}
+	err := s.records.Insert(&jobRecord{ID: recordID, CreatedAt: time.Now().UTC()})
+	if mgo.IsDup(err) {
+		return s.FindByID(id.Hex())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := s.apply(id, update)
+	if err != nil {
+		s.records.RemoveId(recordID)
+	}
+	return job, err
+}
+
+func (s *MgoJobService) Increment(id bson.ObjectId, counters map[string]int) (*Job, error) {
+	return s.apply(id, bson.M{"$inc": counters})
+}
+
+func (s *MgoJobService) AddError(id bson.ObjectId, record int, jobError JobError) (*Job, error) {
+	return s.applyOnce(id, record, bson.M{
+		"$inc": bson.M{"failed": 1},
+		"$push": bson.M{"errors": bson.M{
+			"$each":  []JobError{jobError},
+			"$slice": -maxJobErrors,
+		}},
+	})
+}
+
+func (s *MgoJobService) AddOutcome(id bson.ObjectId, record int, outcome string, entry *ReportEntry) (*Job, error) {
+	update := bson.M{"$inc": bson.M{outcome: 1}}
+
+	if entry != nil && outcome != OutcomeUnchanged {
+		update["$push"] = bson.M{"report." + outcome: bson.M{
+			"$each":  []ReportEntry{*entry},
+			"$slice": maxReportEntries,
+		}}
+	}
+
+	return s.applyOnce(id, record, update)
+}
+
+func (s *MgoJobService) FinishReading(id bson.ObjectId) (*Job, error) {
+	return s.apply(id, bson.M{"$set": bson.M{"readingDone": true}})
+}
+
+func (s *MgoJobService) Fail(id bson.ObjectId, err error) (*Job, error) {
+	return s.setStatus(id, JobStatusFailed, err.Error())
+}
+
+func (s *MgoJobService) Cancel(id string) (*Job, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, mgo.ErrNotFound
+	}
+
+	return s.setStatus(bson.ObjectIdHex(id), JobStatusCancelled, "")
+}
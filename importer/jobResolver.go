@@ -0,0 +1,225 @@
+package importer
+
+import (
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"github.com/dukfaar/recipeBackend/recipe"
+)
+
+var GraphQLType = `
+type ImportJob {
+	_id: ID
+	source: String
+	dryRun: Boolean
+	status: String
+	total: Int
+	converted: Int
+	created: Int
+	updated: Int
+	unchanged: Int
+	failed: Int
+	recordErrors: [ImportJobError]
+	report: ImportReport
+	error: String
+	startedBy: String
+	startedAt: String
+	finishedAt: String
+}
+
+type ImportJobError {
+	externalId: String
+	message: String
+}
+
+type ImportReport {
+	created: [ImportReportEntry]
+	updated: [ImportReportEntry]
+}
+
+type ImportReportEntry {
+	externalId: String
+	recipeId: ID
+	diffs: [ImportFieldDiff]
+}
+
+type ImportFieldDiff {
+	field: String
+	before: String
+	after: String
+}
+`
+
+type JobResolver struct {
+	Job *Job
+}
+
+type JobErrorResolver struct {
+	JobError *JobError
+}
+
+type ReportResolver struct {
+	Report *Report
+}
+
+type ReportEntryResolver struct {
+	Entry *ReportEntry
+}
+
+type FieldDiffResolver struct {
+	Diff *recipe.FieldDiff
+}
+
+func (r *JobResolver) ID() *graphql.ID {
+	id := graphql.ID(r.Job.ID.Hex())
+	return &id
+}
+
+func (r *JobResolver) Source() *string {
+	return &r.Job.Source
+}
+
+func (r *JobResolver) DryRun() *bool {
+	return &r.Job.DryRun
+}
+
+func (r *JobResolver) Status() *string {
+	return &r.Job.Status
+}
+
+func (r *JobResolver) Total() *int32 {
+	result := int32(r.Job.Total)
+	return &result
+}
+
+func (r *JobResolver) Converted() *int32 {
+	result := int32(r.Job.Converted)
+	return &result
+}
+
+func (r *JobResolver) Created() *int32 {
+	result := int32(r.Job.Created)
+	return &result
+}
+
+func (r *JobResolver) Updated() *int32 {
+	result := int32(r.Job.Updated)
+	return &result
+}
+
+func (r *JobResolver) Unchanged() *int32 {
+	result := int32(r.Job.Unchanged)
+	return &result
+}
+
+func (r *JobResolver) Failed() *int32 {
+	result := int32(r.Job.Failed)
+	return &result
+}
+
+func (r *JobResolver) RecordErrors() *[]*JobErrorResolver {
+	l := make([]*JobErrorResolver, len(r.Job.Errors))
+	for i := range r.Job.Errors {
+		l[i] = &JobErrorResolver{JobError: &r.Job.Errors[i]}
+	}
+	return &l
+}
+
+func (r *JobResolver) Report() *ReportResolver {
+	return &ReportResolver{Report: &r.Job.Report}
+}
+
+func (r *JobResolver) Error() *string {
+	if r.Job.Error == "" {
+		return nil
+	}
+	return &r.Job.Error
+}
+
+func (r *JobResolver) StartedBy() *string {
+	if r.Job.StartedBy == "" {
+		return nil
+	}
+	return &r.Job.StartedBy
+}
+
+func (r *JobResolver) StartedAt() *string {
+	startedAt := r.Job.StartedAt.Format(time.RFC3339)
+	return &startedAt
+}
+
+func (r *JobResolver) FinishedAt() *string {
+	if r.Job.FinishedAt == nil {
+		return nil
+	}
+
+	finishedAt := r.Job.FinishedAt.Format(time.RFC3339)
+	return &finishedAt
+}
+
+func (r *JobErrorResolver) ExternalID() *string {
+	if r.JobError.ExternalID == "" {
+		return nil
+	}
+	return &r.JobError.ExternalID
+}
+
+func (r *JobErrorResolver) Message() *string {
+	return &r.JobError.Message
+}
+
+func reportEntryResolvers(entries []ReportEntry) *[]*ReportEntryResolver {
+	l := make([]*ReportEntryResolver, len(entries))
+	for i := range entries {
+		l[i] = &ReportEntryResolver{Entry: &entries[i]}
+	}
+	return &l
+}
+
+func (r *ReportResolver) Created() *[]*ReportEntryResolver {
+	return reportEntryResolvers(r.Report.Created)
+}
+
+func (r *ReportResolver) Updated() *[]*ReportEntryResolver {
+	return reportEntryResolvers(r.Report.Updated)
+}
+
+func (r *ReportEntryResolver) ExternalID() *string {
+	return &r.Entry.ExternalID
+}
+
+func (r *ReportEntryResolver) RecipeID() *graphql.ID {
+	if r.Entry.RecipeID == "" {
+		return nil
+	}
+
+	id := graphql.ID(r.Entry.RecipeID)
+	return &id
+}
+
+func (r *ReportEntryResolver) Diffs() *[]*FieldDiffResolver {
+	l := make([]*FieldDiffResolver, len(r.Entry.Diffs))
+	for i := range r.Entry.Diffs {
+		l[i] = &FieldDiffResolver{Diff: &r.Entry.Diffs[i]}
+	}
+	return &l
+}
+
+func (r *FieldDiffResolver) Field() *string {
+	return &r.Diff.Field
+}
+
+func (r *FieldDiffResolver) Before() *string {
+	if r.Diff.Before == "" {
+		return nil
+	}
+	return &r.Diff.Before
+}
+
+func (r *FieldDiffResolver) After() *string {
+	if r.Diff.After == "" {
+		return nil
+	}
+	return &r.Diff.After
+}
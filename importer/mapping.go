@@ -0,0 +1,204 @@
+package importer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FieldMapping maps recipe fields to the names they have in a source.
+// Fields that are not mapped are expected under their own name.
+//
+// The recipe fields are externalId, inputs, outputs, craftingLevel,
+// craftingJobId, masterbook, requiredControl, requiredCraftsmanship and stars,
+// plus item and amount for the elements of inputs and outputs.
+type FieldMapping map[string]string
+
+var DefaultFieldMapping = FieldMapping{
+	"externalId":    "_id",
+	"craftingJobId": "craftingJob",
+}
+
+var mappableFields = []string{
+	"externalId",
+	"inputs",
+	"outputs",
+	"item",
+	"amount",
+	"craftingLevel",
+	"craftingJobId",
+	"masterbook",
+	"requiredControl",
+	"requiredCraftsmanship",
+	"stars",
+}
+
+// Merge returns a mapping where the entries of other replace those of m.
+func (m FieldMapping) Merge(other FieldMapping) FieldMapping {
+	result := make(FieldMapping, len(m)+len(other))
+	for field, source := range m {
+		result[field] = source
+	}
+	for field, source := range other {
+		result[field] = source
+	}
+	return result
+}
+
+func (m FieldMapping) Validate() error {
+	for field := range m {
+		known := false
+		for _, mappable := range mappableFields {
+			if field == mappable {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown recipe field %q in mapping", field)
+		}
+	}
+	return nil
+}
+
+func (m FieldMapping) Source(field string) string {
+	if source, ok := m[field]; ok {
+		return source
+	}
+	return field
+}
+
+// recordFromMap builds a record from a decoded JSON object or a CSV row.
+// In CSV rows inputs and outputs are written as "item:amount;item:amount".
+func recordFromMap(data map[string]interface{}, mapping FieldMapping) (*Record, error) {
+	record := &Record{}
+
+	externalID, err := stringValue(data[mapping.Source("externalId")])
+	if err != nil {
+		return nil, fmt.Errorf("externalId: %v", err)
+	}
+	if externalID == nil || *externalID == "" {
+		return nil, fmt.Errorf("record without %v", mapping.Source("externalId"))
+	}
+	record.ExternalID = *externalID
+
+	if record.Inputs, err = elementsValue(data[mapping.Source("inputs")], mapping); err != nil {
+		return nil, &RecordError{ExternalID: record.ExternalID, Err: fmt.Errorf("inputs: %v", err)}
+	}
+	if record.Outputs, err = elementsValue(data[mapping.Source("outputs")], mapping); err != nil {
+		return nil, &RecordError{ExternalID: record.ExternalID, Err: fmt.Errorf("outputs: %v", err)}
+	}
+
+	intFields := map[string]**int32{
+		"craftingLevel":         &record.CraftingLevel,
+		"masterbook":            &record.Masterbook,
+		"requiredControl":       &record.RequiredControl,
+		"requiredCraftsmanship": &record.RequiredCraftsmanship,
+		"stars":                 &record.Stars,
+	}
+	for field, target := range intFields {
+		if *target, err = intValue(data[mapping.Source(field)]); err != nil {
+			return nil, &RecordError{ExternalID: record.ExternalID, Err: fmt.Errorf("%v: %v", field, err)}
+		}
+	}
+
+	if record.CraftingJobID, err = stringValue(data[mapping.Source("craftingJobId")]); err != nil {
+		return nil, &RecordError{ExternalID: record.ExternalID, Err: fmt.Errorf("craftingJobId: %v", err)}
+	}
+
+	return record, nil
+}
+
+func stringValue(value interface{}) (*string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return &v, nil
+	case float64:
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		return &s, nil
+	default:
+		return nil, fmt.Errorf("unexpected value %v", value)
+	}
+}
+
+func intValue(value interface{}) (*int32, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		i := int32(v)
+		return &i, nil
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		parsed, err := strconv.ParseInt(strings.TrimSpace(v), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		i := int32(parsed)
+		return &i, nil
+	default:
+		return nil, fmt.Errorf("unexpected value %v", value)
+	}
+}
+
+func elementsValue(value interface{}, mapping FieldMapping) ([]Element, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return parseElementList(v)
+	case []interface{}:
+		elements := make([]Element, 0, len(v))
+		for _, entry := range v {
+			data, ok := entry.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("unexpected element %v", entry)
+			}
+
+			item, err := stringValue(data[mapping.Source("item")])
+			if err != nil || item == nil {
+				return nil, fmt.Errorf("element without item: %v", entry)
+			}
+
+			amount, err := intValue(data[mapping.Source("amount")])
+			if err != nil {
+				return nil, err
+			}
+
+			element := Element{Item: *item, Amount: 1}
+			if amount != nil {
+				element.Amount = *amount
+			}
+			elements = append(elements, element)
+		}
+		return elements, nil
+	default:
+		return nil, fmt.Errorf("unexpected value %v", value)
+	}
+}
+
+func parseElementList(value string) ([]Element, error) {
+	elements := make([]Element, 0)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		element := Element{Item: entry, Amount: 1}
+		if separator := strings.LastIndex(entry, ":"); separator >= 0 {
+			amount, err := strconv.ParseInt(strings.TrimSpace(entry[separator+1:]), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid amount in %q", entry)
+			}
+			element.Item = strings.TrimSpace(entry[:separator])
+			element.Amount = int32(amount)
+		}
+		elements = append(elements, element)
+	}
+	return elements, nil
+}
@@ -0,0 +1,190 @@
+package importer
+
+import (
+	"sync"
+	"time"
+
+	mgo "github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// MemoryJobService keeps import jobs in memory, for running without a
+// database. Jobs are only visible to the replica that created them.
+type MemoryJobService struct {
+	mutex   sync.Mutex
+	jobs    map[bson.ObjectId]*Job
+	records map[jobRecordID]bool
+}
+
+func NewMemoryJobService() *MemoryJobService {
+	return &MemoryJobService{
+		jobs:    make(map[bson.ObjectId]*Job),
+		records: make(map[jobRecordID]bool),
+	}
+}
+
+// copyJob returns a copy that doesn't share slices with the stored job.
+func copyJob(job *Job) *Job {
+	result := *job
+	result.Errors = append([]JobError(nil), job.Errors...)
+	result.Report.Created = append([]ReportEntry(nil), job.Report.Created...)
+	result.Report.Updated = append([]ReportEntry(nil), job.Report.Updated...)
+	return &result
+}
+
+func (s *MemoryJobService) Create(source string, dryRun bool, startedBy string) (*Job, error) {
+	job := &Job{
+		ID:        bson.NewObjectId(),
+		Source:    source,
+		DryRun:    dryRun,
+		Status:    JobStatusRunning,
+		StartedBy: startedBy,
+		StartedAt: time.Now().UTC(),
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.jobs[job.ID] = job
+
+	return copyJob(job), nil
+}
+
+func (s *MemoryJobService) FindByID(id string) (*Job, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, mgo.ErrNotFound
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, ok := s.jobs[bson.ObjectIdHex(id)]
+	if !ok {
+		return &Job{}, mgo.ErrNotFound
+	}
+
+	return copyJob(job), nil
+}
+
+// apply changes the job under the lock and finishes it once it is complete,
+// like the update and finishIfComplete of MgoJobService.
+func (s *MemoryJobService) apply(id bson.ObjectId, change func(job *Job)) (*Job, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, mgo.ErrNotFound
+	}
+
+	change(job)
+
+	if !job.Done() && job.complete() {
+		setJobStatus(job, JobStatusFinished, "")
+	}
+
+	return copyJob(job), nil
+}
+
+func setJobStatus(job *Job, status string, message string) {
+	if job.Done() {
+		// somebody else already ended the job
+		return
+	}
+
+	finishedAt := time.Now().UTC()
+	job.Status = status
+	job.FinishedAt = &finishedAt
+	if message != "" {
+		job.Error = message
+	}
+}
+
+func incrementCounter(job *Job, name string, value int) {
+	switch name {
+	case "total":
+		job.Total += value
+	case "converted":
+		job.Converted += value
+	case OutcomeCreated:
+		job.Created += value
+	case OutcomeUpdated:
+		job.Updated += value
+	case OutcomeUnchanged:
+		job.Unchanged += value
+	case "failed":
+		job.Failed += value
+	}
+}
+
+func (s *MemoryJobService) Increment(id bson.ObjectId, counters map[string]int) (*Job, error) {
+	return s.apply(id, func(job *Job) {
+		for name, value := range counters {
+			incrementCounter(job, name, value)
+		}
+	})
+}
+
+// applyOnce changes the job unless record was counted before.
+func (s *MemoryJobService) applyOnce(id bson.ObjectId, record int, change func(job *Job)) (*Job, error) {
+	return s.apply(id, func(job *Job) {
+		if record != 0 {
+			recordID := jobRecordID{JobID: id, Record: record}
+			if s.records[recordID] {
+				return
+			}
+			s.records[recordID] = true
+		}
+
+		change(job)
+	})
+}
+
+func (s *MemoryJobService) AddError(id bson.ObjectId, record int, jobError JobError) (*Job, error) {
+	return s.applyOnce(id, record, func(job *Job) {
+		job.Failed++
+		job.Errors = append(job.Errors, jobError)
+		if len(job.Errors) > maxJobErrors {
+			job.Errors = job.Errors[len(job.Errors)-maxJobErrors:]
+		}
+	})
+}
+
+func (s *MemoryJobService) AddOutcome(id bson.ObjectId, record int, outcome string, entry *ReportEntry) (*Job, error) {
+	return s.applyOnce(id, record, func(job *Job) {
+		incrementCounter(job, outcome, 1)
+
+		if entry == nil {
+			return
+		}
+
+		switch {
+		case outcome == OutcomeCreated && len(job.Report.Created) < maxReportEntries:
+			job.Report.Created = append(job.Report.Created, *entry)
+		case outcome == OutcomeUpdated && len(job.Report.Updated) < maxReportEntries:
+			job.Report.Updated = append(job.Report.Updated, *entry)
+		}
+	})
+}
+
+func (s *MemoryJobService) FinishReading(id bson.ObjectId) (*Job, error) {
+	return s.apply(id, func(job *Job) {
+		job.ReadingDone = true
+	})
+}
+
+func (s *MemoryJobService) Fail(id bson.ObjectId, err error) (*Job, error) {
+	return s.apply(id, func(job *Job) {
+		setJobStatus(job, JobStatusFailed, err.Error())
+	})
+}
+
+func (s *MemoryJobService) Cancel(id string) (*Job, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, mgo.ErrNotFound
+	}
+
+	return s.apply(bson.ObjectIdHex(id), func(job *Job) {
+		setJobStatus(job, JobStatusCancelled, "")
+	})
+}
@@ -0,0 +1,129 @@
+package importer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+func TestMemoryJobServiceCountsRecordsOnce(t *testing.T) {
+	service := NewMemoryJobService()
+	job, err := service.Create("rc", false, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Increment(job.ID, map[string]int{"total": 4, "converted": 4}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.FinishReading(job.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// records 1 and 2 are redelivered, record 0 is never deduplicated
+	service.AddOutcome(job.ID, 1, OutcomeCreated, nil)
+	service.AddOutcome(job.ID, 1, OutcomeCreated, nil)
+	service.AddError(job.ID, 2, JobError{ExternalID: "2", Message: "broken"})
+	service.AddError(job.ID, 2, JobError{ExternalID: "2", Message: "broken"})
+	service.AddOutcome(job.ID, 0, OutcomeUnchanged, nil)
+
+	job, err = service.FindByID(job.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Created != 1 || job.Failed != 1 || job.Unchanged != 1 || len(job.Errors) != 1 {
+		t.Errorf("created %d, failed %d with %d errors, unchanged %d, want 1 each", job.Created, job.Failed, len(job.Errors), job.Unchanged)
+	}
+	if job.Done() {
+		t.Fatalf("job is %v after 3 of 4 records", job.Status)
+	}
+
+	job, err = service.AddOutcome(job.ID, 0, OutcomeUpdated, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobStatusFinished || job.FinishedAt == nil {
+		t.Errorf("job is %v after all records, want %v", job.Status, JobStatusFinished)
+	}
+}
+
+func TestMemoryJobServiceKeepsRecordsPerJob(t *testing.T) {
+	service := NewMemoryJobService()
+	first, _ := service.Create("rc", false, "")
+	second, _ := service.Create("rc", false, "")
+
+	service.AddOutcome(first.ID, 1, OutcomeCreated, nil)
+	job, err := service.AddOutcome(second.ID, 1, OutcomeCreated, nil)
+	if err != nil || job.Created != 1 {
+		t.Errorf("record 1 of the second job = %v, %v, want it counted", job, err)
+	}
+
+	if _, err := service.AddOutcome(bson.NewObjectId(), 1, OutcomeCreated, nil); err == nil {
+		t.Error("AddOutcome of an unknown job succeeded")
+	}
+}
+
+func TestMemoryJobServiceReport(t *testing.T) {
+	service := NewMemoryJobService()
+	job, _ := service.Create("file", true, "")
+
+	for i := 1; i <= maxReportEntries+1; i++ {
+		service.AddOutcome(job.ID, i, OutcomeCreated, &ReportEntry{ExternalID: "created"})
+	}
+	service.AddOutcome(job.ID, maxReportEntries+2, OutcomeUpdated, &ReportEntry{ExternalID: "updated"})
+	service.AddOutcome(job.ID, maxReportEntries+3, OutcomeUnchanged, &ReportEntry{ExternalID: "unchanged"})
+
+	job, _ = service.FindByID(job.ID.Hex())
+	if job.Created != maxReportEntries+1 || len(job.Report.Created) != maxReportEntries {
+		t.Errorf("created %d with %d entries, want %d with %d", job.Created, len(job.Report.Created), maxReportEntries+1, maxReportEntries)
+	}
+	if len(job.Report.Updated) != 1 || job.Report.Updated[0].ExternalID != "updated" {
+		t.Errorf("updated entries = %v", job.Report.Updated)
+	}
+}
+
+func TestMemoryJobServiceCapsErrors(t *testing.T) {
+	service := NewMemoryJobService()
+	job, _ := service.Create("file", false, "")
+
+	for i := 1; i <= maxJobErrors+10; i++ {
+		service.AddError(job.ID, i, JobError{Message: "broken"})
+	}
+	last, _ := service.AddError(job.ID, maxJobErrors+11, JobError{Message: "last"})
+
+	if last.Failed != maxJobErrors+11 || len(last.Errors) != maxJobErrors {
+		t.Errorf("failed %d with %d errors, want %d with %d", last.Failed, len(last.Errors), maxJobErrors+11, maxJobErrors)
+	}
+	if last.Errors[len(last.Errors)-1].Message != "last" {
+		t.Error("the newest error was dropped instead of the oldest")
+	}
+}
+
+func TestMemoryJobServiceEndsJobsOnce(t *testing.T) {
+	service := NewMemoryJobService()
+
+	cancelled, _ := service.Create("rc", false, "")
+	job, err := service.Cancel(cancelled.ID.Hex())
+	if err != nil || job.Status != JobStatusCancelled || !job.Done() {
+		t.Fatalf("Cancel = %v, %v", job, err)
+	}
+	job, _ = service.Fail(cancelled.ID, errors.New("rc failed"))
+	if job.Status != JobStatusCancelled || job.Error != "" {
+		t.Errorf("failing a cancelled job made it %v: %v", job.Status, job.Error)
+	}
+
+	failed, _ := service.Create("rc", false, "")
+	job, _ = service.Fail(failed.ID, errors.New("rc failed"))
+	if job.Status != JobStatusFailed || job.Error != "rc failed" {
+		t.Errorf("Fail = %v: %v", job.Status, job.Error)
+	}
+	service.FinishReading(failed.ID)
+	if job, _ = service.FindByID(failed.ID.Hex()); job.Status != JobStatusFailed {
+		t.Errorf("finishing a failed job made it %v", job.Status)
+	}
+
+	if _, err := service.Cancel("nope"); err == nil {
+		t.Error("Cancel accepted an invalid id")
+	}
+}
@@ -0,0 +1,192 @@
+package importer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/gateway"
+	"github.com/dukfaar/recipeBackend/itemmapping"
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/rc"
+)
+
+const RCSource = "rc"
+
+// RCItemResolver maps RC item ids to item service ids by the item name.
+// Resolved ids are remembered in the item mapping store.
+type RCItemResolver struct {
+	RC           *rc.Client
+	Gateway      *gateway.Client
+	ItemMappings itemmapping.Store
+	NamespaceID  bson.ObjectId
+	// Workers is the number of RC items fetched in parallel.
+	Workers int
+}
+
+func NewRCItemResolver(upstreams Upstreams, namespaceID bson.ObjectId) *RCItemResolver {
+	return &RCItemResolver{
+		RC:           upstreams.RC,
+		Gateway:      upstreams.Gateway,
+		ItemMappings: upstreams.ItemMappings,
+		NamespaceID:  namespaceID,
+		Workers:      upstreams.Workers,
+	}
+}
+
+func (r *RCItemResolver) ResolveItem(ctx context.Context, id string) (bson.ObjectId, error) {
+	return resolveSingle(ctx, r, id)
+}
+
+func (r *RCItemResolver) ResolveItems(ctx context.Context, ids []string) (map[string]bson.ObjectId, error) {
+	result := make(map[string]bson.ObjectId, len(ids))
+	missing := make([]string, 0)
+
+	for _, id := range ids {
+		mapping, err := r.ItemMappings.Get(RCSource, id)
+		if err == nil {
+			result[id] = mapping.ItemID
+			continue
+		}
+		if err != itemmapping.ErrNotFound {
+			return result, err
+		}
+		missing = append(missing, id)
+	}
+
+	namesByID := r.fetchNames(ctx, missing)
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+
+	if len(namesByID) == 0 {
+		return result, nil
+	}
+
+	names := make([]string, 0, len(namesByID))
+	for _, name := range namesByID {
+		names = append(names, name)
+	}
+
+	itemIDs, err := r.Gateway.ItemIDsByNames(ctx, names, r.NamespaceID.Hex())
+	if err != nil {
+		logging.FromContext(ctx).Error("fetching service items failed", "error", err)
+		return result, err
+	}
+
+	for id, name := range namesByID {
+		itemID, ok := itemIDs[name]
+		if !ok || !bson.IsObjectIdHex(itemID) {
+			continue
+		}
+		result[id] = bson.ObjectIdHex(itemID)
+
+		err = r.ItemMappings.Set(&itemmapping.Mapping{
+			Source:     RCSource,
+			ExternalID: id,
+			ItemID:     result[id],
+			Name:       name,
+			UpdatedAt:  time.Now().UTC(),
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("storing item mapping failed", "externalId", id, "error", err)
+		}
+	}
+
+	return result, nil
+}
+
+// fetchNames gets the RC names of the items with up to Workers requests in
+// flight. Items that can't be fetched are missing from the result.
+func (r *RCItemResolver) fetchNames(ctx context.Context, ids []string) map[string]string {
+	workers := r.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		mutex     sync.Mutex
+		waitGroup sync.WaitGroup
+		queue     = make(chan string)
+		namesByID = make(map[string]string, len(ids))
+	)
+
+	for i := 0; i < workers; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+
+			for id := range queue {
+				itemData, err := r.RC.Item(ctx, id)
+				if err != nil {
+					if ctx.Err() == nil {
+						logging.FromContext(ctx).Warn("getting RC item failed", "externalId", id, "error", err)
+					}
+					continue
+				}
+
+				mutex.Lock()
+				namesByID[id] = itemData.Name
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- id
+	}
+	close(queue)
+	waitGroup.Wait()
+
+	return namesByID
+}
+
+// RCImporter pulls all recipes from RC.
+type RCImporter struct {
+	Client  *rc.Client
+	Options Options
+}
+
+func NewRCImporter(upstreams Upstreams, namespaceID bson.ObjectId) *RCImporter {
+	return &RCImporter{
+		Client: upstreams.RC,
+		Options: Options{
+			Source:      RCSource,
+			NamespaceID: namespaceID,
+			Mapping:     DefaultFieldMapping,
+			Resolver:    NewRCItemResolver(upstreams, namespaceID),
+			Workers:     upstreams.Workers,
+		},
+	}
+}
+
+func (i *RCImporter) Name() string {
+	return RCSource
+}
+
+func (i *RCImporter) Import(ctx context.Context, handle HandleFunc) error {
+	recipeData, err := i.Client.Recipes(ctx)
+
+	if err != nil {
+		logging.FromContext(ctx).Error("getting RC recipes failed", "error", err)
+		return err
+	}
+
+	if err := prefetchItems(ctx, recipeData, i.Options); err != nil {
+		return err
+	}
+
+	return convertAll(ctx, i.Options, func(emit func(map[string]interface{}) error) error {
+		for index := range recipeData {
+			if err := emit(recipeData[index]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, handle)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/itemmapping"
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/recipe"
+)
+
+type itemMergedEvent struct {
+	SourceID string `json:"sourceId"`
+	TargetID string `json:"targetId"`
+}
+
+func parseItemID(msg []byte) (bson.ObjectId, error) {
+	var id string
+	if err := json.Unmarshal(msg, &id); err != nil {
+		var item struct {
+			ID string `json:"_id"`
+		}
+		if err := json.Unmarshal(msg, &item); err != nil {
+			return "", err
+		}
+		id = item.ID
+	}
+
+	if !bson.IsObjectIdHex(id) {
+		return "", fmt.Errorf("invalid item id %q", id)
+	}
+
+	return bson.ObjectIdHex(id), nil
+}
+
+func CreateItemDeletedHandler(recipeService recipe.Service, itemMappingStore itemmapping.Store) EventHandler {
+	return func(ctx context.Context, msg []byte) error {
+		logger := logging.FromContext(ctx)
+		recipeService := recipe.WithContext(ctx, recipeService)
+
+		itemID, err := parseItemID(msg)
+		if err != nil {
+			logger.Error("parsing deleted item failed", "message", string(msg), "error", err)
+			return err
+		}
+
+		if _, err := itemMappingStore.InvalidateItem(itemID); err != nil {
+			logger.Error("invalidating item mappings failed", "itemId", itemID.Hex(), "error", err)
+			return err
+		}
+
+		recipes, err := recipeService.FindByItemID(itemID)
+		if err != nil {
+			logger.Error("finding recipes of deleted item failed", "itemId", itemID.Hex(), "error", err)
+			return err
+		}
+
+		for i := range recipes {
+			if !recipes[i].AddBrokenReference(itemID) {
+				continue
+			}
+
+			_, err := recipeService.Update(ctx, recipes[i].ID.Hex(), &recipes[i])
+			if err != nil {
+				logger.Error("marking recipe as broken failed", "recipeId", recipes[i].ID.Hex(), "error", err)
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+func CreateItemMergedHandler(recipeService recipe.Service, itemMappingStore itemmapping.Store) EventHandler {
+	return func(ctx context.Context, msg []byte) error {
+		logger := logging.FromContext(ctx)
+		recipeService := recipe.WithContext(ctx, recipeService)
+
+		var event itemMergedEvent
+		err := json.Unmarshal(msg, &event)
+
+		if err != nil {
+			logger.Error("unmarshaling item merge failed", "message", string(msg), "error", err)
+			return err
+		}
+
+		if !bson.IsObjectIdHex(event.SourceID) || !bson.IsObjectIdHex(event.TargetID) {
+			logger.Error("invalid item merge", "message", string(msg))
+			return fmt.Errorf("invalid item merge %v -> %v", event.SourceID, event.TargetID)
+		}
+
+		sourceID, targetID := bson.ObjectIdHex(event.SourceID), bson.ObjectIdHex(event.TargetID)
+
+		if _, err := itemMappingStore.InvalidateItem(sourceID); err != nil {
+			logger.Error("invalidating item mappings failed", "itemId", event.SourceID, "error", err)
+			return err
+		}
+
+		recipes, err := recipeService.FindByItemID(sourceID)
+		if err != nil {
+			logger.Error("finding recipes of merged item failed", "itemId", event.SourceID, "error", err)
+			return err
+		}
+
+		for i := range recipes {
+			if !recipes[i].ReplaceItem(sourceID, targetID) {
+				continue
+			}
+
+			_, err := recipeService.Update(ctx, recipes[i].ID.Hex(), &recipes[i])
+			if err != nil {
+				logger.Error("rewriting recipe failed", "recipeId", recipes[i].ID.Hex(), "error", err)
+				return err
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/itemmapping"
+)
+
+const (
+	defaultItemMappingPageSize = 100
+	maxItemMappingPageSize     = 1000
+)
+
+func (r *Resolver) ItemMappings(ctx context.Context, args struct {
+	Source          *string
+	First           *int32
+	AfterSource     *string
+	AfterExternalId *string
+}) (*[]*itemmapping.Resolver, error) {
+	err := checkPermission(ctx, "query.itemMappings")
+	if err != nil {
+		return nil, err
+	}
+
+	store := ctx.Value("itemMappingStore").(itemmapping.Store)
+
+	first := defaultItemMappingPageSize
+	if args.First != nil {
+		first = int(*args.First)
+	}
+	if first < 1 {
+		first = 1
+	}
+	if first > maxItemMappingPageSize {
+		first = maxItemMappingPageSize
+	}
+
+	// pages of a single source only need the last external id
+	afterSource, afterExternalID := "", ""
+	if args.AfterSource != nil {
+		afterSource = *args.AfterSource
+	} else if args.Source != nil {
+		afterSource = *args.Source
+	}
+	if args.AfterExternalId != nil {
+		if args.AfterSource == nil && args.Source == nil {
+			return nil, fmt.Errorf("afterExternalId needs source or afterSource")
+		}
+		afterExternalID = *args.AfterExternalId
+	}
+
+	mappings, err := store.List(args.Source, first, afterSource, afterExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]*itemmapping.Resolver, len(mappings))
+	for i := range mappings {
+		l[i] = &itemmapping.Resolver{Mapping: &mappings[i]}
+	}
+	return &l, nil
+}
+
+func (r *Resolver) SetItemMapping(ctx context.Context, args struct {
+	Source     string
+	ExternalId string
+	ItemId     string
+}) (*itemmapping.Resolver, error) {
+	err := checkPermission(ctx, "mutation.setItemMapping")
+	if err != nil {
+		return nil, err
+	}
+
+	if !bson.IsObjectIdHex(args.ItemId) {
+		return nil, fmt.Errorf("invalid item id %q", args.ItemId)
+	}
+
+	store := ctx.Value("itemMappingStore").(itemmapping.Store)
+
+	mapping := &itemmapping.Mapping{
+		Source:     args.Source,
+		ExternalID: args.ExternalId,
+		ItemID:     bson.ObjectIdHex(args.ItemId),
+		Manual:     true,
+		UpdatedAt:  time.Now().UTC(),
+	}
+
+	if existing, err := store.Get(args.Source, args.ExternalId); err == nil {
+		mapping.Name = existing.Name
+	}
+
+	err = store.Set(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	return &itemmapping.Resolver{Mapping: mapping}, nil
+}
+
+func (r *Resolver) DeleteItemMapping(ctx context.Context, args struct {
+	Source     string
+	ExternalId string
+}) (bool, error) {
+	err := checkPermission(ctx, "mutation.deleteItemMapping")
+	if err != nil {
+		return false, err
+	}
+
+	store := ctx.Value("itemMappingStore").(itemmapping.Store)
+
+	err = store.Delete(args.Source, args.ExternalId)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *Resolver) InvalidateItemMappings(ctx context.Context, args struct {
+	Source string
+}) (int32, error) {
+	err := checkPermission(ctx, "mutation.invalidateItemMappings")
+	if err != nil {
+		return 0, err
+	}
+
+	store := ctx.Value("itemMappingStore").(itemmapping.Store)
+
+	removed, err := store.Invalidate(args.Source)
+	return int32(removed), err
+}
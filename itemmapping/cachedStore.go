@@ -0,0 +1,98 @@
+package itemmapping
+
+import (
+	"sync"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+type cacheEntry struct {
+	mapping  Mapping
+	cachedAt time.Time
+}
+
+// CachedStore keeps recently used mappings of a backing store in memory.
+// Entries expire after the ttl, so changes made by other replicas are picked
+// up eventually; changes made through this store are visible immediately.
+type CachedStore struct {
+	backend Store
+	ttl     time.Duration
+
+	mutex   sync.RWMutex
+	entries map[mappingKey]cacheEntry
+}
+
+func NewCachedStore(backend Store, ttl time.Duration) *CachedStore {
+	return &CachedStore{
+		backend: backend,
+		ttl:     ttl,
+		entries: make(map[mappingKey]cacheEntry),
+	}
+}
+
+func (s *CachedStore) Get(source string, externalID string) (*Mapping, error) {
+	k := key(source, externalID)
+
+	s.mutex.RLock()
+	entry, ok := s.entries[k]
+	s.mutex.RUnlock()
+
+	if ok && time.Since(entry.cachedAt) < s.ttl {
+		mapping := entry.mapping
+		return &mapping, nil
+	}
+
+	mapping, err := s.backend.Get(source, externalID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache(mapping)
+	return mapping, nil
+}
+
+func (s *CachedStore) cache(mapping *Mapping) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries[mapping.key()] = cacheEntry{mapping: *mapping, cachedAt: time.Now()}
+}
+
+func (s *CachedStore) clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries = make(map[mappingKey]cacheEntry)
+}
+
+func (s *CachedStore) Set(mapping *Mapping) error {
+	if err := s.backend.Set(mapping); err != nil {
+		return err
+	}
+
+	s.cache(mapping)
+	return nil
+}
+
+func (s *CachedStore) Delete(source string, externalID string) error {
+	s.mutex.Lock()
+	delete(s.entries, key(source, externalID))
+	s.mutex.Unlock()
+
+	return s.backend.Delete(source, externalID)
+}
+
+func (s *CachedStore) Invalidate(source string) (int, error) {
+	defer s.clear()
+	return s.backend.Invalidate(source)
+}
+
+func (s *CachedStore) InvalidateItem(itemID bson.ObjectId) (int, error) {
+	defer s.clear()
+	return s.backend.InvalidateItem(itemID)
+}
+
+func (s *CachedStore) List(source *string, first int, afterSource string, afterExternalID string) ([]Mapping, error) {
+	return s.backend.List(source, first, afterSource, afterExternalID)
+}
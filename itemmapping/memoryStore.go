@@ -0,0 +1,100 @@
+package itemmapping
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+type MemoryStore struct {
+	mutex    sync.RWMutex
+	mappings map[mappingKey]Mapping
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mappings: make(map[mappingKey]Mapping),
+	}
+}
+
+func (s *MemoryStore) Get(source string, externalID string) (*Mapping, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	mapping, ok := s.mappings[key(source, externalID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &mapping, nil
+}
+
+func (s *MemoryStore) Set(mapping *Mapping) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.mappings[mapping.key()] = *mapping
+	return nil
+}
+
+func (s *MemoryStore) Delete(source string, externalID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.mappings, key(source, externalID))
+	return nil
+}
+
+func (s *MemoryStore) removeWhere(match func(*Mapping) bool) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	removed := 0
+	for k, mapping := range s.mappings {
+		if !mapping.Manual && match(&mapping) {
+			delete(s.mappings, k)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (s *MemoryStore) Invalidate(source string) (int, error) {
+	return s.removeWhere(func(mapping *Mapping) bool {
+		return mapping.Source == source
+	}), nil
+}
+
+func (s *MemoryStore) InvalidateItem(itemID bson.ObjectId) (int, error) {
+	return s.removeWhere(func(mapping *Mapping) bool {
+		return mapping.ItemID == itemID
+	}), nil
+}
+
+func (s *MemoryStore) List(source *string, first int, afterSource string, afterExternalID string) ([]Mapping, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make([]Mapping, 0)
+	for _, mapping := range s.mappings {
+		if source != nil && mapping.Source != *source {
+			continue
+		}
+		if mapping.Source < afterSource || (mapping.Source == afterSource && mapping.ExternalID <= afterExternalID) {
+			continue
+		}
+		result = append(result, mapping)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Source != result[j].Source {
+			return result[i].Source < result[j].Source
+		}
+		return result[i].ExternalID < result[j].ExternalID
+	})
+
+	if first > 0 && len(result) > first {
+		result = result[:first]
+	}
+	return result, nil
+}
@@ -0,0 +1,92 @@
+package itemmapping
+
+import (
+	mgo "github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+type MgoStore struct {
+	collection *mgo.Collection
+}
+
+// NewMgoStore fails if the indexes can't be created. Without the unique
+// index concurrent Sets would store duplicate mappings.
+func NewMgoStore(db *mgo.Database) (*MgoStore, error) {
+	collection := db.C("itemMappings")
+	err := collection.EnsureIndex(mgo.Index{
+		Key:    []string{"source", "externalId"},
+		Unique: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := collection.EnsureIndexKey("itemId"); err != nil {
+		return nil, err
+	}
+
+	return &MgoStore{
+		collection: collection,
+	}, nil
+}
+
+func (s *MgoStore) Get(source string, externalID string) (*Mapping, error) {
+	var result Mapping
+
+	err := s.collection.Find(bson.M{"source": source, "externalId": externalID}).One(&result)
+	if err == mgo.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *MgoStore) Set(mapping *Mapping) error {
+	_, err := s.collection.Upsert(bson.M{"source": mapping.Source, "externalId": mapping.ExternalID}, mapping)
+	return err
+}
+
+func (s *MgoStore) Delete(source string, externalID string) error {
+	err := s.collection.Remove(bson.M{"source": source, "externalId": externalID})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (s *MgoStore) removeAll(query bson.M) (int, error) {
+	query["manual"] = false
+
+	info, err := s.collection.RemoveAll(query)
+	if err != nil {
+		return 0, err
+	}
+	return info.Removed, nil
+}
+
+func (s *MgoStore) Invalidate(source string) (int, error) {
+	return s.removeAll(bson.M{"source": source})
+}
+
+func (s *MgoStore) InvalidateItem(itemID bson.ObjectId) (int, error) {
+	return s.removeAll(bson.M{"itemId": itemID})
+}
+
+func (s *MgoStore) List(source *string, first int, afterSource string, afterExternalID string) ([]Mapping, error) {
+	query := bson.M{
+		"$or": []bson.M{
+			{"source": bson.M{"$gt": afterSource}},
+			{"source": afterSource, "externalId": bson.M{"$gt": afterExternalID}},
+		},
+	}
+	if source != nil {
+		query["source"] = *source
+	}
+
+	result := make([]Mapping, 0)
+	err := s.collection.Find(query).Sort("source", "externalId").Limit(first).All(&result)
+
+	return result, err
+}
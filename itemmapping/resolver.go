@@ -0,0 +1,51 @@
+package itemmapping
+
+import (
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+var GraphQLType = `
+type ItemMapping {
+	source: String
+	externalId: String
+	itemId: ID
+	name: String
+	manual: Boolean
+	updatedAt: String
+}
+`
+
+type Resolver struct {
+	Mapping *Mapping
+}
+
+func (r *Resolver) Source() *string {
+	return &r.Mapping.Source
+}
+
+func (r *Resolver) ExternalID() *string {
+	return &r.Mapping.ExternalID
+}
+
+func (r *Resolver) ItemID() *graphql.ID {
+	id := graphql.ID(r.Mapping.ItemID.Hex())
+	return &id
+}
+
+func (r *Resolver) Name() *string {
+	if r.Mapping.Name == "" {
+		return nil
+	}
+	return &r.Mapping.Name
+}
+
+func (r *Resolver) Manual() *bool {
+	return &r.Mapping.Manual
+}
+
+func (r *Resolver) UpdatedAt() *string {
+	updatedAt := r.Mapping.UpdatedAt.Format(time.RFC3339)
+	return &updatedAt
+}
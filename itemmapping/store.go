@@ -0,0 +1,52 @@
+package itemmapping
+
+import (
+	"errors"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+var ErrNotFound = errors.New("item mapping not found")
+
+// Mapping links an item id of an external source to an item service id.
+// Manual mappings are overrides set by an admin and survive invalidation.
+type Mapping struct {
+	Source     string        `json:"source" bson:"source"`
+	ExternalID string        `json:"externalId" bson:"externalId"`
+	ItemID     bson.ObjectId `json:"itemId" bson:"itemId"`
+	Name       string        `json:"name,omitempty" bson:"name,omitempty"`
+	Manual     bool          `json:"manual" bson:"manual"`
+	UpdatedAt  time.Time     `json:"updatedAt" bson:"updatedAt"`
+}
+
+// mappingKey identifies a mapping in memory. Source and external id are kept
+// apart, so no source name can collide with the ids of another source.
+type mappingKey struct {
+	source     string
+	externalID string
+}
+
+func (m *Mapping) key() mappingKey {
+	return key(m.Source, m.ExternalID)
+}
+
+func key(source string, externalID string) mappingKey {
+	return mappingKey{source: source, externalID: externalID}
+}
+
+type Store interface {
+	Get(source string, externalID string) (*Mapping, error)
+	Set(mapping *Mapping) error
+	Delete(source string, externalID string) error
+
+	// Invalidate drops all automatic mappings of a source, so they are
+	// resolved again on the next import. It returns the number of dropped mappings.
+	Invalidate(source string) (int, error)
+	// InvalidateItem drops all automatic mappings that point to the item.
+	InvalidateItem(itemID bson.ObjectId) (int, error)
+
+	// List returns up to first mappings ordered by source and external id,
+	// starting after the given source and external id.
+	List(source *string, first int, afterSource string, afterExternalID string) ([]Mapping, error)
+}
@@ -0,0 +1,145 @@
+package itemmapping
+
+import (
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+func TestMemoryStoreKeepsSourcesApart(t *testing.T) {
+	store := NewMemoryStore()
+
+	// with source + ":" + id keys both would be "a:b:c"
+	first := &Mapping{Source: "a", ExternalID: "b:c", ItemID: bson.NewObjectId()}
+	second := &Mapping{Source: "a:b", ExternalID: "c", ItemID: bson.NewObjectId()}
+	for _, mapping := range []*Mapping{first, second} {
+		if err := store.Set(mapping); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []*Mapping{first, second} {
+		got, err := store.Get(want.Source, want.ExternalID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ItemID != want.ItemID {
+			t.Errorf("Get(%q, %q) = %v, want %v", want.Source, want.ExternalID, got.ItemID, want.ItemID)
+		}
+	}
+
+	if removed, _ := store.Invalidate("a"); removed != 1 {
+		t.Errorf("Invalidate removed %d mappings, want 1", removed)
+	}
+	if _, err := store.Get("a:b", "c"); err != nil {
+		t.Errorf("Invalidate of another source removed %v", second)
+	}
+}
+
+func TestMemoryStoreSetReplaces(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.Set(&Mapping{Source: "rc", ExternalID: "1", ItemID: bson.NewObjectId()})
+	replaced := bson.NewObjectId()
+	store.Set(&Mapping{Source: "rc", ExternalID: "1", ItemID: replaced})
+
+	mappings, _ := store.List(nil, 0, "", "")
+	if len(mappings) != 1 || mappings[0].ItemID != replaced {
+		t.Errorf("List = %v, want one mapping to %v", mappings, replaced)
+	}
+}
+
+func TestMemoryStoreInvalidateKeepsManualMappings(t *testing.T) {
+	store := NewMemoryStore()
+	item := bson.NewObjectId()
+
+	store.Set(&Mapping{Source: "rc", ExternalID: "1", ItemID: item})
+	store.Set(&Mapping{Source: "rc", ExternalID: "2", ItemID: item, Manual: true})
+
+	if removed, _ := store.InvalidateItem(item); removed != 1 {
+		t.Errorf("InvalidateItem removed %d mappings, want 1", removed)
+	}
+	if _, err := store.Get("rc", "2"); err != nil {
+		t.Error("InvalidateItem removed a manual mapping")
+	}
+}
+
+func TestMemoryStoreListPages(t *testing.T) {
+	store := NewMemoryStore()
+	for _, m := range []struct{ source, id string }{{"b", "1"}, {"a", "2"}, {"a", "1"}, {"b", "0"}} {
+		store.Set(&Mapping{Source: m.source, ExternalID: m.id, ItemID: bson.NewObjectId()})
+	}
+
+	page, _ := store.List(nil, 2, "a", "1")
+	if len(page) != 2 || page[0].Source != "a" || page[0].ExternalID != "2" || page[1].Source != "b" || page[1].ExternalID != "0" {
+		t.Errorf("List after a/1 = %v, want a/2 and b/0", page)
+	}
+
+	source := "b"
+	page, _ = store.List(&source, 10, "", "")
+	if len(page) != 2 {
+		t.Errorf("List of source b = %v, want 2 mappings", page)
+	}
+}
+
+// countingStore counts the Gets reaching the backend of a CachedStore.
+type countingStore struct {
+	*MemoryStore
+	gets int
+}
+
+func (s *countingStore) Get(source string, externalID string) (*Mapping, error) {
+	s.gets++
+	return s.MemoryStore.Get(source, externalID)
+}
+
+func TestCachedStore(t *testing.T) {
+	backend := &countingStore{MemoryStore: NewMemoryStore()}
+	store := NewCachedStore(backend, time.Hour)
+
+	item := bson.NewObjectId()
+	backend.Set(&Mapping{Source: "rc", ExternalID: "1", ItemID: item})
+
+	for i := 0; i < 3; i++ {
+		if mapping, err := store.Get("rc", "1"); err != nil || mapping.ItemID != item {
+			t.Fatalf("Get = %v, %v, want %v", mapping, err, item)
+		}
+	}
+	if backend.gets != 1 {
+		t.Errorf("backend was asked %d times, want once", backend.gets)
+	}
+
+	if _, err := store.Invalidate("rc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("rc", "1"); err != ErrNotFound {
+		t.Errorf("Get after Invalidate = %v, want ErrNotFound", err)
+	}
+
+	manual := &Mapping{Source: "rc", ExternalID: "1", ItemID: bson.NewObjectId(), Manual: true}
+	store.Set(manual)
+	if mapping, _ := backend.MemoryStore.Get("rc", "1"); mapping == nil || mapping.ItemID != manual.ItemID {
+		t.Error("Set didn't reach the backend")
+	}
+
+	store.Delete("rc", "1")
+	if _, err := store.Get("rc", "1"); err != ErrNotFound {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCachedStoreExpires(t *testing.T) {
+	backend := &countingStore{MemoryStore: NewMemoryStore()}
+	store := NewCachedStore(backend, time.Nanosecond)
+
+	backend.Set(&Mapping{Source: "rc", ExternalID: "1", ItemID: bson.NewObjectId()})
+	store.Get("rc", "1")
+	time.Sleep(time.Millisecond)
+
+	changed := bson.NewObjectId()
+	backend.Set(&Mapping{Source: "rc", ExternalID: "1", ItemID: changed})
+	if mapping, _ := store.Get("rc", "1"); mapping == nil || mapping.ItemID != changed {
+		t.Errorf("Get = %v, want the changed mapping after the ttl", mapping)
+	}
+}
@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+var GraphQLType = `
+type BackgroundJob {
+	id: ID
+	name: String
+	startedAt: String
+}
+`
+
+type Resolver struct {
+	Job *Job
+}
+
+func (r *Resolver) ID() *graphql.ID {
+	id := graphql.ID(r.Job.ID)
+	return &id
+}
+
+func (r *Resolver) Name() *string {
+	return &r.Job.Name
+}
+
+func (r *Resolver) StartedAt() *string {
+	startedAt := r.Job.StartedAt.Format(time.RFC3339)
+	return &startedAt
+}
@@ -0,0 +1,162 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/logging"
+)
+
+// Job is a task running on a Runner.
+type Job struct {
+	ID        string
+	Name      string
+	StartedAt time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Done is closed when the job has returned.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Runner runs long-running tasks detached from the request that started them.
+// All jobs derive their context from the context the runner was created with,
+// so they see the same services but are only cancelled explicitly or on shutdown.
+type Runner struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex     sync.Mutex
+	jobs      map[string]*Job
+	waitGroup sync.WaitGroup
+	closed    bool
+}
+
+func NewRunner(ctx context.Context) *Runner {
+	runnerCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	return &Runner{
+		ctx:    runnerCtx,
+		cancel: cancel,
+		jobs:   make(map[string]*Job),
+	}
+}
+
+// Start runs fn under a new id.
+func (r *Runner) Start(name string, fn func(ctx context.Context) error) (*Job, error) {
+	return r.StartWithID(bson.NewObjectId().Hex(), name, fn)
+}
+
+// StartWithID runs fn as the job with the given id, so it can be cancelled by
+// an id that is known elsewhere, like the id of an import job.
+func (r *Runner) StartWithID(id string, name string, fn func(ctx context.Context) error) (*Job, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return nil, fmt.Errorf("job runner is shutting down")
+	}
+	if _, ok := r.jobs[id]; ok {
+		return nil, fmt.Errorf("job %v is already running", id)
+	}
+
+	jobCtx, cancel := context.WithCancel(r.ctx)
+	job := &Job{
+		ID:        id,
+		Name:      name,
+		StartedAt: time.Now().UTC(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	r.jobs[id] = job
+	r.waitGroup.Add(1)
+
+	go r.run(jobCtx, job, fn)
+
+	return job, nil
+}
+
+func (r *Runner) run(ctx context.Context, job *Job, fn func(ctx context.Context) error) {
+	defer r.waitGroup.Done()
+	defer close(job.done)
+	defer job.cancel()
+	defer func() {
+		r.mutex.Lock()
+		delete(r.jobs, job.ID)
+		r.mutex.Unlock()
+	}()
+
+	logger := logging.FromContext(ctx).With("jobId", job.ID, "job", job.Name)
+	ctx = logging.WithLogger(ctx, logger)
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logger.Error("job panicked", "panic", recovered, "stack", string(debug.Stack()))
+		}
+	}()
+
+	err := fn(ctx)
+	if err != nil && err != context.Canceled {
+		logger.Error("job failed", "error", err)
+	}
+}
+
+// Cancel cancels a job running on this runner. It reports whether the job was found.
+func (r *Runner) Cancel(id string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	job, ok := r.jobs[id]
+	if ok {
+		job.cancel()
+	}
+	return ok
+}
+
+// List returns the running jobs, oldest first.
+func (r *Runner) List() []*Job {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	result := make([]*Job, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		result = append(result, job)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartedAt.Before(result[j].StartedAt)
+	})
+	return result
+}
+
+// Shutdown stops accepting jobs, cancels all running ones and waits for them
+// to return until ctx expires.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.mutex.Lock()
+	r.closed = true
+	r.mutex.Unlock()
+
+	r.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.waitGroup.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type contextKey string
+
+// blockUntilCancelled returns a job function that waits for its context and
+// sends the value of key it saw.
+func blockUntilCancelled(seen chan<- interface{}) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		<-ctx.Done()
+		seen <- ctx.Value(contextKey("service"))
+		return ctx.Err()
+	}
+}
+
+func waitDone(t *testing.T, job *Job) {
+	t.Helper()
+
+	select {
+	case <-job.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("job %v didn't return", job.ID)
+	}
+}
+
+func TestRunnerOutlivesRequest(t *testing.T) {
+	requestCtx, cancelRequest := context.WithCancel(context.WithValue(context.Background(), contextKey("service"), "recipes"))
+	runner := NewRunner(requestCtx)
+
+	seen := make(chan interface{}, 1)
+	job, err := runner.StartWithID("import", "rcRecipeImport", blockUntilCancelled(seen))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancelRequest()
+	select {
+	case <-job.Done():
+		t.Fatal("job was cancelled with the context of the runner")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if _, err := runner.StartWithID("import", "rcRecipeImport", blockUntilCancelled(seen)); err == nil {
+		t.Error("started a second job with a running id")
+	}
+	if jobs := runner.List(); len(jobs) != 1 || jobs[0] != job {
+		t.Errorf("List = %v, want only %v", jobs, job.ID)
+	}
+
+	if !runner.Cancel("import") {
+		t.Fatal("Cancel didn't find the job")
+	}
+	waitDone(t, job)
+	if value := <-seen; value != "recipes" {
+		t.Errorf("job saw service %v, want the one of the runner's context", value)
+	}
+
+	if runner.Cancel("import") {
+		t.Error("Cancel found a returned job")
+	}
+	if jobs := runner.List(); len(jobs) != 0 {
+		t.Errorf("List = %v after the job returned", jobs)
+	}
+}
+
+func TestRunnerSurvivesPanics(t *testing.T) {
+	runner := NewRunner(context.Background())
+
+	job, err := runner.Start("panic", func(ctx context.Context) error {
+		panic("broken")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, job)
+
+	if err := runner.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunnerShutdown(t *testing.T) {
+	runner := NewRunner(context.Background())
+
+	seen := make(chan interface{}, 2)
+	first, _ := runner.Start("first", blockUntilCancelled(seen))
+	second, _ := runner.Start("second", blockUntilCancelled(seen))
+
+	if err := runner.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, first)
+	waitDone(t, second)
+
+	if _, err := runner.Start("late", blockUntilCancelled(seen)); err == nil {
+		t.Error("started a job after shutdown")
+	}
+}
+
+func TestRunnerShutdownTimeout(t *testing.T) {
+	runner := NewRunner(context.Background())
+
+	release := make(chan struct{})
+	defer close(release)
+	runner.Start("stuck", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := runner.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown = %v, want to give up on the stuck job", err)
+	}
+}
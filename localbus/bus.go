@@ -0,0 +1,192 @@
+package localbus
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxAttempts is how often a message is handed to a handler before it
+	// is dropped, nsq's default.
+	MaxAttempts = 5
+	// RequeueDelay is the delay before a failed message is retried, it grows
+	// linearly with the attempts.
+	RequeueDelay = 100 * time.Millisecond
+)
+
+type message struct {
+	body     []byte
+	attempts int
+}
+
+// Bus is an in-process eventbus with the semantics of nsq channels:
+// every channel of a topic gets its own copy of a message, and the handlers
+// registered on the same channel share its messages. A message a handler
+// returns an error for is requeued until MaxAttempts is reached.
+//
+// Messages emitted to a topic without any channel are dropped.
+type Bus struct {
+	Logger *slog.Logger
+
+	mutex    sync.Mutex
+	topics   map[string]map[string]*channel
+	stopped  bool
+	handlers sync.WaitGroup
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		Logger: slog.Default(),
+		topics: make(map[string]map[string]*channel),
+	}
+}
+
+// Emit sends data as json, like the nsq eventbus does.
+func (b *Bus) Emit(topic string, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		b.Logger.Error("marshaling event failed", "topic", topic, "error", err)
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.stopped {
+		return
+	}
+
+	for _, c := range b.topics[topic] {
+		c.push(&message{body: body})
+	}
+}
+
+func (b *Bus) On(topic string, channelName string, handler func([]byte) error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.stopped {
+		return
+	}
+
+	channels, ok := b.topics[topic]
+	if !ok {
+		channels = make(map[string]*channel)
+		b.topics[topic] = channels
+	}
+
+	c, ok := channels[channelName]
+	if !ok {
+		c = newChannel(topic, channelName, b.Logger)
+		channels[channelName] = c
+	}
+
+	b.handlers.Add(1)
+	go func() {
+		defer b.handlers.Done()
+		c.consume(handler)
+	}()
+}
+
+// Stop drops all queued messages and waits for the running handlers.
+func (b *Bus) Stop() {
+	b.mutex.Lock()
+	b.stopped = true
+	for _, channels := range b.topics {
+		for _, c := range channels {
+			c.close()
+		}
+	}
+	b.mutex.Unlock()
+
+	b.handlers.Wait()
+}
+
+// channel is an unbounded queue, so handlers can emit to topics they consume
+// without blocking on themselves.
+type channel struct {
+	topic  string
+	name   string
+	logger *slog.Logger
+
+	mutex  sync.Mutex
+	ready  *sync.Cond
+	queue  []*message
+	closed bool
+}
+
+func newChannel(topic string, name string, logger *slog.Logger) *channel {
+	c := &channel{
+		topic:  topic,
+		name:   name,
+		logger: logger,
+	}
+	c.ready = sync.NewCond(&c.mutex)
+	return c
+}
+
+func (c *channel) push(msg *message) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	c.queue = append(c.queue, msg)
+	c.ready.Signal()
+}
+
+func (c *channel) pop() (*message, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for len(c.queue) == 0 && !c.closed {
+		c.ready.Wait()
+	}
+
+	if c.closed {
+		return nil, false
+	}
+
+	msg := c.queue[0]
+	c.queue[0] = nil
+	c.queue = c.queue[1:]
+	return msg, true
+}
+
+func (c *channel) close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.closed = true
+	c.queue = nil
+	c.ready.Broadcast()
+}
+
+func (c *channel) consume(handler func([]byte) error) {
+	for {
+		msg, ok := c.pop()
+		if !ok {
+			return
+		}
+
+		msg.attempts++
+		if err := handler(msg.body); err != nil {
+			c.requeue(msg, err)
+		}
+	}
+}
+
+func (c *channel) requeue(msg *message, err error) {
+	if msg.attempts >= MaxAttempts {
+		c.logger.Error("giving up on message", "topic", c.topic, "channel", c.name, "attempts", msg.attempts, "error", err)
+		return
+	}
+
+	time.AfterFunc(time.Duration(msg.attempts)*RequeueDelay, func() {
+		c.push(msg)
+	})
+}
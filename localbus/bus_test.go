@@ -0,0 +1,183 @@
+package localbus
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const waitTimeout = 5 * time.Second
+
+func receive(t *testing.T, messages <-chan string) string {
+	t.Helper()
+
+	select {
+	case msg := <-messages:
+		return msg
+	case <-time.After(waitTimeout):
+		t.Fatal("timed out waiting for a message")
+		return ""
+	}
+}
+
+func expectNothing(t *testing.T, messages <-chan string) {
+	t.Helper()
+
+	select {
+	case msg := <-messages:
+		t.Fatalf("unexpected message %v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEveryChannelGetsACopy(t *testing.T) {
+	bus := NewBus()
+	defer bus.Stop()
+
+	recipes := make(chan string, 10)
+	audit := make(chan string, 10)
+	other := make(chan string, 10)
+	bus.On("item.deleted", "recipe", func(msg []byte) error { recipes <- string(msg); return nil })
+	bus.On("item.deleted", "audit", func(msg []byte) error { audit <- string(msg); return nil })
+	bus.On("item.merged", "recipe", func(msg []byte) error { other <- string(msg); return nil })
+
+	bus.Emit("item.deleted", "iron")
+
+	if msg := receive(t, recipes); msg != `"iron"` {
+		t.Errorf("recipe channel got %v", msg)
+	}
+	if msg := receive(t, audit); msg != `"iron"` {
+		t.Errorf("audit channel got %v", msg)
+	}
+	expectNothing(t, recipes)
+	expectNothing(t, other)
+}
+
+func TestHandlersOfAChannelShareItsMessages(t *testing.T) {
+	bus := NewBus()
+	defer bus.Stop()
+
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	var handled int32
+	handler := func(name string) func([]byte) error {
+		return func(msg []byte) error {
+			started <- name
+			<-release
+			atomic.AddInt32(&handled, 1)
+			return nil
+		}
+	}
+	bus.On("import.recipe", "recipe", handler("first"))
+	bus.On("import.recipe", "recipe", handler("second"))
+
+	bus.Emit("import.recipe", 1)
+	bus.Emit("import.recipe", 2)
+
+	// both handlers are blocked on a message, so each got one of them
+	if a, b := receive(t, started), receive(t, started); a == b {
+		t.Errorf("both messages went to the %v handler", a)
+	}
+	expectNothing(t, started)
+
+	close(release)
+	deadline := time.Now().Add(waitTimeout)
+	for atomic.LoadInt32(&handled) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if handled != 2 {
+		t.Errorf("handled %d messages, want 2", handled)
+	}
+}
+
+func TestFailedMessagesAreRequeuedUpToMaxAttempts(t *testing.T) {
+	bus := NewBus()
+	defer bus.Stop()
+
+	attempts := make(chan string, MaxAttempts+1)
+	bus.On("item.merged", "recipe", func(msg []byte) error {
+		attempts <- string(msg)
+		return errors.New("database unavailable")
+	})
+
+	bus.Emit("item.merged", "iron")
+
+	for i := 0; i < MaxAttempts; i++ {
+		receive(t, attempts)
+	}
+	// the last requeue delay is MaxAttempts*RequeueDelay
+	select {
+	case <-attempts:
+		t.Fatalf("message was handled more than %d times", MaxAttempts)
+	case <-time.After((MaxAttempts + 1) * RequeueDelay):
+	}
+}
+
+func TestRequeuedMessageSucceeds(t *testing.T) {
+	bus := NewBus()
+	defer bus.Stop()
+
+	var calls int32
+	done := make(chan string, 1)
+	bus.On("item.merged", "recipe", func(msg []byte) error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errors.New("database unavailable")
+		}
+		done <- string(msg)
+		return nil
+	})
+
+	bus.Emit("item.merged", "iron")
+
+	if msg := receive(t, done); msg != `"iron"` {
+		t.Errorf("got %v", msg)
+	}
+	if calls != 3 {
+		t.Errorf("handler called %d times, want 3", calls)
+	}
+}
+
+func TestStopWaitsForRunningHandlers(t *testing.T) {
+	bus := NewBus()
+
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	var finished int32
+	bus.On("import.recipe", "recipe", func(msg []byte) error {
+		started <- string(msg)
+		<-release
+		atomic.AddInt32(&finished, 1)
+		return nil
+	})
+
+	bus.Emit("import.recipe", 1)
+	bus.Emit("import.recipe", 2)
+	receive(t, started)
+
+	var stopped sync.WaitGroup
+	stopped.Add(1)
+	stopReturned := make(chan struct{})
+	go func() {
+		defer stopped.Done()
+		bus.Stop()
+		close(stopReturned)
+	}()
+
+	select {
+	case <-stopReturned:
+		t.Fatal("Stop returned while a handler was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	stopped.Wait()
+
+	if finished != 1 {
+		t.Errorf("%d handlers finished, want the running one", finished)
+	}
+	// the queued message was dropped, and so are new ones
+	bus.Emit("import.recipe", 3)
+	expectNothing(t, started)
+}
@@ -10,4 +10,5 @@ export CLIENT_ID=dukfaar-cloud-internal
 export CLIENT_SECRET='i am a ninja cat'
 export API_GATEWAY_HOST=localhost
 export API_GATEWAY_PORT=8090
-export API_GATEWAY_PATH=/graphql
\ No newline at end of file
+export API_GATEWAY_PATH=/graphql
+export IMPORT_DIR=./import
\ No newline at end of file
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type contextKey string
+
+const (
+	loggerKey    contextKey = "logger"
+	requestIDKey contextKey = "requestId"
+)
+
+// New creates a logger writing format ("json" or "text") at level
+// ("debug", "info", "warn" or "error") to w.
+func New(w io.Writer, format string, level string) (*slog.Logger, error) {
+	var slogLevel slog.Level
+	if err := slogLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	options := &slog.HandlerOptions{Level: slogLevel}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	}
+
+	return nil, fmt.Errorf("invalid log format %q", format)
+}
+
+// WithLogger stores logger in ctx, FromContext returns it.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext returns the logger of ctx, or slog.Default if there is none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+// WithRequestID stores the correlation id in ctx and adds it to the logger.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+
+	ctx = context.WithValue(ctx, requestIDKey, requestID)
+	return WithLogger(ctx, FromContext(ctx).With("requestId", requestID))
+}
+
+// RequestID returns the correlation id of ctx, or an empty string.
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
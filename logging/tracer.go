@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"context"
+	"time"
+
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/introspection"
+	"github.com/graph-gophers/graphql-go/trace"
+)
+
+// Tracer logs every GraphQL operation with its duration and passes
+// everything on to Next.
+type Tracer struct {
+	Next trace.Tracer
+	// Actor returns the user of a request, it is added to the log entries
+	Actor func(ctx context.Context) string
+}
+
+func (t Tracer) TraceQuery(ctx context.Context, queryString string, operationName string, variables map[string]interface{}, varTypes map[string]*introspection.Type) (context.Context, trace.TraceQueryFinishFunc) {
+	start := time.Now()
+	ctx, finish := t.Next.TraceQuery(ctx, queryString, operationName, variables, varTypes)
+
+	if operationName == "" {
+		operationName = "anonymous"
+	}
+
+	logger := FromContext(ctx).With("operation", operationName)
+	if t.Actor != nil {
+		if actor := t.Actor(ctx); actor != "" {
+			logger = logger.With("user", actor)
+		}
+	}
+	ctx = WithLogger(ctx, logger)
+
+	return ctx, func(errs []*errors.QueryError) {
+		if len(errs) > 0 {
+			messages := make([]string, len(errs))
+			for i := range errs {
+				messages[i] = errs[i].Message
+			}
+			logger.Warn("graphql operation failed", "duration", time.Since(start), "errors", messages)
+		} else {
+			logger.Info("graphql operation", "duration", time.Since(start))
+		}
+
+		finish(errs)
+	}
+}
+
+func (t Tracer) TraceField(ctx context.Context, label, typeName, fieldName string, trivial bool, args map[string]interface{}) (context.Context, trace.TraceFieldFinishFunc) {
+	return t.Next.TraceField(ctx, label, typeName, fieldName, trivial, args)
+}
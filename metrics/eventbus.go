@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/dukfaar/goUtils/eventbus"
+)
+
+// EventBus counts emitted events and measures the handlers of the wrapped bus.
+type EventBus struct {
+	eventbus.EventBus
+}
+
+func NewEventBus(bus eventbus.EventBus) *EventBus {
+	return &EventBus{
+		EventBus: bus,
+	}
+}
+
+func (b *EventBus) Emit(topic string, data interface{}) {
+	EventsEmitted.WithLabelValues(topic).Inc()
+	b.EventBus.Emit(topic, data)
+}
+
+func (b *EventBus) On(topic string, channel string, handler func([]byte) error) {
+	b.EventBus.On(topic, channel, func(msg []byte) error {
+		start := time.Now()
+		err := handler(msg)
+
+		EventHandlerDuration.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+		if err != nil {
+			EventHandlerFailures.WithLabelValues(topic).Inc()
+		}
+
+		return err
+	})
+}
+
+// Unwrap returns the wrapped bus, so shutdown can find out whether it can be
+// stopped.
+func (b *EventBus) Unwrap() eventbus.EventBus {
+	return b.EventBus
+}
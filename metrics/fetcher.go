@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"time"
+
+	dukGraphql "github.com/dukfaar/goUtils/graphql"
+)
+
+// Fetcher measures the requests of the wrapped fetcher to the api gateway.
+type Fetcher struct {
+	Fetcher dukGraphql.Fetcher
+}
+
+func (f *Fetcher) Fetch(request dukGraphql.Request) (interface{}, error) {
+	start := time.Now()
+	result, err := f.Fetcher.Fetch(request)
+
+	GatewayFetchDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		GatewayFetchErrors.Inc()
+	}
+
+	return result, err
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	GraphQLOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "recipe_graphql_operation_duration_seconds",
+		Help: "Duration of GraphQL operations, by their root field.",
+	}, []string{"type", "operation"})
+	GraphQLOperationErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipe_graphql_operation_errors_total",
+		Help: "GraphQL operations that returned errors.",
+	}, []string{"type", "operation"})
+	GraphQLResolverDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "recipe_graphql_resolver_duration_seconds",
+		Help:    "Duration of non-trivial GraphQL field resolvers.",
+		Buckets: []float64{.0005, .001, .005, .01, .05, .1, .5, 1, 5},
+	}, []string{"type", "field"})
+	GraphQLResolverErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipe_graphql_resolver_errors_total",
+		Help: "GraphQL field resolvers that returned an error.",
+	}, []string{"type", "field"})
+	GraphQLComplexity = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "recipe_graphql_operation_complexity",
+		Help:    "Computed complexity of GraphQL operations.",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 9),
+	}, []string{"type"})
+	GraphQLRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipe_graphql_rejected_total",
+		Help: "GraphQL operations rejected before execution.",
+	}, []string{"reason"})
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipe_rate_limited_total",
+		Help: "Requests rejected by a rate limit, by bucket.",
+	}, []string{"bucket"})
+	PersistedQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipe_graphql_persisted_queries_total",
+		Help: "Requests with persisted queries by result: hit, miss, registered, tooLong or rejected.",
+	}, []string{"result"})
+
+	ServiceDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "recipe_service_duration_seconds",
+		Help:    "Duration of recipe service methods.",
+		Buckets: []float64{.0005, .001, .005, .01, .05, .1, .5, 1, 5},
+	}, []string{"method"})
+	MongoErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipe_mongo_errors_total",
+		Help: "Errors of recipe service methods, except for not found.",
+	}, []string{"method"})
+	RecipeChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipe_changes_total",
+		Help: "Created, updated and deleted recipes.",
+	}, []string{"type", "namespace"})
+
+	EventsEmitted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipe_events_emitted_total",
+		Help: "Events emitted to the eventbus.",
+	}, []string{"topic"})
+	EventHandlerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "recipe_event_handler_duration_seconds",
+		Help: "Duration of eventbus handlers.",
+	}, []string{"topic"})
+	EventHandlerFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipe_event_handler_failures_total",
+		Help: "Eventbus handlers that returned an error.",
+	}, []string{"topic"})
+
+	GatewayFetchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name: "recipe_gateway_fetch_duration_seconds",
+		Help: "Duration of requests to the api gateway.",
+	})
+	GatewayFetchErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "recipe_gateway_fetch_errors_total",
+		Help: "Failed requests to the api gateway.",
+	})
+
+	ImportJobsStarted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipe_import_jobs_started_total",
+		Help: "Started import jobs.",
+	}, []string{"source", "dry_run"})
+	ImportRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipe_import_records_total",
+		Help: "Imported records by outcome, failed records count as outcome failed.",
+	}, []string{"source", "outcome", "namespace"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		GraphQLOperationDuration,
+		GraphQLOperationErrors,
+		GraphQLResolverDuration,
+		GraphQLResolverErrors,
+		GraphQLComplexity,
+		GraphQLRejected,
+		RateLimited,
+		PersistedQueries,
+		ServiceDuration,
+		MongoErrors,
+		RecipeChanges,
+		EventsEmitted,
+		EventHandlerDuration,
+		EventHandlerFailures,
+		GatewayFetchDuration,
+		GatewayFetchErrors,
+		ImportJobsStarted,
+		ImportRecords,
+	)
+}
+
+// ObserveImportRecord counts a record of a (non dry run) import.
+func ObserveImportRecord(source string, outcome string, namespaceID *bson.ObjectId) {
+	namespace := ""
+	if namespaceID != nil {
+		namespace = namespaceID.Hex()
+	}
+	ImportRecords.WithLabelValues(source, outcome, namespace).Inc()
+}
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	mgo "github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/recipe"
+)
+
+// RecipeService measures the methods of the wrapped recipe.Service that talk
+// to the storage. The query builders are passed through as they are.
+type RecipeService struct {
+	recipe.Service
+}
+
+func NewRecipeService(service recipe.Service) *RecipeService {
+	return &RecipeService{
+		Service: service,
+	}
+}
+
+func observe(method string, start time.Time, err error) {
+	ServiceDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil && err != mgo.ErrNotFound {
+		MongoErrors.WithLabelValues(method).Inc()
+	}
+}
+
+func observeChange(changeType string, model *recipe.Model) {
+	namespace := ""
+	if model != nil && model.NamespaceID != nil {
+		namespace = model.NamespaceID.Hex()
+	}
+	RecipeChanges.WithLabelValues(changeType, namespace).Inc()
+}
+
+func (s *RecipeService) Create(ctx context.Context, model *recipe.Model) (*recipe.Model, error) {
+	start := time.Now()
+	result, err := s.Service.Create(ctx, model)
+	observe("Create", start, err)
+	if err == nil {
+		observeChange("created", result)
+	}
+	return result, err
+}
+
+func (s *RecipeService) Update(ctx context.Context, id string, input interface{}) (*recipe.Model, error) {
+	start := time.Now()
+	result, err := s.Service.Update(ctx, id, input)
+	observe("Update", start, err)
+	if err == nil {
+		observeChange("updated", result)
+	}
+	return result, err
+}
+
+func (s *RecipeService) DeleteByID(ctx context.Context, id string) (string, error) {
+	start := time.Now()
+	result, err := s.Service.DeleteByID(ctx, id)
+	observe("DeleteByID", start, err)
+	if err == nil {
+		// the namespace of deleted recipes isn't known without another query
+		observeChange("deleted", nil)
+	}
+	return result, err
+}
+
+func (s *RecipeService) FindByID(id string) (*recipe.Model, error) {
+	start := time.Now()
+	result, err := s.Service.FindByID(id)
+	observe("FindByID", start, err)
+	return result, err
+}
+
+func (s *RecipeService) FindByItemID(itemID bson.ObjectId) ([]recipe.Model, error) {
+	start := time.Now()
+	result, err := s.Service.FindByItemID(itemID)
+	observe("FindByItemID", start, err)
+	return result, err
+}
+
+func (s *RecipeService) FindByNamespaceID(namespaceID bson.ObjectId) ([]recipe.Model, error) {
+	start := time.Now()
+	result, err := s.Service.FindByNamespaceID(namespaceID)
+	observe("FindByNamespaceID", start, err)
+	return result, err
+}
+
+func (s *RecipeService) FindByExternalID(source string, externalID string) (*recipe.Model, error) {
+	start := time.Now()
+	result, err := s.Service.FindByExternalID(source, externalID)
+	observe("FindByExternalID", start, err)
+	return result, err
+}
+
+func (s *RecipeService) CountByNamespace() ([]recipe.NamespaceCount, error) {
+	start := time.Now()
+	result, err := s.Service.CountByNamespace()
+	observe("CountByNamespace", start, err)
+	return result, err
+}
+
+func (s *RecipeService) HasElementBeforeID(id string) (bool, error) {
+	start := time.Now()
+	result, err := s.Service.HasElementBeforeID(id)
+	observe("HasElementBeforeID", start, err)
+	return result, err
+}
+
+func (s *RecipeService) HasElementAfterID(id string) (bool, error) {
+	start := time.Now()
+	result, err := s.Service.HasElementAfterID(id)
+	observe("HasElementAfterID", start, err)
+	return result, err
+}
+
+func (s *RecipeService) Count() (int, error) {
+	start := time.Now()
+	result, err := s.Service.Count()
+	observe("Count", start, err)
+	return result, err
+}
+
+func (s *RecipeService) List(first *int32, last *int32, before *string, after *string) ([]recipe.Model, error) {
+	start := time.Now()
+	result, err := s.Service.List(first, last, before, after)
+	observe("List", start, err)
+	return result, err
+}
+
+func (s *RecipeService) HasElementBeforeIDWithQuery(query bson.M, id string) (bool, error) {
+	start := time.Now()
+	result, err := s.Service.HasElementBeforeIDWithQuery(query, id)
+	observe("HasElementBeforeIDWithQuery", start, err)
+	return result, err
+}
+
+func (s *RecipeService) HasElementAfterIDWithQuery(query bson.M, id string) (bool, error) {
+	start := time.Now()
+	result, err := s.Service.HasElementAfterIDWithQuery(query, id)
+	observe("HasElementAfterIDWithQuery", start, err)
+	return result, err
+}
+
+func (s *RecipeService) CountWithQuery(query bson.M) (int, error) {
+	start := time.Now()
+	result, err := s.Service.CountWithQuery(query)
+	observe("CountWithQuery", start, err)
+	return result, err
+}
+
+func (s *RecipeService) PerformQuery(query bson.M) *recipe.Model {
+	start := time.Now()
+	result := s.Service.PerformQuery(query)
+	observe("PerformQuery", start, nil)
+	return result
+}
+
+func (s *RecipeService) PerformListQuery(query bson.M, first *int32, last *int32, before *string, after *string) ([]recipe.Model, error) {
+	start := time.Now()
+	result, err := s.Service.PerformListQuery(query, first, last, before, after)
+	observe("PerformListQuery", start, err)
+	return result, err
+}
+
+func (s *RecipeService) Iterate(query bson.M, handle func(*recipe.Model) error) error {
+	start := time.Now()
+	err := s.Service.Iterate(query, handle)
+	observe("Iterate", start, err)
+	return err
+}
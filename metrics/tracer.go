@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/introspection"
+	"github.com/graph-gophers/graphql-go/trace"
+
+	"github.com/dukfaar/recipeBackend/complexity"
+)
+
+// Tracer records GraphQL metrics and passes everything on to Next.
+//
+// Operations are labeled by the root field they select, the operation names
+// are chosen by clients and would make the label unbounded. Operations
+// selecting several root fields, or none in RootFields, are labeled "other".
+type Tracer struct {
+	Next trace.Tracer
+	// RootFields are the known root fields as "type.field", like "query.recipes"
+	RootFields map[string]bool
+}
+
+// RootFields returns the root fields of schema, for Tracer.RootFields.
+func RootFields(schema *introspection.Schema) map[string]bool {
+	rootFields := make(map[string]bool)
+	for operationType, rootType := range map[string]*introspection.Type{
+		"query":        schema.QueryType(),
+		"mutation":     schema.MutationType(),
+		"subscription": schema.SubscriptionType(),
+	} {
+		if rootType == nil {
+			continue
+		}
+		fields := rootType.Fields(&struct{ IncludeDeprecated bool }{true})
+		if fields == nil {
+			continue
+		}
+		for _, field := range *fields {
+			rootFields[operationType+"."+field.Name()] = true
+		}
+	}
+	return rootFields
+}
+
+// operationType guesses the type from the query string, which is good
+// enough for documents with a single operation.
+func operationType(queryString string) string {
+	query := strings.TrimSpace(queryString)
+	switch {
+	case strings.HasPrefix(query, "mutation"):
+		return "mutation"
+	case strings.HasPrefix(query, "subscription"):
+		return "subscription"
+	}
+	return "query"
+}
+
+// operationLabels returns the operation type and the root field an
+// operation is counted under.
+func (t Tracer) operationLabels(queryString string, operationName string) []string {
+	document, err := complexity.Parse(queryString)
+	if err != nil {
+		return []string{operationType(queryString), "other"}
+	}
+	operation, err := document.Operation(operationName)
+	if err != nil {
+		return []string{operationType(queryString), "other"}
+	}
+
+	name := "other"
+	for i, field := range document.RootFields(operation) {
+		if i > 0 && field != name {
+			name = "other"
+			break
+		}
+		name = field
+	}
+	if !t.RootFields[operation.Type+"."+name] {
+		name = "other"
+	}
+
+	return []string{operation.Type, name}
+}
+
+func (t Tracer) TraceQuery(ctx context.Context, queryString string, operationName string, variables map[string]interface{}, varTypes map[string]*introspection.Type) (context.Context, trace.TraceQueryFinishFunc) {
+	start := time.Now()
+	ctx, finish := t.Next.TraceQuery(ctx, queryString, operationName, variables, varTypes)
+
+	labels := t.operationLabels(queryString, operationName)
+
+	return ctx, func(errs []*errors.QueryError) {
+		GraphQLOperationDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		if len(errs) > 0 {
+			GraphQLOperationErrors.WithLabelValues(labels...).Inc()
+		}
+		finish(errs)
+	}
+}
+
+func (t Tracer) TraceField(ctx context.Context, label, typeName, fieldName string, trivial bool, args map[string]interface{}) (context.Context, trace.TraceFieldFinishFunc) {
+	ctx, finish := t.Next.TraceField(ctx, label, typeName, fieldName, trivial, args)
+	if trivial {
+		return ctx, finish
+	}
+
+	start := time.Now()
+	return ctx, func(err *errors.QueryError) {
+		GraphQLResolverDuration.WithLabelValues(typeName, fieldName).Observe(time.Since(start).Seconds())
+		if err != nil {
+			GraphQLResolverErrors.WithLabelValues(typeName, fieldName).Inc()
+		}
+		finish(err)
+	}
+}
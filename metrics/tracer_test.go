@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"testing"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+const testSchema = `
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+type Query {
+	recipes: [Recipe]
+	recipe(id: ID!): Recipe
+}
+
+type Mutation {
+	deleteRecipe(id: ID!): ID
+}
+
+type Recipe {
+	_id: ID
+}
+`
+
+func TestOperationLabels(t *testing.T) {
+	tracer := Tracer{RootFields: RootFields(graphql.MustParseSchema(testSchema, nil).Inspect())}
+
+	tests := []struct {
+		name          string
+		query         string
+		operationName string
+		want          [2]string
+	}{
+		{"shorthand", `{ recipes { _id } }`, "", [2]string{"query", "recipes"}},
+		{"client name is ignored", `query AnythingGoes { recipe(id: "1") { _id } }`, "AnythingGoes", [2]string{"query", "recipe"}},
+		{"alias", `{ a: recipe(id: "1") { _id } b: recipe(id: "2") { _id } }`, "", [2]string{"query", "recipe"}},
+		{"mutation", `mutation { deleteRecipe(id: "1") }`, "", [2]string{"mutation", "deleteRecipe"}},
+		{"several root fields", `{ recipes { _id } recipe(id: "1") { _id } }`, "", [2]string{"query", "other"}},
+		{"fragment", `query { ...Root } fragment Root on Query { recipes { _id } }`, "", [2]string{"query", "recipes"}},
+		{"picked operation", `query A { recipes { _id } } mutation B { deleteRecipe(id: "1") }`, "B", [2]string{"mutation", "deleteRecipe"}},
+		{"typename", `{ __typename }`, "", [2]string{"query", "other"}},
+		{"unknown field", `{ secrets }`, "", [2]string{"query", "other"}},
+		{"unknown operation", `query A { recipes { _id } }`, "B", [2]string{"query", "other"}},
+		{"broken document", `mutation {`, "", [2]string{"mutation", "other"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			labels := tracer.operationLabels(test.query, test.operationName)
+			if len(labels) != 2 || labels[0] != test.want[0] || labels[1] != test.want[1] {
+				t.Errorf("labels = %v, want %v", labels, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/recipe"
+)
+
+type namespaceEvent struct {
+	ID   string `json:"_id"`
+	Name string `json:"name"`
+}
+
+// NamespaceCache keeps the namespaces announced by the namespace service,
+// so lookups by name don't need a gateway round trip every time.
+type NamespaceCache struct {
+	mutex  sync.RWMutex
+	byName map[string]string
+	byID   map[string]string
+}
+
+func NewNamespaceCache() *NamespaceCache {
+	return &NamespaceCache{
+		byName: make(map[string]string),
+		byID:   make(map[string]string),
+	}
+}
+
+func (c *NamespaceCache) GetIDByName(name string) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	id, ok := c.byName[name]
+	return id, ok
+}
+
+func (c *NamespaceCache) Set(id string, name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if oldName, ok := c.byID[id]; ok {
+		delete(c.byName, oldName)
+	}
+
+	c.byID[id] = name
+	c.byName[name] = id
+}
+
+func (c *NamespaceCache) Remove(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if name, ok := c.byID[id]; ok {
+		delete(c.byName, name)
+	}
+	delete(c.byID, id)
+}
+
+func CreateNamespaceChangedHandler(namespaceCache *NamespaceCache) EventHandler {
+	return func(ctx context.Context, msg []byte) error {
+		var namespace namespaceEvent
+		err := json.Unmarshal(msg, &namespace)
+
+		if err != nil {
+			logging.FromContext(ctx).Error("unmarshaling namespace failed", "message", string(msg), "error", err)
+			return err
+		}
+
+		namespaceCache.Set(namespace.ID, namespace.Name)
+
+		return nil
+	}
+}
+
+func CreateNamespaceDeletedHandler(recipeService recipe.Service, namespaceCache *NamespaceCache) EventHandler {
+	return func(ctx context.Context, msg []byte) error {
+		logger := logging.FromContext(ctx)
+		recipeService := recipe.WithContext(ctx, recipeService)
+
+		var namespaceID string
+		if err := json.Unmarshal(msg, &namespaceID); err != nil {
+			var namespace namespaceEvent
+			if err := json.Unmarshal(msg, &namespace); err != nil {
+				logger.Error("unmarshaling deleted namespace failed", "message", string(msg), "error", err)
+				return err
+			}
+			namespaceID = namespace.ID
+		}
+
+		if !bson.IsObjectIdHex(namespaceID) {
+			logger.Error("invalid namespace id", "message", string(msg))
+			return fmt.Errorf("invalid namespace id %q", namespaceID)
+		}
+
+		namespaceCache.Remove(namespaceID)
+
+		recipes, err := recipeService.FindByNamespaceID(bson.ObjectIdHex(namespaceID))
+		if err != nil {
+			logger.Error("finding recipes of namespace failed", "namespaceId", namespaceID, "error", err)
+			return err
+		}
+
+		archivedAt := time.Now().UTC()
+		for i := range recipes {
+			recipes[i].ArchivedAt = &archivedAt
+
+			_, err := recipeService.Update(ctx, recipes[i].ID.Hex(), &recipes[i])
+			if err != nil {
+				logger.Error("archiving recipe failed", "recipeId", recipes[i].ID.Hex(), "error", err)
+				return err
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dukfaar/goUtils/eventbus"
+	dukGraphql "github.com/dukfaar/goUtils/graphql"
+	"github.com/dukfaar/goUtils/permission"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/dukfaar/recipeBackend/health"
+	"github.com/dukfaar/recipeBackend/tracing"
+)
+
+var errPermissionsUnavailable = errors.New("permissions are not loaded yet, try again later")
+
+const (
+	permissionRetryMinDelay = time.Second
+	permissionRetryMaxDelay = time.Minute
+)
+
+var (
+	permissionsLoadedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "recipe_permissions_loaded",
+		Help: "1 once the permission data has been loaded from the api gateway.",
+	})
+	permissionSyncTimestampGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "recipe_permissions_last_sync_timestamp_seconds",
+		Help: "Time of the last successful permission sync.",
+	})
+	permissionSyncFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "recipe_permissions_sync_failures_total",
+		Help: "Failed attempts to fetch the permission data.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(permissionsLoadedGauge, permissionSyncTimestampGauge, permissionSyncFailures)
+}
+
+// PermissionSync loads the permission data from the api gateway in the
+// background. Until the first load succeeded every permission check is
+// denied, so the service can start before the gateway is up.
+//
+// Every sync builds a fresh permission.Service and swaps it in, the auth
+// events of the gateway are applied to the current one under the same lock.
+// Events arriving while a sync is fetching are replayed on the fresh service,
+// which may have been built from data older than them.
+type PermissionSync struct {
+	Fetcher        dukGraphql.Fetcher
+	ResyncInterval time.Duration
+	Health         *health.Flag
+
+	mutex         sync.RWMutex
+	loaded        bool
+	service       *permission.Service
+	eventHandlers map[string]func(msg []byte) error
+	// pending holds the events received since the running sync started
+	// fetching, nil while no sync is running
+	pending []authEvent
+}
+
+type authEvent struct {
+	topic string
+	msg   []byte
+}
+
+func NewPermissionSync(fetcher dukGraphql.Fetcher, resyncInterval time.Duration) *PermissionSync {
+	service := permission.NewService()
+
+	return &PermissionSync{
+		Fetcher:        fetcher,
+		ResyncInterval: resyncInterval,
+		Health:         health.NewFlag(errors.New("permissions not loaded yet")),
+		service:        service,
+		eventHandlers:  recordAuthEventHandlers(service).handlers,
+	}
+}
+
+// authEventRecorder collects the handlers permission.AddAuthEventsHandlers
+// registers, instead of subscribing them.
+type authEventRecorder struct {
+	channels map[string]string
+	handlers map[string]func(msg []byte) error
+}
+
+func (r *authEventRecorder) Emit(topic string, data interface{}) {}
+
+func (r *authEventRecorder) On(topic string, channel string, handler func(msg []byte) error) {
+	r.channels[topic] = channel
+	r.handlers[topic] = handler
+}
+
+// recordAuthEventHandlers is a variable, so tests can observe which events
+// reach which service.
+var recordAuthEventHandlers = func(service *permission.Service) *authEventRecorder {
+	recorder := &authEventRecorder{
+		channels: make(map[string]string),
+		handlers: make(map[string]func(msg []byte) error),
+	}
+	permission.AddAuthEventsHandlers(recorder, service)
+	return recorder
+}
+
+// AddAuthEventsHandlers subscribes to the auth events like
+// permission.AddAuthEventsHandlers does, but applies them to the current
+// service while holding the lock.
+func (s *PermissionSync) AddAuthEventsHandlers(bus eventbus.EventBus) {
+	s.mutex.RLock()
+	recorder := recordAuthEventHandlers(s.service)
+	s.mutex.RUnlock()
+
+	for topic, channel := range recorder.channels {
+		topic := topic
+		bus.On(topic, channel, func(msg []byte) error {
+			return s.handleAuthEvent(topic, msg)
+		})
+	}
+}
+
+func (s *PermissionSync) handleAuthEvent(topic string, msg []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.pending != nil {
+		s.pending = append(s.pending, authEvent{topic: topic, msg: msg})
+	}
+
+	handler, ok := s.eventHandlers[topic]
+	if !ok {
+		return nil
+	}
+	return handler(msg)
+}
+
+func (s *PermissionSync) Loaded() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.loaded
+}
+
+func (s *PermissionSync) load(ctx context.Context) error {
+	s.mutex.Lock()
+	s.pending = make([]authEvent, 0)
+	s.mutex.Unlock()
+
+	service, err := s.fetch(ctx)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pending := s.pending
+	s.pending = nil
+	if err != nil {
+		return err
+	}
+
+	eventHandlers := recordAuthEventHandlers(service).handlers
+	for _, event := range pending {
+		if handler, ok := eventHandlers[event.topic]; ok {
+			if err := handler(event.msg); err != nil {
+				slog.Warn("replaying auth event failed", "topic", event.topic, "error", err)
+			}
+		}
+	}
+
+	s.service = service
+	s.eventHandlers = eventHandlers
+	s.loaded = true
+
+	return nil
+}
+
+// fetch builds a fresh permission service from the data of the api gateway.
+// Permission checks keep using the old service until it is swapped in.
+func (s *PermissionSync) fetch(ctx context.Context) (*permission.Service, error) {
+	result, err := tracing.Fetch(ctx, s.Fetcher, dukGraphql.Request{
+		Query: permission.Query,
+	})
+	if err != nil {
+		return nil, err
+	}
+	queryResult := dukGraphql.Response{result}
+
+	service := permission.NewService()
+	permission.ParseQueryResponse(queryResult, service)
+	service.BuildAllUserPermissionData()
+
+	return service, nil
+}
+
+// Run loads the permissions, retrying with backoff until it succeeds, and
+// then resyncs them every ResyncInterval until ctx is done. A failed resync
+// keeps the data already loaded.
+func (s *PermissionSync) Run(ctx context.Context) {
+	delay := permissionRetryMinDelay
+
+	for {
+		err := s.load(ctx)
+
+		var wait time.Duration
+		if err == nil {
+			permissionsLoadedGauge.Set(1)
+			permissionSyncTimestampGauge.SetToCurrentTime()
+			s.Health.Set(nil)
+
+			delay = permissionRetryMinDelay
+			wait = s.ResyncInterval
+		} else {
+			permissionSyncFailures.Inc()
+			slog.Warn("loading permissions failed", "retryIn", delay, "error", err)
+
+			if !s.Loaded() {
+				s.Health.Set(fmt.Errorf("permissions not loaded yet: %v", err))
+			}
+
+			wait = delay
+			delay *= 2
+			if delay > permissionRetryMaxDelay {
+				delay = permissionRetryMaxDelay
+			}
+			if s.Loaded() && wait > s.ResyncInterval {
+				wait = s.ResyncInterval
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// checkPermission is permission.Check on the current permission data,
+// denying everything while it isn't loaded.
+func checkPermission(ctx context.Context, name string) error {
+	if permissionSync, ok := ctx.Value("permissionSync").(*PermissionSync); ok {
+		permissionSync.mutex.RLock()
+		defer permissionSync.mutex.RUnlock()
+
+		if !permissionSync.loaded {
+			return errPermissionsUnavailable
+		}
+		ctx = context.WithValue(ctx, "permissionService", permissionSync.service)
+	}
+
+	return permission.Check(ctx, name)
+}
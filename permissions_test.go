@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	dukGraphql "github.com/dukfaar/goUtils/graphql"
+	"github.com/dukfaar/goUtils/permission"
+)
+
+// blockingFetcher answers the permission query once release is closed.
+type blockingFetcher struct {
+	started chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (f *blockingFetcher) Fetch(request dukGraphql.Request) (interface{}, error) {
+	f.started <- struct{}{}
+	<-f.release
+	return map[string]interface{}{}, f.err
+}
+
+// recordServiceEvents replaces the auth event handlers with ones logging the
+// messages every service built by the sync receives, in build order.
+func recordServiceEvents(t *testing.T) *[][]string {
+	logs := &[][]string{}
+
+	original := recordAuthEventHandlers
+	recordAuthEventHandlers = func(service *permission.Service) *authEventRecorder {
+		*logs = append(*logs, nil)
+		index := len(*logs) - 1
+
+		return &authEventRecorder{
+			channels: map[string]string{"role.updated": "recipe"},
+			handlers: map[string]func(msg []byte) error{
+				"role.updated": func(msg []byte) error {
+					(*logs)[index] = append((*logs)[index], string(msg))
+					return nil
+				},
+			},
+		}
+	}
+	t.Cleanup(func() { recordAuthEventHandlers = original })
+
+	return logs
+}
+
+func loadWhile(t *testing.T, permissionSync *PermissionSync, fetcher *blockingFetcher, during func()) error {
+	t.Helper()
+
+	done := make(chan error)
+	go func() {
+		done <- permissionSync.load(context.Background())
+	}()
+
+	<-fetcher.started
+	during()
+	close(fetcher.release)
+
+	return <-done
+}
+
+func TestPermissionSyncReplaysEventsReceivedWhileLoading(t *testing.T) {
+	logs := recordServiceEvents(t)
+	fetcher := &blockingFetcher{started: make(chan struct{}), release: make(chan struct{})}
+	permissionSync := NewPermissionSync(fetcher, 0)
+
+	err := loadWhile(t, permissionSync, fetcher, func() {
+		permissionSync.handleAuthEvent("role.updated", []byte("during"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	permissionSync.handleAuthEvent("role.updated", []byte("after"))
+
+	if len(*logs) != 2 {
+		t.Fatalf("built %d services, want 2", len(*logs))
+	}
+	if old := (*logs)[0]; len(old) != 1 || old[0] != "during" {
+		t.Errorf("old service got %v, want the event received before the swap", old)
+	}
+	if current := (*logs)[1]; len(current) != 2 || current[0] != "during" || current[1] != "after" {
+		t.Errorf("new service got %v, want the replayed and the later event", current)
+	}
+	if !permissionSync.Loaded() {
+		t.Error("sync isn't loaded")
+	}
+}
+
+func TestPermissionSyncFailedLoadKeepsService(t *testing.T) {
+	logs := recordServiceEvents(t)
+	fetcher := &blockingFetcher{started: make(chan struct{}), release: make(chan struct{}), err: errors.New("gateway down")}
+	permissionSync := NewPermissionSync(fetcher, 0)
+
+	err := loadWhile(t, permissionSync, fetcher, func() {
+		permissionSync.handleAuthEvent("role.updated", []byte("during"))
+	})
+	if err == nil {
+		t.Fatal("load succeeded, want the fetch error")
+	}
+	permissionSync.handleAuthEvent("role.updated", []byte("after"))
+
+	if len(*logs) != 1 {
+		t.Fatalf("built %d services, want only the initial one", len(*logs))
+	}
+	if events := (*logs)[0]; len(events) != 2 {
+		t.Errorf("service got %v, want both events", events)
+	}
+	if permissionSync.pending != nil {
+		t.Errorf("events %v are still buffered after the failed load", permissionSync.pending)
+	}
+}
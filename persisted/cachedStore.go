@@ -0,0 +1,37 @@
+package persisted
+
+// CachedStore keeps recently used queries of a backing store in memory.
+// Queries never change, so cached entries don't expire.
+type CachedStore struct {
+	backend Store
+	cache   *MemoryStore
+}
+
+func NewCachedStore(backend Store, maxEntries int) *CachedStore {
+	return &CachedStore{
+		backend: backend,
+		cache:   NewMemoryStore(maxEntries),
+	}
+}
+
+func (s *CachedStore) Get(hash string) (string, error) {
+	if query, err := s.cache.Get(hash); err == nil {
+		return query, nil
+	}
+
+	query, err := s.backend.Get(hash)
+	if err != nil {
+		return "", err
+	}
+
+	s.cache.Put(hash, query)
+	return query, nil
+}
+
+func (s *CachedStore) Put(hash string, query string) error {
+	if err := s.backend.Put(hash, query); err != nil {
+		return err
+	}
+
+	return s.cache.Put(hash, query)
+}
@@ -0,0 +1,24 @@
+package persisted
+
+// Error rejects a persisted query request. Apollo clients look for the
+// message, Code ends up in the extensions of the GraphQL error.
+type Error struct {
+	Message string
+	Code    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+var (
+	// ErrQueryNotFound makes apollo clients send the hash again together with the query
+	ErrQueryNotFound   = &Error{Message: "PersistedQueryNotFound", Code: "PERSISTED_QUERY_NOT_FOUND"}
+	ErrHashMismatch    = &Error{Message: "provided sha does not match query", Code: "INVALID_PERSISTED_QUERY"}
+	ErrUnsupported     = &Error{Message: "unsupported persisted query version", Code: "INVALID_PERSISTED_QUERY"}
+	ErrQueryNotAllowed = &Error{Message: "only persisted queries are allowed", Code: "PERSISTED_QUERY_REQUIRED"}
+)
@@ -0,0 +1,36 @@
+package persisted
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// LoadManifest stores all queries of a manifest file, a json object mapping
+// the sha256 hash of every query to the query. It returns the number of
+// queries in the manifest.
+func LoadManifest(path string, store Store) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var manifest map[string]string
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return 0, fmt.Errorf("reading persisted query manifest %v: %v", path, err)
+	}
+
+	for hash, query := range manifest {
+		if Hash(query) != hash {
+			return 0, fmt.Errorf("persisted query manifest %v: %v is not the sha256 hash of its query", path, hash)
+		}
+	}
+
+	for hash, query := range manifest {
+		if err := store.Put(hash, query); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(manifest), nil
+}
@@ -0,0 +1,62 @@
+package persisted
+
+import (
+	"time"
+
+	mgo "github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+type storedQuery struct {
+	Hash      string    `bson:"_id"`
+	Query     string    `bson:"query"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+// MgoStore shares the persisted queries between all replicas. Queries are
+// removed ttl after they were persisted, clients persist them again when
+// they miss.
+type MgoStore struct {
+	collection *mgo.Collection
+}
+
+func NewMgoStore(db *mgo.Database, ttl time.Duration) (*MgoStore, error) {
+	collection := db.C("persistedQueries")
+
+	err := collection.EnsureIndex(mgo.Index{
+		Key:         []string{"createdAt"},
+		ExpireAfter: ttl,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MgoStore{
+		collection: collection,
+	}, nil
+}
+
+func (s *MgoStore) Get(hash string) (string, error) {
+	var result storedQuery
+
+	err := s.collection.FindId(hash).One(&result)
+	if err == mgo.ErrNotFound {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return result.Query, nil
+}
+
+func (s *MgoStore) Put(hash string, query string) error {
+	// replicas may store the same query at the same time, the first one wins
+	_, err := s.collection.UpsertId(hash, bson.M{
+		"$setOnInsert": bson.M{
+			"query":     query,
+			"createdAt": time.Now().UTC(),
+		},
+	})
+	return err
+}
@@ -0,0 +1,80 @@
+package persisted
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+)
+
+var ErrNotFound = errors.New("persisted query not found")
+
+// Hash returns the sha256 hash a query is persisted under.
+func Hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// Store keeps queries by their hash. Stored queries never change, a hash
+// always stands for the same query.
+type Store interface {
+	Get(hash string) (string, error)
+	Put(hash string, query string) error
+}
+
+type memoryEntry struct {
+	hash  string
+	query string
+}
+
+// MemoryStore keeps up to a fixed number of queries, dropping the least
+// recently used ones once it is full. Zero or less keeps everything.
+type MemoryStore struct {
+	maxEntries int
+
+	mutex   sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (s *MemoryStore) Get(hash string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, ok := s.entries[hash]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	s.order.MoveToFront(element)
+	return element.Value.(*memoryEntry).query, nil
+}
+
+func (s *MemoryStore) Put(hash string, query string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if element, ok := s.entries[hash]; ok {
+		s.order.MoveToFront(element)
+		return nil
+	}
+
+	s.entries[hash] = s.order.PushFront(&memoryEntry{hash: hash, query: query})
+
+	if s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryEntry).hash)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+
+	"github.com/dukfaar/recipeBackend/graphqlserver"
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/metrics"
+	"github.com/dukfaar/recipeBackend/persisted"
+)
+
+// persistedQueryExtension returns the hash of the persistedQuery extension
+// apollo clients send, and whether the request had one.
+func persistedQueryExtension(request *graphqlserver.Request) (string, bool, error) {
+	extension, ok := request.Extensions["persistedQuery"].(map[string]interface{})
+	if !ok {
+		return "", false, nil
+	}
+
+	if version, _ := extension["version"].(float64); version != 1 {
+		return "", true, persisted.ErrUnsupported
+	}
+
+	hash, _ := extension["sha256Hash"].(string)
+	if hash == "" {
+		return "", true, persisted.ErrUnsupported
+	}
+
+	return hash, true, nil
+}
+
+// persistedQueryCheck fills in the query of requests that only send the hash
+// of a persisted query, looking it up in the manifest and then in store.
+// With allowlist set only the queries of the manifest are run, and store
+// isn't used at all.
+func persistedQueryCheck(manifest persisted.Store, store persisted.Store, allowlist bool) graphqlserver.Check {
+	observe := func(result string) {
+		metrics.PersistedQueries.WithLabelValues(result).Inc()
+	}
+
+	lookup := func(hash string) (string, error) {
+		query, err := manifest.Get(hash)
+		if err == persisted.ErrNotFound && !allowlist {
+			return store.Get(hash)
+		}
+		return query, err
+	}
+
+	allowed := func(ctx context.Context, hash string) error {
+		_, err := manifest.Get(hash)
+		if err == persisted.ErrNotFound {
+			observe("rejected")
+			logging.FromContext(ctx).Warn("rejected query that isn't in the manifest", "hash", hash)
+			return persisted.ErrQueryNotAllowed
+		}
+		return err
+	}
+
+	return func(ctx context.Context, request *graphqlserver.Request) error {
+		hash, ok, err := persistedQueryExtension(request)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			if allowlist {
+				return allowed(ctx, persisted.Hash(request.Query))
+			}
+			return nil
+		}
+
+		if request.Query == "" {
+			query, err := lookup(hash)
+			if err == persisted.ErrNotFound {
+				observe("miss")
+				return persisted.ErrQueryNotFound
+			}
+			if err != nil {
+				return err
+			}
+
+			observe("hit")
+			request.Query = query
+			return nil
+		}
+
+		if persisted.Hash(request.Query) != hash {
+			return persisted.ErrHashMismatch
+		}
+
+		if allowlist {
+			return allowed(ctx, hash)
+		}
+
+		// persistQuery stores it once it passed the other checks and ran
+		return nil
+	}
+}
+
+// persistQuery is an executor hook persisting the queries clients sent
+// together with their hash. It only sees requests that passed all checks and
+// ran without errors, so clients can't fill the store with queries that are
+// rejected. Queries longer than maxLength aren't persisted, their clients
+// keep sending them.
+func persistQuery(manifest persisted.Store, store persisted.Store, maxLength int) graphqlserver.Hook {
+	return func(ctx context.Context, request *graphqlserver.Request) {
+		hash, ok, err := persistedQueryExtension(request)
+		if !ok || err != nil {
+			return
+		}
+
+		if len(request.Query) > maxLength {
+			metrics.PersistedQueries.WithLabelValues("tooLong").Inc()
+			return
+		}
+
+		// requests that only sent the hash were looked up in either
+		if _, err := manifest.Get(hash); err == nil {
+			return
+		}
+		if _, err := store.Get(hash); err == nil {
+			return
+		}
+
+		// failing to persist only costs the client another round trip
+		if err := store.Put(hash, request.Query); err != nil {
+			logging.FromContext(ctx).Error("persisting query failed", "hash", hash, "error", err)
+			return
+		}
+
+		metrics.PersistedQueries.WithLabelValues("registered").Inc()
+	}
+}
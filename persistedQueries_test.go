@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"github.com/dukfaar/recipeBackend/graphqlserver"
+	"github.com/dukfaar/recipeBackend/persisted"
+)
+
+type helloResolver struct{}
+
+func (r *helloResolver) Hello() string { return "world" }
+
+var errTooExpensive = errors.New("too expensive")
+
+// newPersistedQueryExecutor runs the persisted query check like the server,
+// followed by a check rejecting queries containing "expensive".
+func newPersistedQueryExecutor(manifest persisted.Store, store persisted.Store, allowlist bool, maxLength int) *graphqlserver.Executor {
+	executor := &graphqlserver.Executor{
+		Schema: graphql.MustParseSchema(`schema { query: Query } type Query { hello: String! }`, &helloResolver{}),
+		Checks: []graphqlserver.Check{
+			persistedQueryCheck(manifest, store, allowlist),
+			func(ctx context.Context, request *graphqlserver.Request) error {
+				if strings.Contains(request.Query, "expensive") {
+					return errTooExpensive
+				}
+				return nil
+			},
+		},
+	}
+	if !allowlist {
+		executor.Executed = []graphqlserver.Hook{persistQuery(manifest, store, maxLength)}
+	}
+	return executor
+}
+
+func persistedRequest(query string, hash string) *graphqlserver.Request {
+	return &graphqlserver.Request{
+		Query: query,
+		Extensions: map[string]interface{}{
+			"persistedQuery": map[string]interface{}{"version": float64(1), "sha256Hash": hash},
+		},
+	}
+}
+
+func TestAutomaticPersistedQueries(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		wantErr     error
+		wantPersist bool
+	}{
+		{"executed query", `{ hello }`, nil, true},
+		{"query rejected by a later check", `{ expensive: hello }`, errTooExpensive, false},
+		{"query with errors", `{ goodbye }`, nil, false},
+		{"too long query", `{ hello ` + strings.Repeat(" ", 100) + `}`, nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := persisted.NewMemoryStore(0)
+			executor := newPersistedQueryExecutor(persisted.NewMemoryStore(0), store, false, 64)
+			hash := persisted.Hash(test.query)
+
+			if _, err := executor.Exec(context.Background(), persistedRequest(test.query, hash)); err != test.wantErr {
+				t.Fatalf("err = %v, want %v", err, test.wantErr)
+			}
+
+			_, err := store.Get(hash)
+			if persistedNow := err == nil; persistedNow != test.wantPersist {
+				t.Fatalf("persisted = %v, want %v", persistedNow, test.wantPersist)
+			}
+
+			_, err = executor.Exec(context.Background(), persistedRequest("", hash))
+			if test.wantPersist && err != nil {
+				t.Errorf("hash only: %v", err)
+			}
+			if !test.wantPersist && err != persisted.ErrQueryNotFound {
+				t.Errorf("hash only: err = %v, want ErrQueryNotFound", err)
+			}
+		})
+	}
+}
+
+func TestPersistedQueryHashMismatch(t *testing.T) {
+	store := persisted.NewMemoryStore(0)
+	executor := newPersistedQueryExecutor(persisted.NewMemoryStore(0), store, false, 1024)
+
+	_, err := executor.Exec(context.Background(), persistedRequest(`{ hello }`, persisted.Hash(`{ other }`)))
+	if err != persisted.ErrHashMismatch {
+		t.Fatalf("err = %v, want ErrHashMismatch", err)
+	}
+	if _, err := store.Get(persisted.Hash(`{ other }`)); err == nil {
+		t.Error("query persisted under the wrong hash")
+	}
+}
+
+func TestPersistedQueryAllowlist(t *testing.T) {
+	manifest := persisted.NewMemoryStore(0)
+	manifest.Put(persisted.Hash(`{ hello }`), `{ hello }`)
+
+	// queries clients persisted in auto mode are no part of the allowlist
+	store := persisted.NewMemoryStore(0)
+	store.Put(persisted.Hash(`{ a: hello }`), `{ a: hello }`)
+
+	executor := newPersistedQueryExecutor(manifest, store, true, 1024)
+
+	tests := []struct {
+		name    string
+		request *graphqlserver.Request
+		wantErr error
+	}{
+		{"manifest hash", persistedRequest("", persisted.Hash(`{ hello }`)), nil},
+		{"manifest query", &graphqlserver.Request{Query: `{ hello }`}, nil},
+		{"manifest query with hash", persistedRequest(`{ hello }`, persisted.Hash(`{ hello }`)), nil},
+		{"other query", &graphqlserver.Request{Query: `{ b: hello }`}, persisted.ErrQueryNotAllowed},
+		{"other query with hash", persistedRequest(`{ b: hello }`, persisted.Hash(`{ b: hello }`)), persisted.ErrQueryNotAllowed},
+		{"hash persisted by a client", persistedRequest("", persisted.Hash(`{ a: hello }`)), persisted.ErrQueryNotFound},
+		{"query persisted by a client", &graphqlserver.Request{Query: `{ a: hello }`}, persisted.ErrQueryNotAllowed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := executor.Exec(context.Background(), test.request); err != test.wantErr {
+				t.Errorf("err = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+
+	if _, err := store.Get(persisted.Hash(`{ b: hello }`)); err == nil {
+		t.Error("allowlist mode persisted a query")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"github.com/dukfaar/recipeBackend/complexity"
+	"github.com/dukfaar/recipeBackend/config"
+	"github.com/dukfaar/recipeBackend/graphqlserver"
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/metrics"
+)
+
+// fieldCosts are the fields that don't cost the single read of an ordinary
+// field: expensive queries and mutations, and fields that only unwrap data
+// loaded by their parent.
+var fieldCosts = map[string]int{
+	"Query.recipeCountsByNamespace":   10,
+	"Query.brokenRecipes":             5,
+	"Mutation.rcRecipeImport":         100,
+	"Mutation.fileRecipeImport":       100,
+	"Mutation.invalidateItemMappings": 10,
+	"Subscription.importJobProgress":  10,
+	"Recipe.inputs":                   0,
+	"Recipe.outputs":                  0,
+	"RecipeConnection.edges":          0,
+	"RecipeEdge.node":                 0,
+	"RecipeConnection.pageInfo":       0,
+}
+
+// complexityCheck rejects operations that are too deep, too complex or can't
+// be analyzed. Operations within the limits are passed to rateLimit, which
+// also spends their complexity from the complexity budget.
+func complexityCheck(schema *graphql.Schema, graphqlConfig config.GraphQLConfig, rateLimit func(ctx context.Context, result complexity.Result) error) graphqlserver.Check {
+	analyzer := complexity.NewAnalyzer(schema.Inspect(), graphqlConfig.MaxDepth, graphqlConfig.MaxComplexity)
+	for field, cost := range fieldCosts {
+		analyzer.Costs[field] = cost
+	}
+
+	return func(ctx context.Context, request *graphqlserver.Request) error {
+		result, err := analyzer.Check(request.Query, request.OperationName, request.Variables)
+		if err != nil {
+			// documents that can't be analyzed can't be limited either
+			reason := "invalid"
+			if limitError, ok := err.(*complexity.LimitError); ok {
+				reason = limitError.Limit
+			}
+			metrics.GraphQLRejected.WithLabelValues(reason).Inc()
+			logging.FromContext(ctx).Warn("rejected graphql operation", "operation", request.OperationName, "error", err)
+			return err
+		}
+
+		metrics.GraphQLComplexity.WithLabelValues(result.OperationType).Observe(float64(result.Complexity))
+
+		if err := rateLimit(ctx, result); err != nil {
+			metrics.GraphQLRejected.WithLabelValues("rateLimit").Inc()
+			return err
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/dukfaar/recipeBackend/complexity"
+	"github.com/dukfaar/recipeBackend/config"
+	"github.com/dukfaar/recipeBackend/graphqlserver"
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/metrics"
+	"github.com/dukfaar/recipeBackend/ratelimit"
+	"github.com/dukfaar/recipeBackend/recipe"
+)
+
+// the rate limit buckets, every user has one of each
+const (
+	bucketRequests    = "requests"
+	bucketMutations   = "mutations"
+	bucketImports     = "imports"
+	bucketExpensive   = "expensive"
+	bucketConnections = "connections"
+	// bucketComplexity is the complexity budget, operations take as many
+	// tokens as their complexity
+	bucketComplexity = "complexity"
+)
+
+// importMutations are the mutations that start an import job
+var importMutations = map[string]bool{
+	"rcRecipeImport":   true,
+	"fileRecipeImport": true,
+}
+
+func newRateLimiter(rateLimit config.RateLimitConfig, complexityBudget int) *ratelimit.Limiter {
+	return ratelimit.NewLimiter(map[string]ratelimit.Rule{
+		bucketRequests:    {PerMinute: rateLimit.RequestsPerMinute, Burst: rateLimit.RequestsBurst},
+		bucketMutations:   {PerMinute: rateLimit.MutationsPerMinute, Burst: rateLimit.MutationsBurst},
+		bucketImports:     {PerMinute: rateLimit.ImportsPerMinute, Burst: rateLimit.ImportsBurst},
+		bucketExpensive:   {PerMinute: rateLimit.ExpensivePerMinute, Burst: rateLimit.ExpensiveBurst},
+		bucketConnections: {PerMinute: rateLimit.ConnectionsPerMinute, Burst: rateLimit.ConnectionsBurst},
+		// users can spend up to a minute's worth of their budget at once
+		bucketComplexity: {PerMinute: float64(complexityBudget), Burst: complexityBudget},
+	})
+}
+
+// rateLimitKey is who a request counts against: the user or client ctx is
+// authenticated as, or the address of anonymous requests.
+func rateLimitKey(ctx context.Context, remoteAddr string) string {
+	if userID := recipe.ActorFromContext(ctx); userID != "" {
+		return "user:" + userID
+	}
+
+	if clientID, ok := ctx.Value("clientId").(string); ok && clientID != "" {
+		return "client:" + clientID
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return "address:" + host
+}
+
+func rateLimited(ctx context.Context, limiter *ratelimit.Limiter, bucket string, key string) error {
+	return rateLimitedN(ctx, limiter, bucket, key, 1)
+}
+
+func rateLimitedN(ctx context.Context, limiter *ratelimit.Limiter, bucket string, key string, n int) error {
+	err := limiter.AllowN(bucket, key, n)
+	if err != nil {
+		metrics.RateLimited.WithLabelValues(bucket).Inc()
+		logging.FromContext(ctx).Warn("rate limited request", "bucket", bucket, "key", key)
+	}
+	return err
+}
+
+// rateLimitKeyMiddleware stores the key of the request for the rate limit
+// checks. It has to run after dukHttp.Authenticate to know the user.
+func rateLimitKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := ratelimit.WithKey(r.Context(), rateLimitKey(r.Context(), r.RemoteAddr))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// rateLimitMiddleware counts every request against bucket and stores the key
+// of the request like rateLimitKeyMiddleware.
+func rateLimitMiddleware(limiter *ratelimit.Limiter, bucket string, next http.Handler) http.Handler {
+	return rateLimitKeyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := rateLimited(r.Context(), limiter, bucket, ratelimit.KeyFromContext(r.Context())); err != nil {
+			graphqlserver.WriteError(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// admitSocket counts a socket connection against the connections bucket,
+// once connection_init told who it belongs to.
+func admitSocket(limiter *ratelimit.Limiter) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return rateLimited(ctx, limiter, bucketConnections, ratelimit.KeyFromContext(ctx))
+	}
+}
+
+// rateLimitCheck counts mutations, imports and expensive queries against
+// their buckets and spends the complexity of operations from the complexity
+// budget, for requests over http and operations over the socket alike. It
+// needs the result of the complexity analysis, so it is run by the
+// complexity check.
+func rateLimitCheck(limiter *ratelimit.Limiter, expensiveComplexity int) func(ctx context.Context, result complexity.Result) error {
+	return func(ctx context.Context, result complexity.Result) error {
+		key := ratelimit.KeyFromContext(ctx)
+		if key == "" {
+			return nil
+		}
+
+		if result.OperationType == "mutation" {
+			if err := rateLimited(ctx, limiter, bucketMutations, key); err != nil {
+				return err
+			}
+
+			// every import field starts a job of its own
+			imports := 0
+			for _, field := range result.RootFields {
+				if importMutations[field] {
+					imports++
+				}
+			}
+			if err := rateLimitedN(ctx, limiter, bucketImports, key, imports); err != nil {
+				return err
+			}
+		}
+
+		if result.Complexity >= expensiveComplexity {
+			if err := rateLimited(ctx, limiter, bucketExpensive, key); err != nil {
+				return err
+			}
+		}
+
+		return rateLimitedN(ctx, limiter, bucketComplexity, key, result.Complexity)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dukfaar/recipeBackend/complexity"
+	"github.com/dukfaar/recipeBackend/config"
+	"github.com/dukfaar/recipeBackend/ratelimit"
+)
+
+func TestRateLimitCheck(t *testing.T) {
+	limiter := newRateLimiter(config.RateLimitConfig{
+		MutationsPerMinute: 60,
+		MutationsBurst:     10,
+		ImportsPerMinute:   1,
+		ImportsBurst:       2,
+	}, 1000)
+	check := rateLimitCheck(limiter, 500)
+	ctx := ratelimit.WithKey(context.Background(), "user:1")
+
+	importBoth := complexity.Result{
+		OperationType: "mutation",
+		RootFields:    []string{"rcRecipeImport", "fileRecipeImport"},
+		Complexity:    200,
+	}
+	if err := check(ctx, importBoth); err != nil {
+		t.Fatal(err)
+	}
+
+	// both import tokens were taken by the first operation
+	importOne := complexity.Result{OperationType: "mutation", RootFields: []string{"rcRecipeImport"}, Complexity: 100}
+	err := check(ctx, importOne)
+	if limitError, ok := err.(*ratelimit.Error); !ok || limitError.Bucket != bucketImports {
+		t.Fatalf("err = %v, want the imports bucket to be empty", err)
+	}
+
+	// only the first operation spent 200 of the budget of 1000, the rejected
+	// one didn't pay
+	query := complexity.Result{OperationType: "query", RootFields: []string{"recipes"}, Complexity: 450}
+	if err := check(ctx, query); err != nil {
+		t.Fatal(err)
+	}
+	err = check(ctx, query)
+	if limitError, ok := err.(*ratelimit.Error); !ok || limitError.Bucket != bucketComplexity {
+		t.Fatalf("err = %v, want the complexity budget to be spent", err)
+	}
+
+	if err := check(context.Background(), query); err != nil {
+		t.Errorf("operations without a key aren't limited: %v", err)
+	}
+}
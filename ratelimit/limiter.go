@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// idleTimeout is how long the bucket of a key is kept after its last use.
+// A forgotten bucket is full again, which it would be by then anyway.
+const idleTimeout = 10 * time.Minute
+
+// Rule allows PerMinute events per minute with bursts of up to Burst events.
+// A rule without a rate doesn't limit anything.
+type Rule struct {
+	PerMinute float64
+	Burst     int
+}
+
+// Error is returned for events exceeding the rule of their bucket.
+type Error struct {
+	Bucket string
+	// Delay is how long until the bucket allows the events, zero if it never
+	// does because they exceed its burst
+	Delay time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Delay <= 0 {
+		return fmt.Sprintf("request exceeds the rate limit for %s", e.Bucket)
+	}
+	return fmt.Sprintf("rate limit for %s exceeded, retry in %v", e.Bucket, e.Delay.Round(time.Second))
+}
+
+func (e *Error) RetryAfter() time.Duration {
+	return e.Delay
+}
+
+func (e *Error) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{
+		"code":   "RATE_LIMITED",
+		"bucket": e.Bucket,
+	}
+	if e.Delay > 0 {
+		extensions["retryAfter"] = math.Ceil(e.Delay.Seconds())
+	}
+	return extensions
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// Limiter keeps a token bucket per bucket name and key, like one bucket of
+// mutations per user.
+type Limiter struct {
+	rules map[string]Rule
+
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewLimiter(rules map[string]Rule) *Limiter {
+	return &Limiter{
+		rules:     rules,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes an event from the bucket of key, or returns an *Error telling
+// when the bucket allows it again.
+func (l *Limiter) Allow(name string, key string) error {
+	return l.AllowN(name, key, 1)
+}
+
+// AllowN takes n events at once, like a query of complexity n from the
+// complexity budget.
+func (l *Limiter) AllowN(name string, key string, n int) error {
+	rule, ok := l.rules[name]
+	if !ok || rule.PerMinute <= 0 || n <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if now.Sub(l.lastSweep) > idleTimeout {
+		for bucketKey, b := range l.buckets {
+			if now.Sub(b.lastUsed) > idleTimeout {
+				delete(l.buckets, bucketKey)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	bucketKey := name + "/" + key
+	b, ok := l.buckets[bucketKey]
+	if !ok {
+		burst := rule.Burst
+		if burst <= 0 {
+			burst = 1
+		}
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(rule.PerMinute/60), burst)}
+		l.buckets[bucketKey] = b
+	}
+	b.lastUsed = now
+
+	reservation := b.limiter.ReserveN(now, n)
+	if !reservation.OK() {
+		return &Error{Bucket: name}
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return &Error{Bucket: name, Delay: delay}
+	}
+
+	return nil
+}
+
+type contextKey string
+
+const keyContextKey contextKey = "rateLimitKey"
+
+// WithKey stores the key the requests of ctx are limited by, KeyFromContext
+// returns it.
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, keyContextKey, key)
+}
+
+func KeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(keyContextKey).(string)
+	return key
+}
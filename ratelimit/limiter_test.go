@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"testing"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	limiter := NewLimiter(map[string]Rule{
+		"mutations": {PerMinute: 1, Burst: 2},
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := limiter.Allow("mutations", "user:1"); err != nil {
+			t.Fatalf("event %d within the burst: %v", i, err)
+		}
+	}
+
+	err := limiter.Allow("mutations", "user:1")
+	limitError, ok := err.(*Error)
+	if !ok || limitError.Bucket != "mutations" || limitError.Delay <= 0 {
+		t.Fatalf("err = %v, want an *Error with a delay", err)
+	}
+
+	if err := limiter.Allow("mutations", "user:2"); err != nil {
+		t.Errorf("other keys have buckets of their own: %v", err)
+	}
+	if err := limiter.Allow("unknown", "user:1"); err != nil {
+		t.Errorf("buckets without a rule don't limit: %v", err)
+	}
+}
+
+func TestLimiterAllowN(t *testing.T) {
+	limiter := NewLimiter(map[string]Rule{
+		"complexity": {PerMinute: 100, Burst: 100},
+	})
+
+	if err := limiter.AllowN("complexity", "user:1", 60); err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.AllowN("complexity", "user:1", 0); err != nil {
+		t.Errorf("free operations are always allowed: %v", err)
+	}
+
+	err := limiter.AllowN("complexity", "user:1", 60)
+	if limitError, ok := err.(*Error); !ok || limitError.Delay <= 0 {
+		t.Fatalf("err = %v, want an *Error with a delay", err)
+	}
+
+	// the rejected events weren't taken
+	if err := limiter.AllowN("complexity", "user:1", 40); err != nil {
+		t.Errorf("the rest of the burst: %v", err)
+	}
+
+	err = limiter.AllowN("complexity", "user:2", 101)
+	limitError, ok := err.(*Error)
+	if !ok || limitError.Delay != 0 {
+		t.Fatalf("err = %v, want an *Error without a delay for more than the burst", err)
+	}
+	if _, ok := limitError.Extensions()["retryAfter"]; ok {
+		t.Error("requests that never fit must not ask to be retried")
+	}
+}
@@ -0,0 +1,205 @@
+package rc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/throttle"
+)
+
+const DefaultBaseURL = "https://rc.dukfaar.com"
+
+var ErrResponseTooLarge = errors.New("rc response exceeds size limit")
+
+// Item is the part of an RC item the importer needs.
+type Item struct {
+	ID   string `json:"_id"`
+	Name string `json:"name"`
+}
+
+// Client talks to the RC api. Requests failing in transport or with a server
+// error are retried with exponential backoff as long as the context allows it.
+type Client struct {
+	BaseURL         string
+	HTTPClient      *http.Client
+	Retries         int
+	Backoff         time.Duration
+	MaxBackoff      time.Duration
+	MaxResponseSize int64
+	// Limiter throttles every request attempt, retries included.
+	Limiter *throttle.Limiter
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:         strings.TrimRight(baseURL, "/"),
+		HTTPClient:      &http.Client{Timeout: 60 * time.Second},
+		Retries:         3,
+		Backoff:         500 * time.Millisecond,
+		MaxBackoff:      10 * time.Second,
+		MaxResponseSize: 256 << 20,
+	}
+}
+
+// Recipes returns all recipes known to RC as decoded JSON objects.
+func (c *Client) Recipes(ctx context.Context) ([]map[string]interface{}, error) {
+	var recipes []map[string]interface{}
+	err := c.getJSON(ctx, "/api/recipe", &recipes)
+	return recipes, err
+}
+
+func (c *Client) Item(ctx context.Context, id string) (*Item, error) {
+	var item Item
+	err := c.getJSON(ctx, "/api/item/"+url.PathEscape(id), &item)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+type statusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("rc request %v failed with status %v", e.URL, e.StatusCode)
+}
+
+// transportError is a failure to talk to RC, as opposed to an answer RC
+// gave. Only those and server errors are worth another attempt.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+func retryable(err error) bool {
+	switch err := err.(type) {
+	case *transportError:
+		return true
+	case *statusError:
+		return err.StatusCode >= 500
+	default:
+		return false
+	}
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, out interface{}) error {
+	backoff := c.Backoff
+
+	var err error
+	for attempt := 0; attempt <= c.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			backoff *= 2
+			if backoff > c.MaxBackoff {
+				backoff = c.MaxBackoff
+			}
+		}
+
+		err = c.Limiter.Do(ctx, func() error {
+			return c.doGetJSON(ctx, path, out)
+		})
+		if err == nil || ctx.Err() != nil || !retryable(err) {
+			break
+		}
+
+		logging.FromContext(ctx).Warn("RC request failed", "path", path, "attempt", attempt+1, "error", err)
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if transportErr, ok := err.(*transportError); ok {
+		return transportErr.err
+	}
+	return err
+}
+
+// doGetJSON decodes the response into a fresh value and only stores it in
+// out once it is complete, so a failed attempt leaves nothing behind.
+func (c *Client) doGetJSON(ctx context.Context, path string, out interface{}) error {
+	request, err := http.NewRequest(http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Accept", "application/json")
+
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return &transportError{err: err}
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, io.LimitReader(response.Body, 4096))
+		return &statusError{StatusCode: response.StatusCode, URL: request.URL.String()}
+	}
+
+	var body io.Reader = &transportReader{reader: response.Body}
+	if c.MaxResponseSize > 0 {
+		body = &limitedReader{reader: body, remaining: c.MaxResponseSize}
+	}
+
+	target := reflect.New(reflect.TypeOf(out).Elem())
+	if err := json.NewDecoder(body).Decode(target.Interface()); err != nil {
+		return err
+	}
+
+	reflect.ValueOf(out).Elem().Set(target.Elem())
+	return nil
+}
+
+// transportReader marks errors reading the body as transport errors, a
+// connection dropped halfway through is retried like one that failed to open.
+type transportReader struct {
+	reader io.Reader
+}
+
+func (t *transportReader) Read(p []byte) (int, error) {
+	n, err := t.reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = &transportError{err: err}
+	}
+	return n, err
+}
+
+// limitedReader fails instead of silently truncating like io.LimitReader,
+// so an oversized response isn't mistaken for broken JSON.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		return 0, ErrResponseTooLarge
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+
+	n, err := l.reader.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,161 @@
+package rc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for server that retries without waiting.
+func newTestClient(server *httptest.Server) *Client {
+	client := NewClient(server.URL)
+	client.Backoff = time.Millisecond
+	client.MaxBackoff = time.Millisecond
+	return client
+}
+
+// respond serves the responses in order, repeating the last one.
+func respond(calls *int32, responses ...func(w http.ResponseWriter)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		call := int(atomic.AddInt32(calls, 1)) - 1
+		if call >= len(responses) {
+			call = len(responses) - 1
+		}
+		responses[call](w)
+	}
+}
+
+func status(code int) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(code)
+	}
+}
+
+func body(data string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Write([]byte(data))
+	}
+}
+
+func TestItemRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []func(w http.ResponseWriter)
+		wantCalls int32
+		wantErr   bool
+	}{
+		{"ok", []func(w http.ResponseWriter){body(`{"_id":"1","name":"Iron"}`)}, 1, false},
+		{"server error is retried", []func(w http.ResponseWriter){status(500), status(503), body(`{"_id":"1","name":"Iron"}`)}, 3, false},
+		{"server errors until retries run out", []func(w http.ResponseWriter){status(502)}, 4, true},
+		{"not found is final", []func(w http.ResponseWriter){status(404)}, 1, true},
+		{"too many requests is final", []func(w http.ResponseWriter){status(429)}, 1, true},
+		{"broken json is final", []func(w http.ResponseWriter){body(`{"_id":`), body(`{"_id":"1","name":"Iron"}`)}, 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(respond(&calls, test.responses...))
+			defer server.Close()
+
+			item, err := newTestClient(server).Item(context.Background(), "1")
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, want error %v", err, test.wantErr)
+			}
+			if calls != test.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, test.wantCalls)
+			}
+			if !test.wantErr && (item.ID != "1" || item.Name != "Iron") {
+				t.Errorf("item = %+v", item)
+			}
+		})
+	}
+}
+
+func TestDecodeDoesNotLeaveStaleFields(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(respond(&calls, body(`{"_id":"2"}`), body(`{"_id":"3","name":`)))
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	item := Item{ID: "1", Name: "Iron"}
+	if err := client.getJSON(context.Background(), "/api/item/2", &item); err != nil {
+		t.Fatal(err)
+	}
+	if item.ID != "2" || item.Name != "" {
+		t.Errorf("item = %+v, want only the fields of the response", item)
+	}
+
+	var failed Item
+	if err := client.getJSON(context.Background(), "/api/item/3", &failed); err == nil {
+		t.Fatal("expected a decode error")
+	}
+	if failed != (Item{}) {
+		t.Errorf("failed decode left %+v behind", failed)
+	}
+}
+
+func TestResponseSizeLimit(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(respond(&calls, body(`{"_id":"1","name":"`+strings.Repeat("x", 1024)+`"}`)))
+	defer server.Close()
+
+	client := newTestClient(server)
+	client.MaxResponseSize = 100
+
+	_, err := client.Item(context.Background(), "1")
+	if err != ErrResponseTooLarge {
+		t.Fatalf("err = %v, want ErrResponseTooLarge", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, oversized responses must not be retried", calls)
+	}
+}
+
+func TestTimeoutIsRetried(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte(`{"_id":"1","name":"Iron"}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	client.HTTPClient.Timeout = 50 * time.Millisecond
+
+	item, err := client.Item(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.ID != "1" || calls != 2 {
+		t.Errorf("item = %+v after %d calls, want the second attempt to succeed", item, calls)
+	}
+}
+
+func TestContextEndsRetries(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(respond(&calls, status(500)))
+	defer server.Close()
+
+	client := newTestClient(server)
+	client.Backoff = time.Hour
+	client.MaxBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.Item(ctx, "1")
+	if err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
@@ -0,0 +1,87 @@
+package recipe
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// FieldDiff describes a single changed field, with both values encoded as JSON.
+type FieldDiff struct {
+	Field  string `json:"field" bson:"field"`
+	Before string `json:"before,omitempty" bson:"before,omitempty"`
+	After  string `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+// Diff compares two snapshots field by field. A missing snapshot is treated
+// like an empty recipe.
+func Diff(before *Model, after *Model) []FieldDiff {
+	var empty Model
+	if before == nil {
+		before = &empty
+	}
+	if after == nil {
+		after = &empty
+	}
+
+	beforeValue := reflect.ValueOf(before).Elem()
+	afterValue := reflect.ValueOf(after).Elem()
+	modelType := beforeValue.Type()
+
+	diffs := make([]FieldDiff, 0)
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if !fieldEqual(beforeValue.Field(i), afterValue.Field(i)) {
+			diffs = append(diffs, FieldDiff{
+				Field:  jsonFieldName(field),
+				Before: encodeFieldValue(beforeValue.Field(i)),
+				After:  encodeFieldValue(afterValue.Field(i)),
+			})
+		}
+	}
+
+	return diffs
+}
+
+func encodeFieldValue(value reflect.Value) string {
+	if isEmptyValue(value) {
+		return ""
+	}
+
+	encoded, err := json.Marshal(value.Interface())
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+func isEmptyValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	case reflect.Slice, reflect.Map, reflect.String:
+		return value.Len() == 0
+	}
+	return false
+}
+
+func fieldEqual(a reflect.Value, b reflect.Value) bool {
+	// empty slices are dropped by omitempty, so nil and empty are the same thing here
+	if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
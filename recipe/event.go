@@ -0,0 +1,99 @@
+package recipe
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/recipeBackend/logging"
+)
+
+// EventSchemaVersion is increased whenever the layout of Event changes in a
+// way consumers have to know about.
+const EventSchemaVersion = 1
+
+const (
+	CreatedEventTopic = "recipe.created"
+	UpdatedEventTopic = "recipe.updated"
+	DeletedEventTopic = "recipe.deleted"
+)
+
+// Event is the envelope emitted for every change to a recipe.
+// Consumers should use ID to deduplicate redeliveries and ChangedFields to
+// decide whether an update is relevant to them. CorrelationID is the id of
+// the request or event that caused the change, TraceContext the trace
+// context of the change.
+type Event struct {
+	ID            string            `json:"id"`
+	Type          string            `json:"type"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Timestamp     time.Time         `json:"timestamp"`
+	Actor         string            `json:"actor,omitempty"`
+	CorrelationID string            `json:"correlationId,omitempty"`
+	TraceContext  map[string]string `json:"traceContext,omitempty"`
+	NamespaceID   string            `json:"namespaceId,omitempty"`
+	RecipeID      string            `json:"recipeId"`
+	Before        *Model            `json:"before,omitempty"`
+	After         *Model            `json:"after,omitempty"`
+	ChangedFields []string          `json:"changedFields,omitempty"`
+}
+
+func (e *Event) SetTraceContext(traceContext map[string]string) {
+	e.TraceContext = traceContext
+}
+
+// ActorFromContext returns the id of the authenticated user that triggered
+// the current operation, or an empty string for internal operations.
+func ActorFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	if userID, ok := ctx.Value("userId").(string); ok {
+		return userID
+	}
+
+	return ""
+}
+
+func NewEvent(ctx context.Context, eventType string, before *Model, after *Model) *Event {
+	event := &Event{
+		ID:            bson.NewObjectId().Hex(),
+		Type:          eventType,
+		SchemaVersion: EventSchemaVersion,
+		Timestamp:     time.Now().UTC(),
+		Actor:         ActorFromContext(ctx),
+		CorrelationID: logging.RequestID(ctx),
+		Before:        before,
+		After:         after,
+		ChangedFields: ChangedFields(before, after),
+	}
+
+	current := after
+	if current == nil {
+		current = before
+	}
+
+	if current != nil {
+		event.RecipeID = current.ID.Hex()
+		if current.NamespaceID != nil {
+			event.NamespaceID = current.NamespaceID.Hex()
+		}
+	}
+
+	return event
+}
+
+// ChangedFields lists the json names of all fields that differ between the
+// two snapshots. A missing snapshot counts as every set field being changed.
+func ChangedFields(before *Model, after *Model) []string {
+	diffs := Diff(before, after)
+
+	changed := make([]string, len(diffs))
+	for i := range diffs {
+		changed[i] = diffs[i].Field
+	}
+
+	return changed
+}
@@ -0,0 +1,375 @@
+package recipe
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	mgo "github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/dukfaar/goUtils/eventbus"
+
+	"github.com/dukfaar/recipeBackend/tracing"
+)
+
+// MemoryService keeps the recipes in memory and evaluates the queries built
+// for MgoService on them, so resolvers can run without a database.
+//
+// Recipes are stored as the documents mgo would write, every read decodes a
+// fresh copy, so callers can't change stored recipes by accident.
+type MemoryService struct {
+	mutex     sync.RWMutex
+	documents map[bson.ObjectId]bson.M
+	eventbus  eventbus.EventBus
+}
+
+func NewMemoryService(eventbus eventbus.EventBus) *MemoryService {
+	return &MemoryService{
+		documents: make(map[bson.ObjectId]bson.M),
+		eventbus:  eventbus,
+	}
+}
+
+func fromDocument(doc bson.M) (*Model, error) {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Model
+	err = bson.Unmarshal(data, &result)
+	return &result, err
+}
+
+// find returns the matching documents in id order, the order mongo returns
+// them in for the queries used here. The query is converted like mgo would
+// send it, so it is compared with the stored documents in the same types.
+func (s *MemoryService) find(query bson.M) ([]bson.M, error) {
+	query, err := toDocument(query)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []bson.M
+	for _, doc := range s.documents {
+		matched, err := matchQuery(doc, query)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			result = append(result, doc)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return strings.Compare(string(result[i]["_id"].(bson.ObjectId)), string(result[j]["_id"].(bson.ObjectId))) < 0
+	})
+
+	return result, nil
+}
+
+func (s *MemoryService) findModels(query bson.M) ([]Model, error) {
+	documents, err := s.find(query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Model, 0, len(documents))
+	for _, doc := range documents {
+		model, err := fromDocument(doc)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *model)
+	}
+
+	return result, nil
+}
+
+func (s *MemoryService) findOne(query bson.M) (*Model, error) {
+	documents, err := s.find(query)
+	if err != nil {
+		return &Model{}, err
+	}
+	if len(documents) == 0 {
+		return &Model{}, mgo.ErrNotFound
+	}
+
+	return fromDocument(documents[0])
+}
+
+func (s *MemoryService) Create(ctx context.Context, model *Model) (*Model, error) {
+	model.ID = bson.NewObjectId()
+
+	doc, err := toDocument(model)
+	if err != nil {
+		return model, err
+	}
+
+	s.mutex.Lock()
+	s.documents[model.ID] = doc
+	s.mutex.Unlock()
+
+	tracing.Emit(ctx, s.eventbus, CreatedEventTopic, NewEvent(ctx, CreatedEventTopic, nil, model))
+
+	return model, nil
+}
+
+// Update replaces the recipe like mgo's UpdateId does. Of the update
+// operators only $set and $unset on top level fields are supported.
+func (s *MemoryService) Update(ctx context.Context, id string, input interface{}) (*Model, error) {
+	before, err := s.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := toDocument(input)
+	if err != nil {
+		return nil, err
+	}
+
+	objectID := bson.ObjectIdHex(id)
+
+	s.mutex.Lock()
+	current, ok := s.documents[objectID]
+	if !ok {
+		s.mutex.Unlock()
+		return nil, mgo.ErrNotFound
+	}
+	s.documents[objectID] = applyUpdate(current, update, objectID)
+	s.mutex.Unlock()
+
+	result, err := s.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tracing.Emit(ctx, s.eventbus, UpdatedEventTopic, NewEvent(ctx, UpdatedEventTopic, before, result))
+
+	return result, nil
+}
+
+func applyUpdate(current bson.M, update bson.M, id bson.ObjectId) bson.M {
+	set, hasSet := update["$set"].(bson.M)
+	unset, hasUnset := update["$unset"].(bson.M)
+
+	if !hasSet && !hasUnset {
+		update["_id"] = id
+		return update
+	}
+
+	result := make(bson.M, len(current))
+	for key, value := range current {
+		result[key] = value
+	}
+	for key, value := range set {
+		result[key] = value
+	}
+	for key := range unset {
+		delete(result, key)
+	}
+
+	return result
+}
+
+func (s *MemoryService) DeleteByID(ctx context.Context, id string) (string, error) {
+	before, err := s.FindByID(id)
+	if err != nil {
+		return id, err
+	}
+
+	s.mutex.Lock()
+	delete(s.documents, bson.ObjectIdHex(id))
+	s.mutex.Unlock()
+
+	tracing.Emit(ctx, s.eventbus, DeletedEventTopic, NewEvent(ctx, DeletedEventTopic, before, nil))
+
+	return id, nil
+}
+
+func (s *MemoryService) FindByID(id string) (*Model, error) {
+	if !bson.IsObjectIdHex(id) {
+		return &Model{}, mgo.ErrNotFound
+	}
+
+	return s.findOne(bson.M{"_id": bson.ObjectIdHex(id)})
+}
+
+func (s *MemoryService) FindByItemID(itemID bson.ObjectId) ([]Model, error) {
+	return s.findModels(bson.M{
+		"$or": []bson.M{
+			{InputItemIDField: itemID},
+			{OutputItemIDField: itemID},
+		},
+	})
+}
+
+func (s *MemoryService) FindByNamespaceID(namespaceID bson.ObjectId) ([]Model, error) {
+	query := s.MakeBaseQuery()
+	query["namespaceId"] = namespaceID
+
+	return s.findModels(query)
+}
+
+func (s *MemoryService) FindByExternalID(source string, externalID string) (*Model, error) {
+	return s.findOne(bson.M{"importSource": source, "externalId": externalID})
+}
+
+func (s *MemoryService) CountByNamespace() ([]NamespaceCount, error) {
+	var result []NamespaceCount
+	indexByNamespace := make(map[bson.ObjectId]int)
+
+	documents, err := s.find(s.MakeBaseQuery())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, doc := range documents {
+		namespaceID, _ := doc["namespaceId"].(bson.ObjectId)
+
+		index, ok := indexByNamespace[namespaceID]
+		if !ok {
+			index = len(result)
+			indexByNamespace[namespaceID] = index
+
+			count := NamespaceCount{}
+			if namespaceID != "" {
+				count.NamespaceID = &namespaceID
+			}
+			result = append(result, count)
+		}
+
+		result[index].Count++
+	}
+
+	return result, nil
+}
+
+// HasElementBeforeID, HasElementAfterID and Count ignore the base query,
+// like their counterparts of BaseMgoServiceWithQuery do.
+func (s *MemoryService) HasElementBeforeID(id string) (bool, error) {
+	return s.HasElementBeforeIDWithQuery(bson.M{}, id)
+}
+
+func (s *MemoryService) HasElementAfterID(id string) (bool, error) {
+	return s.HasElementAfterIDWithQuery(bson.M{}, id)
+}
+
+func (s *MemoryService) Count() (int, error) {
+	return s.CountWithQuery(bson.M{})
+}
+
+func (s *MemoryService) List(first *int32, last *int32, before *string, after *string) ([]Model, error) {
+	query := s.MakeBaseQuery()
+	s.MakeListQuery(query, before, after)
+
+	return s.PerformListQuery(query, first, last, before, after)
+}
+
+func withIDCondition(query bson.M, op string, id string) bson.M {
+	result := make(bson.M, len(query)+1)
+	for key, value := range query {
+		result[key] = value
+	}
+
+	return bson.M{"$and": []bson.M{result, {"_id": bson.M{op: bson.ObjectIdHex(id)}}}}
+}
+
+func (s *MemoryService) HasElementBeforeIDWithQuery(query bson.M, id string) (bool, error) {
+	if !bson.IsObjectIdHex(id) {
+		return false, nil
+	}
+
+	documents, err := s.find(withIDCondition(query, "$lt", id))
+	return len(documents) > 0, err
+}
+
+func (s *MemoryService) HasElementAfterIDWithQuery(query bson.M, id string) (bool, error) {
+	if !bson.IsObjectIdHex(id) {
+		return false, nil
+	}
+
+	documents, err := s.find(withIDCondition(query, "$gt", id))
+	return len(documents) > 0, err
+}
+
+func (s *MemoryService) CountWithQuery(query bson.M) (int, error) {
+	documents, err := s.find(query)
+	return len(documents), err
+}
+
+// MakeBaseQuery hides archived recipes from every listing and count.
+func (s *MemoryService) MakeBaseQuery() bson.M {
+	return bson.M{"archivedAt": bson.M{"$exists": false}}
+}
+
+func (s *MemoryService) MakeListQuery(query bson.M, before *string, after *string) {
+	idQuery := bson.M{}
+	if before != nil && bson.IsObjectIdHex(*before) {
+		idQuery["$lt"] = bson.ObjectIdHex(*before)
+	}
+	if after != nil && bson.IsObjectIdHex(*after) {
+		idQuery["$gt"] = bson.ObjectIdHex(*after)
+	}
+
+	if len(idQuery) > 0 {
+		query["_id"] = idQuery
+	}
+}
+
+func (s *MemoryService) PerformQuery(query bson.M) *Model {
+	result, _ := s.findOne(query)
+	return result
+}
+
+func (s *MemoryService) PerformListQuery(query bson.M, first *int32, last *int32, before *string, after *string) ([]Model, error) {
+	result, err := s.findModels(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if first != nil {
+		result = result[:clampedSize(*first, len(result))]
+	}
+	if last != nil {
+		result = result[len(result)-clampedSize(*last, len(result)):]
+	}
+
+	return result, nil
+}
+
+// clampedSize limits a client's first or last to 0..available.
+func clampedSize(size int32, available int) int {
+	if size < 0 {
+		return 0
+	}
+	if int(size) > available {
+		return available
+	}
+	return int(size)
+}
+
+func (s *MemoryService) Iterate(query bson.M, handle func(*Model) error) error {
+	documents, err := s.find(query)
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range documents {
+		model, err := fromDocument(doc)
+		if err != nil {
+			return err
+		}
+
+		if err := handle(model); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,8 @@
 package recipe
 
 import (
+	"time"
+
 	"github.com/dukfaar/goUtils/relay"
 	"github.com/globalsign/mgo/bson"
 	graphql "github.com/graph-gophers/graphql-go"
@@ -11,6 +13,9 @@ type InOutElement struct {
 	Amount int32         `json:"amount,omitempty"`
 }
 
+// mgo doesn't inline embedded structs, the elements are stored as
+// {inoutelement: {_id, amount}}. Queries for the items of a recipe use
+// InputItemIDField and OutputItemIDField.
 type InputElement struct {
 	InOutElement
 }
@@ -19,6 +24,11 @@ type OutputElement struct {
 	InOutElement
 }
 
+const (
+	InputItemIDField  = "inputs.inoutelement._id"
+	OutputItemIDField = "outputs.inoutelement._id"
+)
+
 type Model struct {
 	ID                    bson.ObjectId   `json:"_id,omitempty" bson:"_id,omitempty"`
 	Inputs                []InputElement  `json:"inputs,omitempty"`
@@ -30,6 +40,125 @@ type Model struct {
 	RequiredControl       *int32          `json:"requiredControl,omitempty" bson:"requiredControl,omitempty"`
 	RequiredCraftsmanship *int32          `json:"requiredCraftsmanship,omitempty" bson:"requiredCraftsmanship,omitempty"`
 	Stars                 *int32          `json:"stars,omitempty" bson:"stars,omitempty"`
+	BrokenReferences      []bson.ObjectId `json:"brokenReferences,omitempty" bson:"brokenReferences,omitempty"`
+	ArchivedAt            *time.Time      `json:"archivedAt,omitempty" bson:"archivedAt,omitempty"`
+	ImportSource          *string         `json:"importSource,omitempty" bson:"importSource,omitempty"`
+	ExternalID            *string         `json:"externalId,omitempty" bson:"externalId,omitempty"`
+}
+
+func (m *Model) ReferencesItem(itemID bson.ObjectId) bool {
+	for _, input := range m.Inputs {
+		if input.ItemID == itemID {
+			return true
+		}
+	}
+	for _, output := range m.Outputs {
+		if output.ItemID == itemID {
+			return true
+		}
+	}
+	return false
+}
+
+// AddBrokenReference marks itemID as broken and reports whether it wasn't
+// marked before.
+func (m *Model) AddBrokenReference(itemID bson.ObjectId) bool {
+	for _, broken := range m.BrokenReferences {
+		if broken == itemID {
+			return false
+		}
+	}
+	m.BrokenReferences = append(m.BrokenReferences, itemID)
+	return true
+}
+
+// KeepStoredFields copies what an import or a mutation doesn't set from the
+// stored version of the recipe onto m, so replacing the stored document with m
+// keeps them. Broken references to items m no longer uses are dropped.
+func (m *Model) KeepStoredFields(stored *Model) {
+	m.ID = stored.ID
+	m.ArchivedAt = stored.ArchivedAt
+	if m.NamespaceID == nil {
+		m.NamespaceID = stored.NamespaceID
+	}
+	if m.ImportSource == nil {
+		m.ImportSource = stored.ImportSource
+	}
+	if m.ExternalID == nil {
+		m.ExternalID = stored.ExternalID
+	}
+
+	m.BrokenReferences = nil
+	for _, broken := range stored.BrokenReferences {
+		if m.ReferencesItem(broken) {
+			m.BrokenReferences = append(m.BrokenReferences, broken)
+		}
+	}
+}
+
+func (m *Model) removeBrokenReference(itemID bson.ObjectId) bool {
+	kept := m.BrokenReferences[:0]
+	for _, broken := range m.BrokenReferences {
+		if broken != itemID {
+			kept = append(kept, broken)
+		}
+	}
+	removed := len(kept) != len(m.BrokenReferences)
+	m.BrokenReferences = kept
+	return removed
+}
+
+// ReplaceItem rewrites all references from oldID to newID. Elements that end up
+// referencing the same item are combined by adding up their amounts. It
+// reports whether the recipe changed.
+func (m *Model) ReplaceItem(oldID bson.ObjectId, newID bson.ObjectId) bool {
+	changed := false
+
+	inputs := make([]InputElement, 0, len(m.Inputs))
+	for _, input := range m.Inputs {
+		if input.ItemID == oldID {
+			input.ItemID = newID
+			changed = true
+		}
+		inputs = appendOrMergeInput(inputs, input)
+	}
+	m.Inputs = inputs
+
+	outputs := make([]OutputElement, 0, len(m.Outputs))
+	for _, output := range m.Outputs {
+		if output.ItemID == oldID {
+			output.ItemID = newID
+			changed = true
+		}
+		outputs = appendOrMergeOutput(outputs, output)
+	}
+	m.Outputs = outputs
+
+	if m.removeBrokenReference(oldID) {
+		changed = true
+	}
+
+	return changed
+}
+
+func appendOrMergeInput(list []InputElement, element InputElement) []InputElement {
+	for i := range list {
+		if list[i].ItemID == element.ItemID {
+			list[i].Amount += element.Amount
+			return list
+		}
+	}
+	return append(list, element)
+}
+
+func appendOrMergeOutput(list []OutputElement, element OutputElement) []OutputElement {
+	for i := range list {
+		if list[i].ItemID == element.ItemID {
+			list[i].Amount += element.Amount
+			return list
+		}
+	}
+	return append(list, element)
 }
 
 type MutationInOutElement struct {
@@ -54,6 +183,13 @@ type Recipe {
 	requiredControl: Int
 	requiredCraftsmanship: Int
 	stars: Int
+	brokenReferences: [ID]
+	archivedAt: String
+}
+
+type NamespaceRecipeCount {
+	namespaceId: ID
+	count: Int!
 }
 
 type RecipeInput {
@@ -0,0 +1,326 @@
+package recipe
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// This file evaluates mongo queries against documents in memory. It covers
+// the subset the resolvers and services build: equality on dotted paths with
+// array traversal, $exists, the comparison operators, $in, $nin and the
+// logical $or, $and and $nor. Anything else is an error, so a query that
+// would silently behave differently than on mongo is noticed right away.
+
+// toDocument converts v the way mgo would store it.
+func toDocument(v interface{}) (bson.M, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bson.M
+	err = bson.Unmarshal(data, &doc)
+	return doc, err
+}
+
+func matchQuery(doc bson.M, query bson.M) (bool, error) {
+	for key, cond := range query {
+		var matched bool
+		var err error
+
+		switch key {
+		case "$or":
+			matched, err = matchAny(doc, cond)
+		case "$and":
+			matched, err = matchAll(doc, cond)
+		case "$nor":
+			matched, err = matchAny(doc, cond)
+			matched = !matched
+		default:
+			if strings.HasPrefix(key, "$") {
+				return false, fmt.Errorf("unsupported query operator %v", key)
+			}
+			matched, err = matchField(lookup(doc, strings.Split(key, ".")), cond)
+		}
+
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func matchAny(doc bson.M, cond interface{}) (bool, error) {
+	queries, err := subQueries(cond)
+	if err != nil {
+		return false, err
+	}
+
+	for _, sub := range queries {
+		matched, err := matchQuery(doc, sub)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+func matchAll(doc bson.M, cond interface{}) (bool, error) {
+	queries, err := subQueries(cond)
+	if err != nil {
+		return false, err
+	}
+
+	for _, sub := range queries {
+		matched, err := matchQuery(doc, sub)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func subQueries(cond interface{}) ([]bson.M, error) {
+	list, ok := cond.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("logical operator needs an array, got %T", cond)
+	}
+
+	queries := make([]bson.M, len(list))
+	for i := range list {
+		query, ok := list[i].(bson.M)
+		if !ok {
+			return nil, fmt.Errorf("logical operator needs documents, got %T", list[i])
+		}
+		queries[i] = query
+	}
+	return queries, nil
+}
+
+// lookup returns every value found at path. Arrays are traversed like mongo
+// does, numeric parts also index into them.
+func lookup(value interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		return []interface{}{value}
+	}
+
+	switch v := value.(type) {
+	case bson.M:
+		child, ok := v[path[0]]
+		if !ok {
+			return nil
+		}
+		return lookup(child, path[1:])
+	case []interface{}:
+		var result []interface{}
+		if index, err := strconv.Atoi(path[0]); err == nil {
+			if index >= 0 && index < len(v) {
+				result = append(result, lookup(v[index], path[1:])...)
+			}
+		}
+		for _, element := range v {
+			if _, ok := element.(bson.M); ok {
+				result = append(result, lookup(element, path)...)
+			}
+		}
+		return result
+	}
+
+	return nil
+}
+
+// candidates are the values a condition is compared with: the found values
+// and the elements of found arrays.
+func candidates(values []interface{}) []interface{} {
+	var result []interface{}
+	for _, value := range values {
+		result = append(result, value)
+		if list, ok := value.([]interface{}); ok {
+			result = append(result, list...)
+		}
+	}
+	return result
+}
+
+func operators(cond interface{}) (bson.M, bool) {
+	ops, ok := cond.(bson.M)
+	if !ok || len(ops) == 0 {
+		return nil, false
+	}
+
+	for key := range ops {
+		if !strings.HasPrefix(key, "$") {
+			return nil, false
+		}
+	}
+	return ops, true
+}
+
+func matchField(values []interface{}, cond interface{}) (bool, error) {
+	ops, ok := operators(cond)
+	if !ok {
+		return anyEqual(values, cond), nil
+	}
+
+	for op, arg := range ops {
+		var matched bool
+		var err error
+
+		switch op {
+		case "$exists":
+			exists, _ := arg.(bool)
+			matched = exists == (len(values) > 0)
+		case "$eq":
+			matched = anyEqual(values, arg)
+		case "$ne":
+			matched = !anyEqual(values, arg)
+		case "$gt", "$gte", "$lt", "$lte":
+			matched = anyCompare(values, arg, op)
+		case "$in":
+			matched, err = anyIn(values, arg)
+		case "$nin":
+			matched, err = anyIn(values, arg)
+			matched = !matched
+		default:
+			err = fmt.Errorf("unsupported query operator %v", op)
+		}
+
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func anyEqual(values []interface{}, expected interface{}) bool {
+	if expected == nil && len(values) == 0 {
+		// like in mongo, null matches missing fields
+		return true
+	}
+
+	for _, candidate := range candidates(values) {
+		if equalValues(candidate, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyIn(values []interface{}, arg interface{}) (bool, error) {
+	list, ok := arg.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("$in needs an array, got %T", arg)
+	}
+
+	for _, expected := range list {
+		if anyEqual(values, expected) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func anyCompare(values []interface{}, arg interface{}, op string) bool {
+	for _, candidate := range candidates(values) {
+		result, ok := compareValues(candidate, arg)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case op == "$gt" && result > 0,
+			op == "$gte" && result >= 0,
+			op == "$lt" && result < 0,
+			op == "$lte" && result <= 0:
+			return true
+		}
+	}
+	return false
+}
+
+func equalValues(a interface{}, b interface{}) bool {
+	if result, ok := compareValues(a, b); ok {
+		return result == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// compareValues orders two scalars of the same kind. ok is false for values
+// mongo wouldn't compare with each other.
+func compareValues(a interface{}, b interface{}) (result int, ok bool) {
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	switch av := a.(type) {
+	case string:
+		bv, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(av, bv), true
+	case bson.ObjectId:
+		bv, ok := b.(bson.ObjectId)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(string(av), string(bv)), true
+	case time.Time:
+		bv, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case av.Before(bv):
+			return -1, true
+		case av.After(bv):
+			return 1, true
+		}
+		return 0, true
+	case bool:
+		bv, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case av == bv:
+			return 0, true
+		case !av:
+			return -1, true
+		}
+		return 1, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,180 @@
+package recipe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+func int32Pointer(v int32) *int32 { return &v }
+
+func TestMatchQuery(t *testing.T) {
+	iron, copper, tin := bson.NewObjectId(), bson.NewObjectId(), bson.NewObjectId()
+	namespace, otherNamespace := bson.NewObjectId(), bson.NewObjectId()
+	archivedAt := time.Now()
+
+	model := Model{
+		ID:            bson.NewObjectId(),
+		Inputs:        []InputElement{{InOutElement{ItemID: iron, Amount: 2}}, {InOutElement{ItemID: copper, Amount: 1}}},
+		Outputs:       []OutputElement{{InOutElement{ItemID: tin, Amount: 1}}},
+		NamespaceID:   &namespace,
+		CraftingLevel: int32Pointer(15),
+		ArchivedAt:    &archivedAt,
+	}
+	doc, err := toDocument(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query bson.M
+		want  bool
+	}{
+		{"empty query", bson.M{}, true},
+		{"equality", bson.M{"namespaceId": namespace}, true},
+		{"equality mismatch", bson.M{"namespaceId": otherNamespace}, false},
+		{"dotted path into array", bson.M{InputItemIDField: copper}, true},
+		{"dotted path into other array", bson.M{OutputItemIDField: copper}, false},
+		{"dotted path with operator", bson.M{"inputs.inoutelement.amount": bson.M{"$gte": 2}}, true},
+		{"array index", bson.M{"inputs.1.inoutelement._id": copper}, true},
+		{"array index mismatch", bson.M{"inputs.0.inoutelement._id": copper}, false},
+		{"array index out of range", bson.M{"inputs.5": bson.M{"$exists": true}}, false},
+		{"exists", bson.M{"archivedAt": bson.M{"$exists": true}}, true},
+		{"not exists", bson.M{"archivedAt": bson.M{"$exists": false}}, false},
+		{"missing field not exists", bson.M{"brokenReferences.0": bson.M{"$exists": false}}, true},
+		{"null matches missing field", bson.M{"stars": nil}, true},
+		{"in", bson.M{"namespaceId": bson.M{"$in": []bson.ObjectId{otherNamespace, namespace}}}, true},
+		{"in array field", bson.M{InputItemIDField: bson.M{"$in": []bson.ObjectId{tin, iron}}}, true},
+		{"in mismatch", bson.M{"namespaceId": bson.M{"$in": []bson.ObjectId{otherNamespace}}}, false},
+		{"nin", bson.M{"namespaceId": bson.M{"$nin": []bson.ObjectId{otherNamespace}}}, true},
+		{"ne", bson.M{"craftingLevel": bson.M{"$ne": 15}}, false},
+		{"range", bson.M{"craftingLevel": bson.M{"$gt": 10, "$lt": 20}}, true},
+		{"range mismatch", bson.M{"craftingLevel": bson.M{"$gt": 15}}, false},
+		{"number compared with string", bson.M{"craftingLevel": bson.M{"$lt": "20"}}, false},
+		{"or", bson.M{"$or": []bson.M{{InputItemIDField: tin}, {OutputItemIDField: tin}}}, true},
+		{"or mismatch", bson.M{"$or": []bson.M{{InputItemIDField: tin}, {"namespaceId": otherNamespace}}}, false},
+		{"and", bson.M{"$and": []bson.M{{InputItemIDField: iron}, {OutputItemIDField: tin}}}, true},
+		{"nor", bson.M{"$nor": []bson.M{{InputItemIDField: tin}}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query, err := toDocument(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := matchQuery(doc, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("matchQuery(%v) = %v, want %v", test.query, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMatchQueryErrors(t *testing.T) {
+	doc := bson.M{"_id": bson.NewObjectId(), "stars": 1}
+
+	tests := []struct {
+		name  string
+		query bson.M
+	}{
+		{"unsupported logical operator", bson.M{"$where": "this.stars > 0"}},
+		{"unsupported field operator", bson.M{"stars": bson.M{"$regex": "1"}}},
+		{"in without array", bson.M{"stars": bson.M{"$in": 1}}},
+		{"or without array", bson.M{"$or": bson.M{"stars": 1}}},
+		{"or without documents", bson.M{"$or": []int{1}}},
+		{"nested unsupported operator", bson.M{"$or": []bson.M{{"stars": bson.M{"$size": 1}}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query, err := toDocument(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := matchQuery(doc, query); err == nil {
+				t.Errorf("matchQuery(%v) succeeded, want an error", test.query)
+			}
+		})
+	}
+}
+
+func TestMemoryServiceCursorRanges(t *testing.T) {
+	service := NewMemoryService(nil)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		id := bson.NewObjectId()
+		service.documents[id] = bson.M{"_id": id}
+		ids = append(ids, id.Hex())
+	}
+
+	tests := []struct {
+		name   string
+		first  *int32
+		last   *int32
+		before *string
+		after  *string
+		want   []string
+	}{
+		{"all", nil, nil, nil, nil, ids},
+		{"first", int32Pointer(2), nil, nil, nil, ids[:2]},
+		{"last", nil, int32Pointer(2), nil, nil, ids[3:]},
+		{"after", nil, nil, nil, &ids[1], ids[2:]},
+		{"before", nil, nil, &ids[3], nil, ids[:3]},
+		{"between", nil, nil, &ids[4], &ids[0], ids[1:4]},
+		{"first after", int32Pointer(1), nil, nil, &ids[2], ids[3:4]},
+		{"last before", nil, int32Pointer(1), &ids[2], nil, ids[1:2]},
+		{"first beyond the end", int32Pointer(10), nil, nil, nil, ids},
+		{"negative first", int32Pointer(-1), nil, nil, nil, nil},
+		{"negative last", nil, int32Pointer(-3), nil, nil, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			models, err := service.List(test.first, test.last, test.before, test.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, model := range models {
+				got = append(got, model.ID.Hex())
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("got %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+
+	hasBefore, _ := service.HasElementBeforeIDWithQuery(bson.M{}, ids[0])
+	hasAfter, _ := service.HasElementAfterIDWithQuery(bson.M{}, ids[3])
+	if hasBefore || !hasAfter {
+		t.Errorf("hasBefore = %v, hasAfter = %v, want false and true", hasBefore, hasAfter)
+	}
+}
+
+func TestMemoryServiceRejectsUnsupportedQueries(t *testing.T) {
+	service := NewMemoryService(nil)
+	id := bson.NewObjectId()
+	service.documents[id] = bson.M{"_id": id}
+
+	if _, err := service.CountWithQuery(bson.M{"name": bson.M{"$regex": "^Iron"}}); err == nil {
+		t.Error("CountWithQuery succeeded, want an error for $regex")
+	}
+	if _, err := service.PerformListQuery(bson.M{"$where": "true"}, nil, nil, nil, nil); err == nil {
+		t.Error("PerformListQuery succeeded, want an error for $where")
+	}
+}
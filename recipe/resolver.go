@@ -1,6 +1,10 @@
 package recipe
 
-import graphql "github.com/graph-gophers/graphql-go"
+import (
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
 
 type InputElementResolver struct {
 	InputElement *InputElement
@@ -57,6 +61,24 @@ func (r *Resolver) Stars() *int32 {
 	return r.Model.Stars
 }
 
+func (r *Resolver) BrokenReferences() *[]*graphql.ID {
+	l := make([]*graphql.ID, len(r.Model.BrokenReferences))
+	for i := range r.Model.BrokenReferences {
+		id := graphql.ID(r.Model.BrokenReferences[i].Hex())
+		l[i] = &id
+	}
+	return &l
+}
+
+func (r *Resolver) ArchivedAt() *string {
+	if r.Model.ArchivedAt == nil {
+		return nil
+	}
+
+	archivedAt := r.Model.ArchivedAt.Format(time.RFC3339)
+	return &archivedAt
+}
+
 func (r *Resolver) Inputs() *[]*InputElementResolver {
 	l := make([]*InputElementResolver, len(r.Model.Inputs))
 	for i, input := range r.Model.Inputs {
@@ -92,3 +114,20 @@ func (r *OutputElementResolver) Amount() *int32 {
 	result := r.OutputElement.Amount
 	return &result
 }
+
+type NamespaceCountResolver struct {
+	NamespaceCount *NamespaceCount
+}
+
+func (r *NamespaceCountResolver) NamespaceID() *graphql.ID {
+	if r.NamespaceCount.NamespaceID == nil {
+		return nil
+	}
+
+	id := graphql.ID(r.NamespaceCount.NamespaceID.Hex())
+	return &id
+}
+
+func (r *NamespaceCountResolver) Count() int32 {
+	return int32(r.NamespaceCount.Count)
+}
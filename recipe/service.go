@@ -1,18 +1,26 @@
 package recipe
 
 import (
+	"context"
+
 	mgo "github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 
 	"github.com/dukfaar/goUtils/eventbus"
 	"github.com/dukfaar/goUtils/service"
+
+	"github.com/dukfaar/recipeBackend/tracing"
 )
 
 type Service interface {
-	Create(*Model) (*Model, error)
-	DeleteByID(id string) (string, error)
+	Create(context.Context, *Model) (*Model, error)
+	DeleteByID(ctx context.Context, id string) (string, error)
 	FindByID(id string) (*Model, error)
-	Update(string, interface{}) (*Model, error)
+	FindByItemID(itemID bson.ObjectId) ([]Model, error)
+	FindByNamespaceID(namespaceID bson.ObjectId) ([]Model, error)
+	FindByExternalID(source string, externalID string) (*Model, error)
+	CountByNamespace() ([]NamespaceCount, error)
+	Update(context.Context, string, interface{}) (*Model, error)
 
 	HasElementBeforeID(id string) (bool, error)
 	HasElementAfterID(id string) (bool, error)
@@ -30,6 +38,15 @@ type Service interface {
 
 	PerformQuery(query bson.M) *Model
 	PerformListQuery(query bson.M, first *int32, last *int32, before *string, after *string) ([]Model, error)
+
+	// Iterate walks all recipes matching the query in id order without loading
+	// them into memory at once. An error returned by handle stops the iteration.
+	Iterate(query bson.M, handle func(*Model) error) error
+}
+
+type NamespaceCount struct {
+	NamespaceID *bson.ObjectId `bson:"_id"`
+	Count       int            `bson:"count"`
 }
 
 type MgoService struct {
@@ -48,18 +65,23 @@ func NewMgoService(db *mgo.Database, eventbus eventbus.EventBus) *MgoService {
 	}
 }
 
-func (s *MgoService) Create(model *Model) (*Model, error) {
+func (s *MgoService) Create(ctx context.Context, model *Model) (*Model, error) {
 	model.ID = bson.NewObjectId()
 
 	err := s.Collection.Insert(model)
 
 	if err == nil {
-		s.eventbus.Emit("recipe.created", model)
+		tracing.Emit(ctx, s.eventbus, CreatedEventTopic, NewEvent(ctx, CreatedEventTopic, nil, model))
 	}
 
 	return model, err
 }
 
+// MakeBaseQuery hides archived recipes from every listing and count.
+func (s *MgoService) MakeBaseQuery() bson.M {
+	return bson.M{"archivedAt": bson.M{"$exists": false}}
+}
+
 func (s *MgoService) PerformQuery(query bson.M) *Model {
 	var result Model
 	s.Collection.Find(query).One(&result)
@@ -74,8 +96,29 @@ func (s *MgoService) PerformListQuery(query bson.M, first *int32, last *int32, b
 	return result, err
 }
 
-func (s *MgoService) Update(id string, input interface{}) (*Model, error) {
-	err := s.Collection.UpdateId(bson.ObjectIdHex(id), input)
+func (s *MgoService) Iterate(query bson.M, handle func(*Model) error) error {
+	iter := s.Collection.Find(query).Sort("_id").Iter()
+
+	var result Model
+	for iter.Next(&result) {
+		if err := handle(&result); err != nil {
+			iter.Close()
+			return err
+		}
+		result = Model{}
+	}
+
+	return iter.Close()
+}
+
+func (s *MgoService) Update(ctx context.Context, id string, input interface{}) (*Model, error) {
+	before, err := s.FindByID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Collection.UpdateId(bson.ObjectIdHex(id), input)
 
 	if err != nil {
 		return nil, err
@@ -87,16 +130,22 @@ func (s *MgoService) Update(id string, input interface{}) (*Model, error) {
 		return nil, err
 	}
 
-	s.eventbus.Emit("recipe.updated", result)
+	tracing.Emit(ctx, s.eventbus, UpdatedEventTopic, NewEvent(ctx, UpdatedEventTopic, before, result))
 
 	return result, err
 }
 
-func (s *MgoService) DeleteByID(id string) (string, error) {
-	err := s.Collection.RemoveId(bson.ObjectIdHex(id))
+func (s *MgoService) DeleteByID(ctx context.Context, id string) (string, error) {
+	before, err := s.FindByID(id)
+
+	if err != nil {
+		return id, err
+	}
+
+	err = s.Collection.RemoveId(bson.ObjectIdHex(id))
 
 	if err == nil {
-		s.eventbus.Emit("recipe.deleted", id)
+		tracing.Emit(ctx, s.eventbus, DeletedEventTopic, NewEvent(ctx, DeletedEventTopic, before, nil))
 	}
 
 	return id, err
@@ -110,6 +159,48 @@ func (s *MgoService) FindByID(id string) (*Model, error) {
 	return &result, err
 }
 
+func (s *MgoService) FindByItemID(itemID bson.ObjectId) ([]Model, error) {
+	var result []Model
+
+	err := s.Collection.Find(bson.M{
+		"$or": []bson.M{
+			{InputItemIDField: itemID},
+			{OutputItemIDField: itemID},
+		},
+	}).All(&result)
+
+	return result, err
+}
+
+func (s *MgoService) FindByNamespaceID(namespaceID bson.ObjectId) ([]Model, error) {
+	query := s.MakeBaseQuery()
+	query["namespaceId"] = namespaceID
+
+	var result []Model
+	err := s.Collection.Find(query).All(&result)
+
+	return result, err
+}
+
+func (s *MgoService) FindByExternalID(source string, externalID string) (*Model, error) {
+	var result Model
+
+	err := s.Collection.Find(bson.M{"importSource": source, "externalId": externalID}).One(&result)
+
+	return &result, err
+}
+
+func (s *MgoService) CountByNamespace() ([]NamespaceCount, error) {
+	var result []NamespaceCount
+
+	err := s.Collection.Pipe([]bson.M{
+		{"$match": s.MakeBaseQuery()},
+		{"$group": bson.M{"_id": "$namespaceId", "count": bson.M{"$sum": 1}}},
+	}).All(&result)
+
+	return result, err
+}
+
 func (s *MgoService) List(first *int32, last *int32, before *string, after *string) ([]Model, error) {
 	query := s.MakeBaseQuery()
 	s.MakeListQuery(query, before, after)
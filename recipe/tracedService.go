@@ -0,0 +1,168 @@
+package recipe
+
+import (
+	"context"
+
+	mgo "github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/dukfaar/recipeBackend/tracing"
+)
+
+// TracedService starts a span for every method of the wrapped Service that
+// talks to the storage. Most methods take no context, their spans are
+// children of the context the service was bound to with WithContext.
+type TracedService struct {
+	Service
+	ctx context.Context
+}
+
+func NewTracedService(service Service) *TracedService {
+	return &TracedService{
+		Service: service,
+		ctx:     context.Background(),
+	}
+}
+
+// WithContext returns service bound to ctx, if it can be bound at all.
+func WithContext(ctx context.Context, service Service) Service {
+	if traced, ok := service.(*TracedService); ok {
+		return &TracedService{Service: traced.Service, ctx: ctx}
+	}
+	return service
+}
+
+// traceEnd ends span like tracing.End, but doesn't count a missing recipe as
+// a failure.
+func traceEnd(span trace.Span, err error) {
+	if err == mgo.ErrNotFound {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+func (s *TracedService) Create(ctx context.Context, model *Model) (*Model, error) {
+	ctx, span := tracing.Start(ctx, "recipe.Create")
+	result, err := s.Service.Create(ctx, model)
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) Update(ctx context.Context, id string, input interface{}) (*Model, error) {
+	ctx, span := tracing.Start(ctx, "recipe.Update")
+	result, err := s.Service.Update(ctx, id, input)
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) DeleteByID(ctx context.Context, id string) (string, error) {
+	ctx, span := tracing.Start(ctx, "recipe.DeleteByID")
+	result, err := s.Service.DeleteByID(ctx, id)
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) FindByID(id string) (*Model, error) {
+	_, span := tracing.Start(s.ctx, "recipe.FindByID")
+	result, err := s.Service.FindByID(id)
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) FindByItemID(itemID bson.ObjectId) ([]Model, error) {
+	_, span := tracing.Start(s.ctx, "recipe.FindByItemID")
+	result, err := s.Service.FindByItemID(itemID)
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) FindByNamespaceID(namespaceID bson.ObjectId) ([]Model, error) {
+	_, span := tracing.Start(s.ctx, "recipe.FindByNamespaceID")
+	result, err := s.Service.FindByNamespaceID(namespaceID)
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) FindByExternalID(source string, externalID string) (*Model, error) {
+	_, span := tracing.Start(s.ctx, "recipe.FindByExternalID")
+	result, err := s.Service.FindByExternalID(source, externalID)
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) CountByNamespace() ([]NamespaceCount, error) {
+	_, span := tracing.Start(s.ctx, "recipe.CountByNamespace")
+	result, err := s.Service.CountByNamespace()
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) HasElementBeforeID(id string) (bool, error) {
+	_, span := tracing.Start(s.ctx, "recipe.HasElementBeforeID")
+	result, err := s.Service.HasElementBeforeID(id)
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) HasElementAfterID(id string) (bool, error) {
+	_, span := tracing.Start(s.ctx, "recipe.HasElementAfterID")
+	result, err := s.Service.HasElementAfterID(id)
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) Count() (int, error) {
+	_, span := tracing.Start(s.ctx, "recipe.Count")
+	result, err := s.Service.Count()
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) List(first *int32, last *int32, before *string, after *string) ([]Model, error) {
+	_, span := tracing.Start(s.ctx, "recipe.List")
+	result, err := s.Service.List(first, last, before, after)
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) HasElementBeforeIDWithQuery(query bson.M, id string) (bool, error) {
+	_, span := tracing.Start(s.ctx, "recipe.HasElementBeforeIDWithQuery")
+	result, err := s.Service.HasElementBeforeIDWithQuery(query, id)
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) HasElementAfterIDWithQuery(query bson.M, id string) (bool, error) {
+	_, span := tracing.Start(s.ctx, "recipe.HasElementAfterIDWithQuery")
+	result, err := s.Service.HasElementAfterIDWithQuery(query, id)
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) CountWithQuery(query bson.M) (int, error) {
+	_, span := tracing.Start(s.ctx, "recipe.CountWithQuery")
+	result, err := s.Service.CountWithQuery(query)
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) PerformListQuery(query bson.M, first *int32, last *int32, before *string, after *string) ([]Model, error) {
+	_, span := tracing.Start(s.ctx, "recipe.PerformListQuery")
+	result, err := s.Service.PerformListQuery(query, first, last, before, after)
+	traceEnd(span, err)
+	return result, err
+}
+
+func (s *TracedService) PerformQuery(query bson.M) *Model {
+	_, span := tracing.Start(s.ctx, "recipe.PerformQuery")
+	defer span.End()
+	return s.Service.PerformQuery(query)
+}
+
+func (s *TracedService) Iterate(query bson.M, handle func(*Model) error) error {
+	_, span := tracing.Start(s.ctx, "recipe.Iterate")
+	err := s.Service.Iterate(query, handle)
+	traceEnd(span, err)
+	return err
+}
@@ -2,16 +2,13 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/http"
 
 	"github.com/globalsign/mgo/bson"
 
-	"github.com/dukfaar/goUtils/eventbus"
-	dukgraphql "github.com/dukfaar/goUtils/graphql"
-	"github.com/dukfaar/goUtils/permission"
 	"github.com/dukfaar/goUtils/relay"
+	"github.com/dukfaar/recipeBackend/gateway"
+	"github.com/dukfaar/recipeBackend/logging"
 	"github.com/dukfaar/recipeBackend/recipe"
 	graphql "github.com/graph-gophers/graphql-go"
 )
@@ -19,49 +16,74 @@ import (
 type Resolver struct {
 }
 
+// checkObjectIDArguments rejects arguments that aren't ObjectIds, before
+// they are passed to the query helpers below.
+func checkObjectIDArguments(arguments map[string]*string) error {
+	for name, value := range arguments {
+		if value != nil && !bson.IsObjectIdHex(*value) {
+			return fmt.Errorf("invalid %v %q", name, *value)
+		}
+	}
+	return nil
+}
+
 func AddInputOutputToQuery(query bson.M, inputId *string, outputId *string) {
 	if inputId != nil {
-		query["inputs"] = bson.ObjectIdHex(*inputId)
+		query[recipe.InputItemIDField] = bson.ObjectIdHex(*inputId)
 	}
 	if outputId != nil {
-		query["outputs"] = bson.ObjectIdHex(*outputId)
+		query[recipe.OutputItemIDField] = bson.ObjectIdHex(*outputId)
 	}
 }
 
-func (r *Resolver) Recipes(ctx context.Context, args struct {
-	First        *int32
-	Last         *int32
-	Before       *string
-	After        *string
-	InputItemId  *string
-	OutputItemId *string
-}) (*recipe.ConnectionResolver, error) {
-	recipeService := ctx.Value("recipeService").(recipe.Service)
+func AddNamespaceToQuery(query bson.M, namespaceId *string) {
+	if namespaceId != nil {
+		query["namespaceId"] = bson.ObjectIdHex(*namespaceId)
+	}
+}
+
+func AddBrokenToQuery(query bson.M) {
+	query["brokenReferences.0"] = bson.M{"$exists": true}
+}
+
+type recipeConnectionArgs struct {
+	First  *int32
+	Last   *int32
+	Before *string
+	After  *string
+}
+
+type countResult struct {
+	count int
+	err   error
+}
 
-	var totalChannel = make(chan int)
+func recipeConnection(recipeService recipe.Service, args recipeConnectionArgs, addFilters func(bson.M)) (*recipe.ConnectionResolver, error) {
+	var totalChannel = make(chan countResult, 1)
 	go func() {
 		countQuery := recipeService.MakeBaseQuery()
-		AddInputOutputToQuery(countQuery, args.InputItemId, args.OutputItemId)
-		var total, _ = recipeService.CountWithQuery(countQuery)
-		totalChannel <- total
+		addFilters(countQuery)
+		total, err := recipeService.CountWithQuery(countQuery)
+		totalChannel <- countResult{total, err}
 	}()
 
-	var recipesChannel = make(chan []recipe.Model)
-	go func() {
-		listQuery := recipeService.MakeBaseQuery()
-		recipeService.MakeListQuery(listQuery, args.Before, args.After)
-		AddInputOutputToQuery(listQuery, args.InputItemId, args.OutputItemId)
-		result, _ := recipeService.PerformListQuery(listQuery, args.First, args.Last, args.Before, args.After)
-		recipesChannel <- result
-	}()
+	listQuery := recipeService.MakeBaseQuery()
+	recipeService.MakeListQuery(listQuery, args.Before, args.After)
+	addFilters(listQuery)
+	recipes, err := recipeService.PerformListQuery(listQuery, args.First, args.Last, args.Before, args.After)
+	total := <-totalChannel
+	if err != nil {
+		return nil, err
+	}
+	if total.err != nil {
+		return nil, total.err
+	}
 
 	var (
 		start string
 		end   string
 	)
 
-	var recipes = <-recipesChannel
-
 	if len(recipes) == 0 {
 		start, end = "", ""
 	} else {
@@ -69,14 +91,14 @@ func (r *Resolver) Recipes(ctx context.Context, args struct {
 	}
 
 	query := recipeService.MakeBaseQuery()
-	AddInputOutputToQuery(query, args.InputItemId, args.OutputItemId)
+	addFilters(query)
 	hasPreviousPageChannel, hasNextPageChannel := relay.GetHasPreviousAndNextPageWithQuery(query, len(recipes), start, end, recipeService)
 
 	return &recipe.ConnectionResolver{
 		Models: recipes,
 		ConnectionResolver: relay.ConnectionResolver{
 			relay.Connection{
-				Total:           int32(<-totalChannel),
+				Total:           int32(total.count),
 				From:            start,
 				To:              end,
 				HasNextPage:     <-hasNextPageChannel,
@@ -86,22 +108,115 @@ func (r *Resolver) Recipes(ctx context.Context, args struct {
 	}, nil
 }
 
-func setDataOnModel(model *recipe.Model, input *recipe.MutationInput) {
-	model.Inputs = make([]recipe.InputElement, len(*input.Inputs))
-	model.Outputs = make([]recipe.OutputElement, len(*input.Outputs))
+func (r *Resolver) Recipes(ctx context.Context, args struct {
+	First        *int32
+	Last         *int32
+	Before       *string
+	After        *string
+	InputItemId  *string
+	OutputItemId *string
+	NamespaceId  *string
+}) (*recipe.ConnectionResolver, error) {
+	err := checkObjectIDArguments(map[string]*string{
+		"inputItemId":  args.InputItemId,
+		"outputItemId": args.OutputItemId,
+		"namespaceId":  args.NamespaceId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recipeService := ctx.Value("recipeService").(recipe.Service)
+
+	connectionArgs := recipeConnectionArgs{args.First, args.Last, args.Before, args.After}
+	return recipeConnection(recipeService, connectionArgs, func(query bson.M) {
+		AddInputOutputToQuery(query, args.InputItemId, args.OutputItemId)
+		AddNamespaceToQuery(query, args.NamespaceId)
+	})
+}
+
+func (r *Resolver) RecipeCount(ctx context.Context, args struct {
+	NamespaceId string
+}) (int32, error) {
+	if err := checkObjectIDArguments(map[string]*string{"namespaceId": &args.NamespaceId}); err != nil {
+		return 0, err
+	}
+
+	recipeService := ctx.Value("recipeService").(recipe.Service)
+
+	query := recipeService.MakeBaseQuery()
+	AddNamespaceToQuery(query, &args.NamespaceId)
+	count, err := recipeService.CountWithQuery(query)
+
+	return int32(count), err
+}
+
+func (r *Resolver) RecipeCountsByNamespace(ctx context.Context) (*[]*recipe.NamespaceCountResolver, error) {
+	recipeService := ctx.Value("recipeService").(recipe.Service)
+
+	counts, err := recipeService.CountByNamespace()
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]*recipe.NamespaceCountResolver, len(counts))
+	for i := range counts {
+		l[i] = &recipe.NamespaceCountResolver{NamespaceCount: &counts[i]}
+	}
+	return &l, nil
+}
+
+func (r *Resolver) BrokenRecipes(ctx context.Context, args recipeConnectionArgs) (*recipe.ConnectionResolver, error) {
+	recipeService := ctx.Value("recipeService").(recipe.Service)
+
+	return recipeConnection(recipeService, args, AddBrokenToQuery)
+}
+
+// setDataOnModel copies the elements given in input onto model, lists that
+// weren't given are left as they are.
+func setDataOnModel(model *recipe.Model, input *recipe.MutationInput) error {
+	if input == nil {
+		return nil
+	}
+
+	if input.Inputs != nil {
+		model.Inputs = make([]recipe.InputElement, len(*input.Inputs))
+		for i, element := range *input.Inputs {
+			inOut, err := mutationElement("inputs", element)
+			if err != nil {
+				return err
+			}
+			model.Inputs[i] = recipe.InputElement{inOut}
+		}
+	}
+	if input.Outputs != nil {
+		model.Outputs = make([]recipe.OutputElement, len(*input.Outputs))
+		for i, element := range *input.Outputs {
+			inOut, err := mutationElement("outputs", element)
+			if err != nil {
+				return err
+			}
+			model.Outputs[i] = recipe.OutputElement{inOut}
+		}
+	}
+
+	return nil
+}
 
-	for i := range *input.Inputs {
-		model.Inputs[i] = recipe.InputElement{recipe.InOutElement{
-			ItemID: bson.ObjectIdHex(string((*input.Inputs)[i].ItemID)),
-			Amount: int32((*input.Inputs)[i].Amount),
-		}}
+func mutationElement(list string, element *recipe.MutationInOutElement) (recipe.InOutElement, error) {
+	if element == nil {
+		return recipe.InOutElement{}, fmt.Errorf("%v must not contain null", list)
 	}
-	for i := range *input.Outputs {
-		model.Outputs[i] = recipe.OutputElement{recipe.InOutElement{
-			ItemID: bson.ObjectIdHex(string((*input.Outputs)[i].ItemID)),
-			Amount: int32((*input.Outputs)[i].Amount),
-		}}
+
+	itemID := string(element.ItemID)
+	if err := checkObjectIDArguments(map[string]*string{list + ".itemId": &itemID}); err != nil {
+		return recipe.InOutElement{}, err
 	}
+
+	return recipe.InOutElement{
+		ItemID: bson.ObjectIdHex(itemID),
+		Amount: element.Amount,
+	}, nil
 }
 
 func (r *Resolver) CreateRecipe(ctx context.Context, args struct {
@@ -110,9 +225,11 @@ func (r *Resolver) CreateRecipe(ctx context.Context, args struct {
 	recipeService := ctx.Value("recipeService").(recipe.Service)
 
 	inputModel := recipe.Model{}
-	setDataOnModel(&inputModel, args.Input)
+	if err := setDataOnModel(&inputModel, args.Input); err != nil {
+		return nil, err
+	}
 
-	newModel, err := recipeService.Create(&inputModel)
+	newModel, err := recipeService.Create(ctx, &inputModel)
 
 	if err == nil {
 		return &recipe.Resolver{
@@ -127,12 +244,25 @@ func (r *Resolver) UpdateRecipe(ctx context.Context, args struct {
 	Id    string
 	Input *recipe.MutationInput
 }) (*recipe.Resolver, error) {
+	if err := checkObjectIDArguments(map[string]*string{"id": &args.Id}); err != nil {
+		return nil, err
+	}
+
 	recipeService := ctx.Value("recipeService").(recipe.Service)
 
-	inputModel := recipe.Model{}
-	setDataOnModel(&inputModel, args.Input)
+	stored, err := recipeService.FindByID(args.Id)
+	if err != nil {
+		return nil, err
+	}
 
-	newModel, err := recipeService.Update(args.Id, &inputModel)
+	// the update replaces the document, so it starts from the stored recipe
+	inputModel := *stored
+	if err := setDataOnModel(&inputModel, args.Input); err != nil {
+		return nil, err
+	}
+	inputModel.KeepStoredFields(stored)
+
+	newModel, err := recipeService.Update(ctx, args.Id, &inputModel)
 
 	if err == nil {
 		return &recipe.Resolver{
@@ -146,9 +276,13 @@ func (r *Resolver) UpdateRecipe(ctx context.Context, args struct {
 func (r *Resolver) DeleteRecipe(ctx context.Context, args struct {
 	Id string
 }) (*graphql.ID, error) {
+	if err := checkObjectIDArguments(map[string]*string{"id": &args.Id}); err != nil {
+		return nil, err
+	}
+
 	recipeService := ctx.Value("recipeService").(recipe.Service)
 
-	deletedID, err := recipeService.DeleteByID(args.Id)
+	deletedID, err := recipeService.DeleteByID(ctx, args.Id)
 	result := graphql.ID(deletedID)
 
 	if err == nil {
@@ -161,6 +295,10 @@ func (r *Resolver) DeleteRecipe(ctx context.Context, args struct {
 func (r *Resolver) Recipe(ctx context.Context, args struct {
 	Id string
 }) (*recipe.Resolver, error) {
+	if err := checkObjectIDArguments(map[string]*string{"id": &args.Id}); err != nil {
+		return nil, err
+	}
+
 	recipeService := ctx.Value("recipeService").(recipe.Service)
 
 	queryRecipe, err := recipeService.FindByID(args.Id)
@@ -175,142 +313,23 @@ func (r *Resolver) Recipe(ctx context.Context, args struct {
 }
 
 func fetchFFXIVNamespace(ctx context.Context) (string, error) {
-	fetcher := ctx.Value("apigatewayfetcher").(dukgraphql.Fetcher)
-
-	namespaceResult, err := fetcher.Fetch(dukgraphql.Request{
-		Query: "query { namespaceByName(name: \"FFXIV\") { _id name } }",
-	})
-
-	if err != nil {
-		fmt.Printf("Error fetching namespace: %v\n", err)
-		return "", err
-	}
-
-	namespaceResponse := dukgraphql.Response{namespaceResult}
-
-	return namespaceResponse.GetObject("namespaceByName").GetString("_id"), nil
-}
-
-func fetchFFXIVItemByName(ctx context.Context, name string, namespaceId string) (string, error) {
-	fetcher := ctx.Value("apigatewayfetcher").(dukgraphql.Fetcher)
-
-	itemResult, err := fetcher.Fetch(dukgraphql.Request{
-		Query: "query { findItem(name: \"" + name + "\", namespaceId: \"" + namespaceId + "\") { _id } }",
-	})
-
-	if err != nil {
-		fmt.Printf("Error fetching item: %v\n", err)
-		return "", err
-	}
-
-	itemResponse := dukgraphql.Response{itemResult}
-
-	return itemResponse.GetObject("findItem").GetString("_id"), nil
-}
-
-var rcItemMap = make(map[string]string)
-
-func ConvertRcItemIDToItemServiceID(ctx context.Context, id string, namespaceId string) (string, error) {
-	if _, ok := rcItemMap[id]; ok {
-		return rcItemMap[id], nil
-	}
-
-	rcItemResponse, err := http.Get("https://rc.dukfaar.com/api/item/" + id)
-	if err != nil {
-		fmt.Printf("Error getting item: %v\n", err)
-		return "", err
+	namespaceCache := ctx.Value("namespaceCache").(*NamespaceCache)
+	if id, ok := namespaceCache.GetIDByName("FFXIV"); ok {
+		return id, nil
 	}
-	defer rcItemResponse.Body.Close()
 
-	var itemData struct {
-		Id   string `json:"_id"`
-		Name string `json:"name"`
-	}
-	err = json.NewDecoder(rcItemResponse.Body).Decode(&itemData)
+	gatewayClient := ctx.Value("gatewayClient").(*gateway.Client)
 
-	if err != nil {
-		fmt.Printf("Error parsing item data: %v\n", err)
-		return "", err
+	namespaceId, err := gatewayClient.NamespaceIDByName(ctx, "FFXIV")
+	if err == gateway.ErrNotFound {
+		return "", nil
 	}
-
-	newId, err := fetchFFXIVItemByName(ctx, itemData.Name, namespaceId)
-
 	if err != nil {
-		fmt.Printf("Error fetching service item: %v\n", err)
+		logging.FromContext(ctx).Error("fetching namespace failed", "error", err)
 		return "", err
 	}
 
-	rcItemMap[id] = newId
-
-	return newId, nil
-}
-
-func ConvertRecipe(ctx context.Context, recipe map[string]interface{}, namespaceId string) error {
-	delete(recipe, "_id")
-	recipe["namespace"] = namespaceId
-
-	inputs := recipe["inputs"].([]interface{})
-	for inputIndex := range inputs {
-		input := inputs[inputIndex].(map[string]interface{})
-		delete(input, "_id")
-		newId, err := ConvertRcItemIDToItemServiceID(ctx, input["item"].(string), namespaceId)
-		if err != nil {
-			return err
-		}
-		input["item"] = newId
-	}
-
-	outputs := recipe["outputs"].([]interface{})
-	for outputIndex := range outputs {
-		output := outputs[outputIndex].(map[string]interface{})
-		delete(output, "_id")
-		newId, err := ConvertRcItemIDToItemServiceID(ctx, output["item"].(string), namespaceId)
-		if err != nil {
-			return err
-		}
-		output["item"] = newId
-	}
-
-	return nil
-}
-
-func (r *Resolver) RcRecipeImport(ctx context.Context) (string, error) {
-	err := permission.Check(ctx, "mutation.rcRecipeImport")
-	if err != nil {
-		return "No Permission", err
-	}
-
-	rcRecipeResponse, err := http.Get("https://rc.dukfaar.com/api/recipe")
-
-	if err != nil {
-		fmt.Printf("Error getting recipes: %v\n", err)
-		return "Error reading from RC", err
-	}
-	defer rcRecipeResponse.Body.Close()
-
-	var recipeData []interface{}
-	err = json.NewDecoder(rcRecipeResponse.Body).Decode(&recipeData)
-
-	if err != nil {
-		fmt.Printf("Error reading recipes: %v\n", err)
-		return "Error parsing data from RC", err
-	}
-
-	eventbus := ctx.Value("eventbus").(eventbus.EventBus)
-	namespaceId, err := fetchFFXIVNamespace(ctx)
-	if err != nil {
-		return "Error fetching namespace", err
-	}
-
-	go func() {
-		for index := range recipeData {
-			err := ConvertRecipe(ctx, recipeData[index].(map[string]interface{}), namespaceId)
-
-			if err == nil {
-				eventbus.Emit("import.recipe", recipeData[index])
-			}
-		}
-	}()
+	namespaceCache.Set(namespaceId, "FFXIV")
 
-	return "OK", nil
+	return namespaceId, nil
 }
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"github.com/dukfaar/recipeBackend/localbus"
+	"github.com/dukfaar/recipeBackend/recipe"
+)
+
+func newResolverContext() (context.Context, *recipe.MemoryService) {
+	recipeService := recipe.NewMemoryService(localbus.NewBus())
+	return context.WithValue(context.Background(), "recipeService", recipe.Service(recipeService)), recipeService
+}
+
+func mutationInput(input bson.ObjectId, output bson.ObjectId) *recipe.MutationInput {
+	return &recipe.MutationInput{
+		Inputs:  &[]*recipe.MutationInOutElement{{ItemID: graphql.ID(input.Hex()), Amount: 2}},
+		Outputs: &[]*recipe.MutationInOutElement{{ItemID: graphql.ID(output.Hex()), Amount: 1}},
+	}
+}
+
+func stringPointer(s string) *string { return &s }
+
+func TestResolverRecipes(t *testing.T) {
+	ctx, recipeService := newResolverContext()
+	resolver := &Resolver{}
+
+	ore, bar, plate := bson.NewObjectId(), bson.NewObjectId(), bson.NewObjectId()
+	namespace := bson.NewObjectId()
+
+	smelt, err := resolver.CreateRecipe(ctx, struct{ Input *recipe.MutationInput }{mutationInput(ore, bar)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forge, err := resolver.CreateRecipe(ctx, struct{ Input *recipe.MutationInput }{mutationInput(bar, plate)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	namespaced := smelt.Model
+	namespaced.NamespaceID = &namespace
+	if _, err := recipeService.Update(ctx, namespaced.ID.Hex(), namespaced); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		input  *string
+		output *string
+		ns     *string
+		want   []bson.ObjectId
+	}{
+		{"all", nil, nil, nil, []bson.ObjectId{smelt.Model.ID, forge.Model.ID}},
+		{"by input item", stringPointer(bar.Hex()), nil, nil, []bson.ObjectId{forge.Model.ID}},
+		{"by output item", nil, stringPointer(bar.Hex()), nil, []bson.ObjectId{smelt.Model.ID}},
+		{"by namespace", nil, nil, stringPointer(namespace.Hex()), []bson.ObjectId{smelt.Model.ID}},
+		{"no match", stringPointer(plate.Hex()), nil, nil, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			connection, err := resolver.Recipes(ctx, struct {
+				First        *int32
+				Last         *int32
+				Before       *string
+				After        *string
+				InputItemId  *string
+				OutputItemId *string
+				NamespaceId  *string
+			}{InputItemId: test.input, OutputItemId: test.output, NamespaceId: test.ns})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(connection.Models) != len(test.want) {
+				t.Fatalf("got %d recipes, want %d", len(connection.Models), len(test.want))
+			}
+			for i := range test.want {
+				if connection.Models[i].ID != test.want[i] {
+					t.Errorf("recipe %d = %v, want %v", i, connection.Models[i].ID, test.want[i])
+				}
+			}
+		})
+	}
+
+	count, err := resolver.RecipeCount(ctx, struct{ NamespaceId string }{namespace.Hex()})
+	if err != nil || count != 1 {
+		t.Errorf("RecipeCount = %d, %v, want 1", count, err)
+	}
+}
+
+func TestResolverRejectsInvalidIDs(t *testing.T) {
+	ctx, _ := newResolverContext()
+	resolver := &Resolver{}
+
+	_, err := resolver.Recipes(ctx, struct {
+		First        *int32
+		Last         *int32
+		Before       *string
+		After        *string
+		InputItemId  *string
+		OutputItemId *string
+		NamespaceId  *string
+	}{InputItemId: stringPointer("iron")})
+	if err == nil {
+		t.Error("Recipes accepted an invalid inputItemId")
+	}
+
+	if _, err := resolver.RecipeCount(ctx, struct{ NamespaceId string }{"nope"}); err == nil {
+		t.Error("RecipeCount accepted an invalid namespaceId")
+	}
+	if _, err := resolver.Recipe(ctx, struct{ Id string }{"nope"}); err == nil {
+		t.Error("Recipe accepted an invalid id")
+	}
+	if _, err := resolver.DeleteRecipe(ctx, struct{ Id string }{"nope"}); err == nil {
+		t.Error("DeleteRecipe accepted an invalid id")
+	}
+	if _, err := resolver.UpdateRecipe(ctx, struct {
+		Id    string
+		Input *recipe.MutationInput
+	}{"nope", nil}); err == nil {
+		t.Error("UpdateRecipe accepted an invalid id")
+	}
+
+	invalidInputs := map[string]*recipe.MutationInput{
+		"an invalid item id": {Inputs: &[]*recipe.MutationInOutElement{{ItemID: "iron", Amount: 1}}},
+		"a null element":     {Outputs: &[]*recipe.MutationInOutElement{nil}},
+	}
+	for name, input := range invalidInputs {
+		if _, err := resolver.CreateRecipe(ctx, struct{ Input *recipe.MutationInput }{input}); err == nil {
+			t.Errorf("CreateRecipe accepted an input with %v", name)
+		}
+	}
+
+	created, err := resolver.CreateRecipe(ctx, struct{ Input *recipe.MutationInput }{nil})
+	if err != nil || len(created.Model.Inputs) != 0 || len(created.Model.Outputs) != 0 {
+		t.Errorf("CreateRecipe without input = %v, %v, want an empty recipe", created, err)
+	}
+}
+
+func TestResolverBrokenRecipes(t *testing.T) {
+	ctx, recipeService := newResolverContext()
+	resolver := &Resolver{}
+
+	deleted, kept := bson.NewObjectId(), bson.NewObjectId()
+
+	broken, err := resolver.CreateRecipe(ctx, struct{ Input *recipe.MutationInput }{mutationInput(deleted, kept)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolver.CreateRecipe(ctx, struct{ Input *recipe.MutationInput }{mutationInput(kept, kept)}); err != nil {
+		t.Fatal(err)
+	}
+
+	model := broken.Model
+	model.AddBrokenReference(deleted)
+	if _, err := recipeService.Update(ctx, model.ID.Hex(), model); err != nil {
+		t.Fatal(err)
+	}
+
+	connection, err := resolver.BrokenRecipes(ctx, recipeConnectionArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(connection.Models) != 1 || connection.Models[0].ID != model.ID {
+		t.Errorf("BrokenRecipes = %v, want only %v", connection.Models, model.ID)
+	}
+
+	deletedID, err := resolver.DeleteRecipe(ctx, struct{ Id string }{model.ID.Hex()})
+	if err != nil || string(*deletedID) != model.ID.Hex() {
+		t.Fatalf("DeleteRecipe = %v, %v", deletedID, err)
+	}
+	if _, err := resolver.Recipe(ctx, struct{ Id string }{model.ID.Hex()}); err == nil {
+		t.Error("deleted recipe is still found")
+	}
+}
+
+// failingService fails every listing and count, like the memory service does
+// for queries it can't evaluate.
+type failingService struct {
+	*recipe.MemoryService
+}
+
+func (s failingService) CountWithQuery(query bson.M) (int, error) {
+	return 0, errors.New("count failed")
+}
+
+func (s failingService) PerformListQuery(query bson.M, first *int32, last *int32, before *string, after *string) ([]recipe.Model, error) {
+	return nil, errors.New("list failed")
+}
+
+func TestResolverReturnsQueryErrors(t *testing.T) {
+	ctx, memoryService := newResolverContext()
+	ctx = context.WithValue(ctx, "recipeService", recipe.Service(failingService{memoryService}))
+	resolver := &Resolver{}
+
+	if _, err := resolver.BrokenRecipes(ctx, recipeConnectionArgs{}); err == nil {
+		t.Error("BrokenRecipes hid the query error")
+	}
+}
+
+func TestResolverUpdateRecipeKeepsStoredFields(t *testing.T) {
+	ctx, recipeService := newResolverContext()
+	resolver := &Resolver{}
+
+	ore, bar, ingot := bson.NewObjectId(), bson.NewObjectId(), bson.NewObjectId()
+	namespace := bson.NewObjectId()
+
+	stored := importedRecipe("1", ore, bar, 2)
+	stored.NamespaceID = &namespace
+	stored.BrokenReferences = []bson.ObjectId{ore, bar}
+	if _, err := recipeService.Create(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := resolver.UpdateRecipe(ctx, struct {
+		Id    string
+		Input *recipe.MutationInput
+	}{stored.ID.Hex(), &recipe.MutationInput{
+		Outputs: &[]*recipe.MutationInOutElement{{ItemID: graphql.ID(ingot.Hex()), Amount: 1}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model := updated.Model
+	if len(model.Outputs) != 1 || model.Outputs[0].ItemID != ingot {
+		t.Errorf("outputs = %v, want %v", model.Outputs, ingot)
+	}
+	if len(model.Inputs) != 1 || model.Inputs[0].ItemID != ore {
+		t.Errorf("inputs = %v, want the stored %v", model.Inputs, ore)
+	}
+	if model.NamespaceID == nil || *model.NamespaceID != namespace || *model.ImportSource != "rc" || *model.ExternalID != "1" {
+		t.Errorf("update lost the namespace or import source: %+v", model)
+	}
+	if len(model.BrokenReferences) != 1 || model.BrokenReferences[0] != ore {
+		t.Errorf("brokenReferences = %v, want only the still used %v", model.BrokenReferences, ore)
+	}
+
+	found, err := recipeService.FindByExternalID("rc", "1")
+	if err != nil || found.ID != stored.ID {
+		t.Errorf("FindByExternalID = %v, %v, want the updated recipe", found, err)
+	}
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"github.com/dukfaar/goUtils/relay"
+	"github.com/dukfaar/recipeBackend/importer"
+	"github.com/dukfaar/recipeBackend/itemmapping"
+	"github.com/dukfaar/recipeBackend/jobs"
 	"github.com/dukfaar/recipeBackend/recipe"
 )
 
@@ -9,11 +12,18 @@ var Schema string = `
 		schema {
 			query: Query
 			mutation: Mutation
+			subscription: Subscription
 		}
 
 		type Query {
-			recipes(first: Int, last: Int, before: String, after: String, inputItemId: ID, outputItemId: ID): RecipeConnection!
+			recipes(first: Int, last: Int, before: String, after: String, inputItemId: ID, outputItemId: ID, namespaceId: ID): RecipeConnection!
+			recipeCount(namespaceId: ID!): Int!
+			recipeCountsByNamespace: [NamespaceRecipeCount!]!
 			recipe(id: ID!): Recipe!
+			brokenRecipes(first: Int, last: Int, before: String, after: String): RecipeConnection!
+			importJob(id: ID!): ImportJob
+			backgroundJobs: [BackgroundJob!]!
+			itemMappings(source: String, first: Int, afterSource: String, afterExternalId: String): [ItemMapping!]!
 		}
 
 		input RecipeMutationInOutInput {
@@ -26,12 +36,30 @@ var Schema string = `
 			outputs: [RecipeMutationInOutInput]
 		}
 
+		input ImportFieldMappingInput {
+			field: String!
+			source: String!
+		}
+
 		type Mutation {
 			createRecipe(input: RecipeMutationInput): Recipe!
 			updateRecipe(id: ID!, input: RecipeMutationInput): Recipe!
 			deleteRecipe(id: ID!): ID
 
-			rcRecipeImport(): String!
+			rcRecipeImport(dryRun: Boolean): ImportJob!
+			fileRecipeImport(format: String!, path: String!, itemIdStrategy: String, mapping: [ImportFieldMappingInput!], namespaceId: ID, dryRun: Boolean): ImportJob!
+			cancelImportJob(id: ID!): ImportJob!
+
+			setItemMapping(source: String!, externalId: String!, itemId: ID!): ItemMapping!
+			deleteItemMapping(source: String!, externalId: String!): Boolean!
+			invalidateItemMappings(source: String!): Int!
+		}
+
+		type Subscription {
+			importJobProgress(id: ID!): ImportJob!
 		}` +
 	relay.PageInfoGraphQLString +
-	recipe.GraphQLType
+	recipe.GraphQLType +
+	importer.GraphQLType +
+	itemmapping.GraphQLType +
+	jobs.GraphQLType
@@ -3,89 +3,256 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/dukfaar/goUtils/env"
 	"github.com/dukfaar/goUtils/eventbus"
 	dukGraphql "github.com/dukfaar/goUtils/graphql"
 	dukHttp "github.com/dukfaar/goUtils/http"
-	"github.com/dukfaar/goUtils/permission"
+	"github.com/dukfaar/recipeBackend/config"
+	"github.com/dukfaar/recipeBackend/gateway"
+	"github.com/dukfaar/recipeBackend/graphqlserver"
+	"github.com/dukfaar/recipeBackend/health"
+	"github.com/dukfaar/recipeBackend/jobs"
+	"github.com/dukfaar/recipeBackend/localbus"
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/metrics"
+	"github.com/dukfaar/recipeBackend/persisted"
+	"github.com/dukfaar/recipeBackend/ratelimit"
+	"github.com/dukfaar/recipeBackend/rc"
 	"github.com/dukfaar/recipeBackend/recipe"
+	"github.com/dukfaar/recipeBackend/throttle"
+	"github.com/dukfaar/recipeBackend/tracing"
 
 	"github.com/globalsign/mgo"
 
 	"github.com/gorilla/websocket"
 
 	graphql "github.com/graph-gophers/graphql-go"
-	graphqlRelay "github.com/graph-gophers/graphql-go/relay"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func createApiGatewayFetcher() dukGraphql.Fetcher {
-	url := env.GetDefaultEnvVar("API_GATEWAY_HOST", "localhost") + ":" + env.GetDefaultEnvVar("API_GATEWAY_PORT", "8090")
-	path := env.GetDefaultEnvVar("API_GATEWAY_PATH", "/graphql")
+func createApiGatewayFetcher(gatewayConfig config.GatewayConfig) dukGraphql.Fetcher {
+	url := gatewayConfig.Host + ":" + gatewayConfig.Port
 
-	apiGatewayFetcher, err := dukGraphql.NewHttpFetcher(url, path)
+	apiGatewayFetcher, err := dukGraphql.NewHttpFetcher(url, gatewayConfig.Path)
 
 	if err != nil {
 		panic(err)
 	}
 
-	clientID := os.Getenv("CLIENT_ID")
-	clientSecret := os.Getenv("CLIENT_SECRET")
-
-	loginApiGatewayFetcher := dukGraphql.NewClientLoginHttpFetcher(apiGatewayFetcher, clientID, clientSecret)
+	loginApiGatewayFetcher := dukGraphql.NewClientLoginHttpFetcher(apiGatewayFetcher, gatewayConfig.ClientID, gatewayConfig.ClientSecret)
 
 	return loginApiGatewayFetcher
 }
 
+func createGatewayClient(fetcher dukGraphql.Fetcher, gatewayConfig config.GatewayConfig) *gateway.Client {
+	gatewayClient := gateway.NewClient(fetcher)
+	gatewayClient.Limiter = throttle.NewLimiter(
+		gatewayConfig.RatePerSecond,
+		gatewayConfig.RateBurst,
+		gatewayConfig.MaxConcurrent,
+	)
+	return gatewayClient
+}
+
+func createRcClient(rcConfig config.RCConfig) *rc.Client {
+	rcClient := rc.NewClient(rcConfig.URL)
+	rcClient.HTTPClient.Timeout = rcConfig.Timeout
+	rcClient.Retries = rcConfig.Retries
+	rcClient.MaxResponseSize = int64(rcConfig.MaxResponseSize)
+	rcClient.Limiter = throttle.NewLimiter(
+		rcConfig.RatePerSecond,
+		rcConfig.RateBurst,
+		rcConfig.MaxConcurrent,
+	)
+
+	return rcClient
+}
+
+func dialMongo(mongoConfig config.MongoConfig) (*mgo.Session, error) {
+	dialInfo, err := mongoConfig.DialInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return mgo.DialWithInfo(dialInfo)
+}
+
+func createEventbus(serviceConfig *config.Config) eventbus.EventBus {
+	if serviceConfig.Eventbus == config.EventbusLocal {
+		slog.Info("using the in-process eventbus")
+		return localbus.NewBus()
+	}
+
+	return eventbus.NewNsqEventBus(serviceConfig.Nsq.TCPURL, serviceConfig.Nsq.LookupHTTPURL)
+}
+
+// bindRecipeService binds the recipe service in ctx to ctx, so the spans of
+// its calls become children of the current GraphQL span.
+func bindRecipeService(ctx context.Context) context.Context {
+	recipeService, ok := ctx.Value("recipeService").(recipe.Service)
+	if !ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, "recipeService", recipe.WithContext(ctx, recipeService))
+}
+
 func main() {
-	dbSession, err := mgo.Dial(env.GetDefaultEnvVar("DB_HOST", "localhost"))
+	serviceConfig, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	logger, err := logging.New(os.Stdout, serviceConfig.Log.Format, serviceConfig.Log.Level)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	logger.Info("effective config", "config", serviceConfig.Redacted())
+
+	shutdownTracing, err := tracing.Setup(context.Background(), serviceConfig.Tracing, "recipe")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	eventBus := metrics.NewEventBus(createEventbus(serviceConfig))
+
+	storage, err := OpenStorage(serviceConfig, eventBus)
 	if err != nil {
 		panic(err)
 	}
-	defer dbSession.Close()
+	defer storage.Close()
+
+	gatewayHealth := health.NewFlag(errors.New("no gateway request yet"))
+
+	loginApiGatewayFetcher := &health.ObservedFetcher{
+		Fetcher: &metrics.Fetcher{Fetcher: createApiGatewayFetcher(serviceConfig.Gateway)},
+		Flag:    gatewayHealth,
+	}
+	permissionSync := NewPermissionSync(loginApiGatewayFetcher, serviceConfig.Gateway.PermissionResyncInterval)
 
-	log.Println("Connected to database")
+	// stopped on shutdown
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
 
-	db := dbSession.DB("recipe")
+	readiness := health.NewChecker(5 * time.Second)
+	if storage.Check != nil {
+		readiness.Add("mongo", storage.Check)
+	}
+	if serviceConfig.Eventbus == config.EventbusNsq {
+		readiness.Add("nsqd", health.TCPCheck(serviceConfig.Nsq.TCPURL))
+		readiness.Add("nsqlookupd", health.NsqLookupCheck(serviceConfig.Nsq.LookupHTTPURL))
+	}
+	readiness.Add("gateway", gatewayHealth.Check)
+	readiness.Add("permissions", permissionSync.Health.Check)
 
-	nsqEventbus := eventbus.NewNsqEventBus(env.GetDefaultEnvVar("NSQD_TCP_URL", "localhost:4150"), env.GetDefaultEnvVar("NSQLOOKUP_HTTP_URL", "localhost:4161"))
+	// liveness only says the process still serves requests, a broken dependency
+	// is no reason to get restarted
+	http.Handle("/healthz", health.Handler(health.NewChecker(5*time.Second)))
+	http.Handle("/readyz", health.Handler(readiness))
 
-	permissionService := permission.NewService()
-	loginApiGatewayFetcher := createApiGatewayFetcher()
+	namespaceCache := NewNamespaceCache()
 
-	ctx := context.Background()
-	ctx = context.WithValue(ctx, "db", db)
-	ctx = context.WithValue(ctx, "recipeService", recipe.NewMgoService(db, nsqEventbus))
-	ctx = context.WithValue(ctx, "permissionService", permissionService)
-	ctx = context.WithValue(ctx, "eventbus", nsqEventbus)
+	ctx := logging.WithLogger(context.Background(), logger)
+	ctx = context.WithValue(ctx, "db", storage.DB)
+	ctx = context.WithValue(ctx, "recipeService", storage.RecipeService)
+	ctx = context.WithValue(ctx, "permissionSync", permissionSync)
+	ctx = context.WithValue(ctx, "eventbus", eventBus)
 	ctx = context.WithValue(ctx, "apigatewayfetcher", loginApiGatewayFetcher)
+	ctx = context.WithValue(ctx, "gatewayClient", createGatewayClient(loginApiGatewayFetcher, serviceConfig.Gateway))
+	ctx = context.WithValue(ctx, "namespaceCache", namespaceCache)
+	ctx = context.WithValue(ctx, "rcClient", createRcClient(serviceConfig.RC))
+	ctx = context.WithValue(ctx, "itemMappingStore", storage.ItemMappingStore)
+	ctx = context.WithValue(ctx, "importWorkers", serviceConfig.Import.Workers)
+	ctx = context.WithValue(ctx, "importJobService", storage.JobService)
+	ctx = context.WithValue(ctx, "importDir", serviceConfig.Import.Dir)
+
+	// the runner sees all services above, but none of the request values
+	jobRunner := jobs.NewRunner(ctx)
+	ctx = context.WithValue(ctx, "jobRunner", jobRunner)
+
+	// the tracer needs the root fields before the schema it traces exists
+	rootFields := metrics.RootFields(graphql.MustParseSchema(Schema, nil).Inspect())
+	schema := graphql.MustParseSchema(Schema, &Resolver{}, graphql.Tracer(logging.Tracer{
+		Next: metrics.Tracer{
+			Next: tracing.GraphQLTracer{
+				Bind: bindRecipeService,
+			},
+			RootFields: rootFields,
+		},
+		Actor: recipe.ActorFromContext,
+	}))
 
-	schema := graphql.MustParseSchema(Schema, &Resolver{})
+	// the manifest is kept apart from the queries persisted by clients, so
+	// they can't add to the allowlist
+	persistedQueries := serviceConfig.GraphQL.PersistedQueries
+	manifest := persisted.NewMemoryStore(0)
+	if persistedQueries.Manifest != "" {
+		count, err := persisted.LoadManifest(persistedQueries.Manifest, manifest)
+		if err != nil {
+			panic(err)
+		}
+		logger.Info("loaded persisted query manifest", "queries", count)
+	}
 
-	http.Handle("/graphql", dukHttp.AddContext(ctx, dukHttp.Authenticate(&graphqlRelay.Handler{
-		Schema: schema,
-	})))
+	// persisted queries come first, the other checks need the query
+	var checks []graphqlserver.Check
+	var executed []graphqlserver.Hook
+	if persistedQueries.Mode != config.PersistedQueriesOff {
+		checks = append(checks, persistedQueryCheck(manifest, storage.QueryStore, persistedQueries.Mode == config.PersistedQueriesAllowlist))
+	}
+	if persistedQueries.Mode == config.PersistedQueriesAuto {
+		executed = append(executed, persistQuery(manifest, storage.QueryStore, persistedQueries.MaxQueryLength))
+	}
+	rateLimiter := newRateLimiter(serviceConfig.RateLimit, serviceConfig.GraphQL.ComplexityBudget)
+	checks = append(checks, complexityCheck(schema, serviceConfig.GraphQL, rateLimitCheck(rateLimiter, serviceConfig.RateLimit.ExpensiveComplexity)))
+
+	executor := &graphqlserver.Executor{
+		Schema:   schema,
+		Checks:   checks,
+		Executed: executed,
+	}
+
+	http.Handle("/graphql", dukHttp.AddContext(ctx, logging.Middleware(logger, tracing.Middleware(dukHttp.Authenticate(rateLimitMiddleware(rateLimiter, bucketRequests, &graphqlserver.Handler{
+		Executor: executor,
+	}))))))
+
+	http.Handle("/export", dukHttp.AddContext(ctx, logging.Middleware(logger, tracing.Middleware(dukHttp.Authenticate(ExportHandler())))))
 
-	http.Handle("/socket", dukHttp.AddContext(ctx, &dukGraphql.SocketHandler{
-		Schema: schema,
+	http.Handle("/import", dukHttp.AddContext(ctx, logging.Middleware(logger, tracing.Middleware(dukHttp.Authenticate(rateLimitMiddleware(rateLimiter, bucketImports, ImportUploadHandler()))))))
+
+	socketConfig := serviceConfig.Socket
+	socketHandler := &graphqlserver.SocketHandler{
+		Executor: executor,
 		Upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
-			CheckOrigin: func(r *http.Request) bool {
-				return true
-			},
+			CheckOrigin:     graphqlserver.AllowOrigins(socketConfig.Origins()),
 		},
-	}))
+		Authenticate:   authenticateSocket,
+		Admit:          admitSocket(rateLimiter),
+		ConnectionKey:  ratelimit.KeyFromContext,
+		MaxConnections: socketConfig.MaxConnectionsPerUser,
+		InitTimeout:    socketConfig.InitTimeout,
+		KeepAlive:      socketConfig.KeepAlive,
+		IdleTimeout:    socketConfig.IdleTimeout,
+	}
+	http.Handle("/socket", dukHttp.AddContext(ctx, logging.Middleware(logger, tracing.Middleware(dukHttp.Authenticate(rateLimitKeyMiddleware(socketHandler))))))
 
 	serviceInfo := eventbus.ServiceInfo{
 		Name:                  "recipe",
-		Hostname:              env.GetDefaultEnvVar("PUBLISHED_HOSTNAME", "servicebackend"),
-		Port:                  env.GetDefaultEnvVar("PUBLISHED_PORT", "8080"),
+		Hostname:              serviceConfig.Service.PublishedHostname,
+		Port:                  serviceConfig.Service.PublishedPort,
 		GraphQLHttpEndpoint:   "/graphql",
 		GraphQLSocketEndpoint: "/socket",
 		SchemaExtensions: []eventbus.SchemaExtension{{
@@ -115,48 +282,101 @@ func main() {
 					},
 				},
 			},
+		}, {
+			Type: "Namespace",
+			Fields: []eventbus.FieldType{
+				{
+					Name: "recipes",
+					Type: "RecipeConnection!",
+					Resolve: eventbus.ResolveType{
+						By: "recipes",
+						FieldArguments: map[string]string{
+							"namespaceId": "_id",
+						},
+					},
+				},
+				{
+					Name: "recipeCount",
+					Type: "Int!",
+					Resolve: eventbus.ResolveType{
+						By: "recipeCount",
+						FieldArguments: map[string]string{
+							"namespaceId": "_id",
+						},
+					},
+				},
+			},
 		}},
 	}
 
-	result, err := loginApiGatewayFetcher.Fetch(dukGraphql.Request{
-		Query: permission.Query,
-	})
-	if err != nil {
-		panic(err)
-	}
-	queryResult := dukGraphql.Response{result}
-
-	permission.ParseQueryResponse(queryResult, permissionService)
-	permissionService.BuildAllUserPermissionData()
+	// serve right away, permission checks deny everything until the gateway
+	// delivered the permission data
+	go permissionSync.Run(backgroundCtx)
 
-	permission.AddAuthEventsHandlers(nsqEventbus, permissionService)
+	permissionSync.AddAuthEventsHandlers(eventBus)
 
-	nsqEventbus.Emit("service.up", serviceInfo)
+	eventBus.Emit("service.up", serviceInfo)
 
-	nsqEventbus.On("service.up", "recipe", func(msg []byte) error {
+	eventBus.On("service.up", "recipe", func(msg []byte) error {
 		newService := eventbus.ServiceInfo{}
 		json.Unmarshal(msg, &newService)
 
 		if newService.Name == "apigateway" {
-			nsqEventbus.Emit("service.up", serviceInfo)
+			eventBus.Emit("service.up", serviceInfo)
 		}
 
 		return nil
 	})
 
-	eventDBSession := dbSession.Clone()
-	eventDB := eventDBSession.DB("recipe")
-	defer eventDBSession.Close()
-	eventRecipeService := recipe.NewMgoService(eventDB, nsqEventbus)
+	handlerGate := &HandlerGate{}
+	eventCtx := logging.WithLogger(context.Background(), logger)
+	on := func(topic string, handler EventHandler) {
+		eventBus.On(topic, "recipe", handlerGate.Wrap(withEventContext(eventCtx, topic, handler)))
+	}
 
-	nsqEventbus.On("import.recipe", "recipe", CreateRCEventImporter(eventRecipeService, loginApiGatewayFetcher))
+	on("import.recipe", CreateImportEventHandler(storage.EventRecipeService, storage.EventJobService))
+	on("item.deleted", CreateItemDeletedHandler(storage.EventRecipeService, storage.ItemMappingStore))
+	on("item.merged", CreateItemMergedHandler(storage.EventRecipeService, storage.ItemMappingStore))
+	on("namespace.created", CreateNamespaceChangedHandler(namespaceCache))
+	on("namespace.updated", CreateNamespaceChangedHandler(namespaceCache))
+	on("namespace.deleted", CreateNamespaceDeletedHandler(storage.EventRecipeService, namespaceCache))
 
 	http.Handle("/metrics", promhttp.Handler())
 
-	dukGraphql.EmitRegisterEvents("registerQuery", schema.Inspect().QueryType(), nsqEventbus)
-	dukGraphql.EmitRegisterEvents("registerMutation", schema.Inspect().MutationType(), nsqEventbus)
-	dukGraphql.EmitRegisterEvents("registerSubscription", schema.Inspect().SubscriptionType(), nsqEventbus)
-	dukGraphql.EmitRegisterTypeEvents("registerType", schema.Inspect().Types(), nsqEventbus)
+	dukGraphql.EmitRegisterEvents("registerQuery", schema.Inspect().QueryType(), eventBus)
+	dukGraphql.EmitRegisterEvents("registerMutation", schema.Inspect().MutationType(), eventBus)
+	dukGraphql.EmitRegisterEvents("registerSubscription", schema.Inspect().SubscriptionType(), eventBus)
+	dukGraphql.EmitRegisterTypeEvents("registerType", schema.Inspect().Types(), eventBus)
+
+	server := &http.Server{
+		Addr: ":" + serviceConfig.Port,
+	}
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		serverErrors <- server.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	select {
+	case err := <-serverErrors:
+		logger.Error("serving http failed", "error", err)
+		os.Exit(1)
+	case sig := <-signals:
+		logger.Info("shutting down", "signal", sig.String())
+	}
+
+	stopBackground()
+
+	shutdown(serviceConfig.ShutdownTimeout, server, socketHandler, eventBus, handlerGate, jobRunner, serviceInfo)
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Error("flushing spans failed", "error", err)
+	}
+	cancelFlush()
 
-	log.Fatal(http.ListenAndServe(":"+env.GetDefaultEnvVar("PORT", "8080"), nil))
+	logger.Info("shutdown complete")
 }
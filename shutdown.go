@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dukfaar/goUtils/eventbus"
+
+	"github.com/dukfaar/recipeBackend/graphqlserver"
+	"github.com/dukfaar/recipeBackend/jobs"
+)
+
+var errShuttingDown = errors.New("service is shutting down")
+
+// HandlerGate wraps eventbus handlers, so shutdown can wait for the messages
+// being handled and turn away new ones. Rejected messages are requeued by nsq
+// and picked up by another replica.
+type HandlerGate struct {
+	mutex     sync.RWMutex
+	closed    bool
+	waitGroup sync.WaitGroup
+}
+
+func (g *HandlerGate) Wrap(handler func(msg []byte) error) func(msg []byte) error {
+	return func(msg []byte) error {
+		g.mutex.RLock()
+		if g.closed {
+			g.mutex.RUnlock()
+			return errShuttingDown
+		}
+		g.waitGroup.Add(1)
+		g.mutex.RUnlock()
+
+		defer g.waitGroup.Done()
+		return handler(msg)
+	}
+}
+
+// Close turns away new messages and returns a channel that is closed once
+// all running handlers have returned.
+func (g *HandlerGate) Close() <-chan struct{} {
+	g.mutex.Lock()
+	g.closed = true
+	g.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.waitGroup.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// stopEventbus stops the consumers of the eventbus, looking through wrappers
+// like the metrics bus, and reports whether it could. Otherwise the consumers
+// keep receiving until the process exits, and the handler gate turns their
+// messages away.
+func stopEventbus(bus eventbus.EventBus) bool {
+	for {
+		if stopper, ok := bus.(interface{ Stop() }); ok {
+			stopper.Stop()
+			return true
+		}
+
+		wrapper, ok := bus.(interface{ Unwrap() eventbus.EventBus })
+		if !ok {
+			slog.Warn("eventbus can't be stopped, new messages are requeued until exit")
+			return false
+		}
+		bus = wrapper.Unwrap()
+	}
+}
+
+// shutdown tears the service down in the order that loses the least work:
+// the gateway stops routing to us, in-flight requests finish, websockets and
+// event handlers are drained, and running jobs get cancelled. Cancelled
+// import jobs are marked as failed with errImportInterrupted.
+func shutdown(
+	timeout time.Duration,
+	server *http.Server,
+	sockets *graphqlserver.SocketHandler,
+	bus eventbus.EventBus,
+	handlerGate *HandlerGate,
+	jobRunner *jobs.Runner,
+	serviceInfo eventbus.ServiceInfo,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	bus.Emit("service.down", serviceInfo)
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("draining http connections failed", "error", err)
+	}
+
+	slog.Info("closing websocket connections", "count", sockets.Count())
+	sockets.CloseAll()
+
+	handlersDone := handlerGate.Close()
+	stopEventbus(bus)
+	select {
+	case <-handlersDone:
+	case <-ctx.Done():
+		slog.Warn("timed out waiting for event handlers")
+	}
+
+	if err := jobRunner.Shutdown(ctx); err != nil {
+		slog.Error("stopping background jobs failed", "error", err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dukfaar/goUtils/eventbus"
+
+	"github.com/dukfaar/recipeBackend/metrics"
+)
+
+// unstoppableBus is a bus without Stop, like the nsq bus of goUtils.
+type unstoppableBus struct{}
+
+func (unstoppableBus) Emit(topic string, data interface{})                         {}
+func (unstoppableBus) On(topic string, channel string, handler func([]byte) error) {}
+
+type stoppableBus struct {
+	unstoppableBus
+	stopped int
+}
+
+func (b *stoppableBus) Stop() {
+	b.stopped++
+}
+
+func TestStopEventbus(t *testing.T) {
+	stoppable := &stoppableBus{}
+
+	tests := []struct {
+		name string
+		bus  eventbus.EventBus
+		want bool
+	}{
+		{"bus without Stop", unstoppableBus{}, false},
+		{"wrapped bus without Stop", metrics.NewEventBus(unstoppableBus{}), false},
+		{"wrapped bus", metrics.NewEventBus(stoppable), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := stopEventbus(test.bus); got != test.want {
+				t.Errorf("stopEventbus() = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	if stoppable.stopped != 1 {
+		t.Errorf("wrapped bus stopped %d times, want 1", stoppable.stopped)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	dukHttp "github.com/dukfaar/goUtils/http"
+
+	"github.com/dukfaar/recipeBackend/logging"
+	"github.com/dukfaar/recipeBackend/ratelimit"
+)
+
+var errSocketUnauthorized = errors.New("authentication of the connection failed")
+
+// socketAuthorization returns the authorization header sent in a
+// connection_init payload, as "authorization" like the http header or as
+// the bare "authToken" of apollo clients.
+func socketAuthorization(payload json.RawMessage) string {
+	var params map[string]interface{}
+	if err := json.Unmarshal(payload, &params); err != nil {
+		return ""
+	}
+
+	for name, value := range params {
+		if strings.EqualFold(name, "authorization") {
+			authorization, _ := value.(string)
+			return authorization
+		}
+	}
+
+	if token, _ := params["authToken"].(string); token != "" {
+		return "Bearer " + token
+	}
+
+	return ""
+}
+
+// discardResponse swallows what dukHttp.Authenticate replies to a request
+// it rejects.
+type discardResponse struct {
+	header http.Header
+}
+
+func (w *discardResponse) Header() http.Header            { return w.header }
+func (w *discardResponse) Write(data []byte) (int, error) { return len(data), nil }
+func (w *discardResponse) WriteHeader(int)                {}
+
+// authenticateSocket authenticates the token of a connection_init payload
+// the same way dukHttp.Authenticate authenticates http requests, so socket
+// operations get the same permission checks. Connections without a token
+// keep the context of their upgrade request, which was authenticated by its
+// headers.
+func authenticateSocket(ctx context.Context, r *http.Request, payload json.RawMessage) (context.Context, error) {
+	authorization := socketAuthorization(payload)
+	if authorization == "" {
+		return ctx, nil
+	}
+
+	authRequest := r.Clone(ctx)
+	authRequest.Header.Set("Authorization", authorization)
+
+	var authenticated context.Context
+	dukHttp.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated = r.Context()
+	})).ServeHTTP(&discardResponse{header: make(http.Header)}, authRequest)
+
+	if authenticated == nil {
+		logging.FromContext(ctx).Warn("refused socket connection with invalid token")
+		return nil, errSocketUnauthorized
+	}
+
+	// the connection counts against the limits of the authenticated user now
+	return ratelimit.WithKey(authenticated, rateLimitKey(authenticated, r.RemoteAddr)), nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/dukfaar/goUtils/eventbus"
+
+	"github.com/globalsign/mgo"
+
+	"github.com/dukfaar/recipeBackend/config"
+	"github.com/dukfaar/recipeBackend/health"
+	"github.com/dukfaar/recipeBackend/importer"
+	"github.com/dukfaar/recipeBackend/itemmapping"
+	"github.com/dukfaar/recipeBackend/metrics"
+	"github.com/dukfaar/recipeBackend/persisted"
+	"github.com/dukfaar/recipeBackend/recipe"
+)
+
+// Storage holds the services backed by the configured storage.
+type Storage struct {
+	// DB is nil when running without a database
+	DB               *mgo.Database
+	RecipeService    recipe.Service
+	JobService       importer.JobService
+	ItemMappingStore itemmapping.Store
+	QueryStore       persisted.Store
+
+	// the event handlers get their own services, so they don't queue up
+	// behind requests on the same mongo session
+	EventRecipeService recipe.Service
+	EventJobService    importer.JobService
+
+	// Check is nil if there is no dependency to check
+	Check health.Check
+
+	sessions []*mgo.Session
+}
+
+func OpenStorage(serviceConfig *config.Config, bus eventbus.EventBus) (*Storage, error) {
+	if serviceConfig.Storage == config.StorageMemory {
+		slog.Info("keeping all data in memory")
+
+		recipeService := recipe.NewTracedService(metrics.NewRecipeService(recipe.NewMemoryService(bus)))
+		jobService := importer.NewMemoryJobService()
+
+		return &Storage{
+			RecipeService:      recipeService,
+			JobService:         jobService,
+			ItemMappingStore:   itemmapping.NewMemoryStore(),
+			QueryStore:         persisted.NewMemoryStore(serviceConfig.GraphQL.PersistedQueries.CacheSize),
+			EventRecipeService: recipeService,
+			EventJobService:    jobService,
+		}, nil
+	}
+
+	dbSession, err := dialMongo(serviceConfig.Mongo)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("connected to database")
+
+	db := dbSession.DB(serviceConfig.Mongo.Database)
+
+	eventDBSession := dbSession.Clone()
+	eventDB := eventDBSession.DB(serviceConfig.Mongo.Database)
+
+	fail := func(err error) (*Storage, error) {
+		eventDBSession.Close()
+		dbSession.Close()
+		return nil, err
+	}
+
+	jobService, err := importer.NewMgoJobService(db)
+	if err != nil {
+		return fail(err)
+	}
+	eventJobService, err := importer.NewMgoJobService(eventDB)
+	if err != nil {
+		return fail(err)
+	}
+	itemMappingStore, err := itemmapping.NewMgoStore(db)
+	if err != nil {
+		return fail(err)
+	}
+	persistedQueries := serviceConfig.GraphQL.PersistedQueries
+	queryStore, err := persisted.NewMgoStore(db, persistedQueries.TTL)
+	if err != nil {
+		return fail(err)
+	}
+
+	return &Storage{
+		DB:                 db,
+		RecipeService:      recipe.NewTracedService(metrics.NewRecipeService(recipe.NewMgoService(db, bus))),
+		JobService:         jobService,
+		ItemMappingStore:   itemmapping.NewCachedStore(itemMappingStore, 10*time.Minute),
+		QueryStore:         persisted.NewCachedStore(queryStore, persistedQueries.CacheSize),
+		EventRecipeService: recipe.NewTracedService(metrics.NewRecipeService(recipe.NewMgoService(eventDB, bus))),
+		EventJobService:    eventJobService,
+		Check:              health.MongoCheck(dbSession),
+		sessions:           []*mgo.Session{eventDBSession, dbSession},
+	}, nil
+}
+
+func (s *Storage) Close() {
+	for _, session := range s.sessions {
+		session.Close()
+	}
+}
@@ -0,0 +1,58 @@
+package throttle
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter caps both the rate and the number of concurrent calls to an upstream.
+// A nil *Limiter doesn't limit anything.
+type Limiter struct {
+	rate  *rate.Limiter
+	slots chan struct{}
+}
+
+// NewLimiter allows perSecond calls per second with bursts of up to burst
+// calls, and at most maxConcurrent calls at the same time. Zero or negative
+// values disable the respective limit.
+func NewLimiter(perSecond float64, burst int, maxConcurrent int) *Limiter {
+	limiter := &Limiter{}
+
+	if perSecond > 0 {
+		if burst <= 0 {
+			burst = 1
+		}
+		limiter.rate = rate.NewLimiter(rate.Limit(perSecond), burst)
+	}
+
+	if maxConcurrent > 0 {
+		limiter.slots = make(chan struct{}, maxConcurrent)
+	}
+
+	return limiter
+}
+
+// Do waits until the call is allowed and runs fn.
+func (l *Limiter) Do(ctx context.Context, fn func() error) error {
+	if l == nil {
+		return fn()
+	}
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+			defer func() { <-l.slots }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if l.rate != nil {
+		if err := l.rate.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	return fn()
+}
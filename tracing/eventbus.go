@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/dukfaar/goUtils/eventbus"
+)
+
+// Carrier is an event payload that can carry a trace context, so the
+// handlers of the event continue the trace of whatever emitted it.
+type Carrier interface {
+	SetTraceContext(traceContext map[string]string)
+}
+
+// Emit emits event in a producer span and stores the context of that span
+// in the event.
+func Emit(ctx context.Context, bus eventbus.EventBus, topic string, event Carrier) {
+	_, span := Start(ctx, "emit "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("messaging.destination.name", topic)),
+	)
+	defer span.End()
+
+	event.SetTraceContext(Inject(trace.ContextWithSpan(ctx, span)))
+	bus.Emit(topic, event)
+}
+
+// Handle runs handle in a consumer span continuing traceContext, the trace
+// context the message was emitted with.
+func Handle(ctx context.Context, topic string, traceContext map[string]string, handle func(ctx context.Context) error) error {
+	ctx, span := Start(Extract(ctx, traceContext), "handle "+topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("messaging.destination.name", topic)),
+	)
+
+	err := handle(ctx)
+	End(span, err)
+	return err
+}
@@ -0,0 +1,20 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+
+	dukGraphql "github.com/dukfaar/goUtils/graphql"
+)
+
+// Fetch sends request to the api gateway in a client span. The fetcher has
+// no context of its own, so the span is started here.
+func Fetch(ctx context.Context, fetcher dukGraphql.Fetcher, request dukGraphql.Request) (interface{}, error) {
+	_, span := Start(ctx, "gateway fetch", trace.WithSpanKind(trace.SpanKindClient))
+
+	result, err := fetcher.Fetch(request)
+	End(span, err)
+
+	return result, err
+}
@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/introspection"
+	graphqlTrace "github.com/graph-gophers/graphql-go/trace"
+)
+
+// GraphQLTracer starts a span for every GraphQL operation and every
+// non-trivial resolver.
+type GraphQLTracer struct {
+	// Bind is called with the context of every new span, so values that
+	// capture the context can be rebound to it
+	Bind func(ctx context.Context) context.Context
+}
+
+func (t GraphQLTracer) bind(ctx context.Context) context.Context {
+	if t.Bind == nil {
+		return ctx
+	}
+	return t.Bind(ctx)
+}
+
+func (t GraphQLTracer) TraceQuery(ctx context.Context, queryString string, operationName string, variables map[string]interface{}, varTypes map[string]*introspection.Type) (context.Context, graphqlTrace.TraceQueryFinishFunc) {
+	name := operationName
+	if name == "" {
+		name = "anonymous"
+	}
+
+	ctx, span := Start(ctx, "graphql "+name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("graphql.operation.name", operationName),
+			attribute.String("graphql.document", queryString),
+		),
+	)
+
+	return t.bind(ctx), func(errs []*errors.QueryError) {
+		if len(errs) > 0 {
+			for _, err := range errs {
+				span.RecordError(err)
+			}
+			span.SetStatus(codes.Error, fmt.Sprintf("%d errors", len(errs)))
+		}
+		span.End()
+	}
+}
+
+func (t GraphQLTracer) TraceField(ctx context.Context, label, typeName, fieldName string, trivial bool, args map[string]interface{}) (context.Context, graphqlTrace.TraceFieldFinishFunc) {
+	if trivial {
+		return ctx, func(*errors.QueryError) {}
+	}
+
+	ctx, span := Start(ctx, label, trace.WithAttributes(
+		attribute.String("graphql.type", typeName),
+		attribute.String("graphql.field", fieldName),
+	))
+
+	return t.bind(ctx), func(err *errors.QueryError) {
+		if err != nil {
+			End(span, err)
+			return
+		}
+		span.End()
+	}
+}